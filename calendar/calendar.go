@@ -0,0 +1,204 @@
+// Package calendar 提供中国法定节假日和调休工作日的工作日历。
+//
+// 每年的数据是一个 JSON 文件，内置在 data 目录中并编译进程序；
+// 新一年的安排公布后，可以通过管理接口上传 JSON 或 ICS 文件覆盖或补充，无需重新发布。
+// 没有数据的年份按周一至周五为工作日处理。
+package calendar
+
+import (
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+//go:embed data/*.json
+var embedded embed.FS
+
+// Day 一个特殊日期：Holiday 为 true 表示放假，为 false 表示调休上班
+type Day struct {
+	Date    string `json:"date"` // YYYY-MM-DD
+	Name    string `json:"name"` // 所属节日，如 春节
+	Holiday bool   `json:"holiday"`
+}
+
+// Year 一年的节假日安排
+type Year struct {
+	Year int   `json:"year"`
+	Days []Day `json:"days"`
+}
+
+// Validate 校验日期格式，且所有日期都属于该年
+func (y *Year) Validate() error {
+	if y.Year < 1900 || y.Year > 2100 {
+		return fmt.Errorf("无效的年份: %d", y.Year)
+	}
+	seen := map[string]bool{}
+	for _, d := range y.Days {
+		t, err := time.Parse("2006-01-02", d.Date)
+		if err != nil {
+			return fmt.Errorf("无效的日期: %s", d.Date)
+		}
+		if t.Year() != y.Year {
+			return fmt.Errorf("日期 %s 不属于 %d 年", d.Date, y.Year)
+		}
+		if seen[d.Date] {
+			return fmt.Errorf("日期重复: %s", d.Date)
+		}
+		seen[d.Date] = true
+	}
+	return nil
+}
+
+// ParseJSON 解析一年的 JSON 数据
+func ParseJSON(data []byte) (*Year, error) {
+	var y Year
+	if err := json.Unmarshal(data, &y); err != nil {
+		return nil, errors.New("无效的日历 JSON")
+	}
+	if err := y.Validate(); err != nil {
+		return nil, err
+	}
+	sort.Slice(y.Days, func(i, j int) bool { return y.Days[i].Date < y.Days[j].Date })
+	return &y, nil
+}
+
+// Calendar 工作日历，可并发读取，加载新数据时整年替换
+type Calendar struct {
+	mu      sync.RWMutex
+	years   map[int]*Year
+	days    map[string]Day
+	version int64
+}
+
+// New 创建空的工作日历，此时周一至周五为工作日
+func New() *Calendar {
+	return &Calendar{years: map[int]*Year{}, days: map[string]Day{}}
+}
+
+var defaultCalendar = mustLoadEmbedded()
+
+// Default 返回全局工作日历，初始为内置数据
+func Default() *Calendar {
+	return defaultCalendar
+}
+
+func mustLoadEmbedded() *Calendar {
+	c := New()
+	entries, err := embedded.ReadDir("data")
+	if err != nil {
+		panic(err)
+	}
+	for _, e := range entries {
+		data, err := embedded.ReadFile("data/" + e.Name())
+		if err != nil {
+			panic(err)
+		}
+		y, err := ParseJSON(data)
+		if err != nil {
+			panic(fmt.Sprintf("内置日历 %s 无效: %v", e.Name(), err))
+		}
+		c.Load(y)
+	}
+	return c
+}
+
+// Load 加载一年的数据，替换该年已有的数据
+func (c *Calendar) Load(y *Year) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if old, ok := c.years[y.Year]; ok {
+		for _, d := range old.Days {
+			delete(c.days, d.Date)
+		}
+	}
+	c.years[y.Year] = y
+	for _, d := range y.Days {
+		c.days[d.Date] = d
+	}
+	c.version++
+}
+
+// Version 每次加载数据后递增，用于区分缓存
+func (c *Calendar) Version() int64 {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.version
+}
+
+// Years 返回已加载数据的年份
+func (c *Calendar) Years() []int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	years := make([]int, 0, len(c.years))
+	for y := range c.years {
+		years = append(years, y)
+	}
+	sort.Ints(years)
+	return years
+}
+
+// Year 返回某年的数据，没有数据时第二个返回值为 false
+func (c *Calendar) Year(year int) (*Year, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	y, ok := c.years[year]
+	return y, ok
+}
+
+// Lookup 返回某天的特殊安排 (放假或调休上班)，普通日期第二个返回值为 false
+func (c *Calendar) Lookup(t time.Time) (Day, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	d, ok := c.days[t.Format("2006-01-02")]
+	return d, ok
+}
+
+// IsWorkday 判断某天 (只看年月日) 是否为工作日
+func (c *Calendar) IsWorkday(t time.Time) bool {
+	if d, ok := c.Lookup(t); ok {
+		return !d.Holiday
+	}
+	return t.Weekday() != time.Saturday && t.Weekday() != time.Sunday
+}
+
+// 查找工作日时最多向前/向后查找的天数，防止错误数据导致死循环
+const maxWorkdaySearch = 366
+
+// NextWorkday 返回 t 当天或之后的第一个工作日
+func (c *Calendar) NextWorkday(t time.Time) time.Time {
+	for i := 0; i < maxWorkdaySearch && !c.IsWorkday(t); i++ {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t
+}
+
+// PrevWorkday 返回 t 当天或之前的最后一个工作日
+func (c *Calendar) PrevWorkday(t time.Time) time.Time {
+	for i := 0; i < maxWorkdaySearch && !c.IsWorkday(t); i++ {
+		t = t.AddDate(0, 0, -1)
+	}
+	return t
+}
+
+// AddWorkdays 返回 t 之后第 n 个工作日 (n 为负数时向前)，n 为 0 时返回 t 当天或之后的第一个工作日
+// 时刻和时区保持不变
+func (c *Calendar) AddWorkdays(t time.Time, n int) time.Time {
+	if n == 0 {
+		return c.NextWorkday(t)
+	}
+	step := 1
+	if n < 0 {
+		step, n = -1, -n
+	}
+	for n > 0 {
+		t = t.AddDate(0, 0, step)
+		if c.IsWorkday(t) {
+			n--
+		}
+	}
+	return t
+}
@@ -0,0 +1,116 @@
+package calendar
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ParseICS 从 iCalendar 文件中解析节假日安排，按年份分组返回
+//
+// 每个 VEVENT 是一段全天日期 (DTSTART;VALUE=DATE 到 DTEND，DTEND 不含)，
+// SUMMARY 中包含 "班" (如 "春节 补班") 的视为调休上班，其余视为放假，
+// 节日名称取 SUMMARY 中第一个空格之前的部分。
+func ParseICS(data []byte) ([]*Year, error) {
+	lines := unfoldICS(data)
+
+	byYear := map[int]*Year{}
+	var inEvent bool
+	var start, end, summary string
+	for _, line := range lines {
+		switch {
+		case line == "BEGIN:VEVENT":
+			inEvent, start, end, summary = true, "", "", ""
+		case line == "END:VEVENT":
+			inEvent = false
+			if err := addICSEvent(byYear, start, end, summary); err != nil {
+				return nil, err
+			}
+		case inEvent:
+			name, value := splitICSLine(line)
+			switch name {
+			case "DTSTART":
+				start = value
+			case "DTEND":
+				end = value
+			case "SUMMARY":
+				summary = value
+			}
+		}
+	}
+	if len(byYear) == 0 {
+		return nil, errors.New("ICS 文件中没有节假日事件")
+	}
+
+	years := make([]*Year, 0, len(byYear))
+	for _, y := range byYear {
+		sort.Slice(y.Days, func(i, j int) bool { return y.Days[i].Date < y.Days[j].Date })
+		if err := y.Validate(); err != nil {
+			return nil, err
+		}
+		years = append(years, y)
+	}
+	sort.Slice(years, func(i, j int) bool { return years[i].Year < years[j].Year })
+	return years, nil
+}
+
+// unfoldICS 按行拆分并展开折行 (以空格或制表符开头的行是上一行的延续)
+func unfoldICS(data []byte) []string {
+	var lines []string
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// splitICSLine 拆分 "NAME;PARAM=...:VALUE"，返回属性名和值
+func splitICSLine(line string) (string, string) {
+	i := strings.Index(line, ":")
+	if i < 0 {
+		return line, ""
+	}
+	name := line[:i]
+	if j := strings.Index(name, ";"); j >= 0 {
+		name = name[:j]
+	}
+	return strings.ToUpper(name), line[i+1:]
+}
+
+// addICSEvent 将一个事件覆盖的每一天加入对应年份
+func addICSEvent(byYear map[int]*Year, start, end, summary string) error {
+	from, err := time.Parse("20060102", start)
+	if err != nil {
+		return errors.New("ICS 事件必须是全天日期: " + start)
+	}
+	to := from.AddDate(0, 0, 1)
+	if end != "" {
+		if to, err = time.Parse("20060102", end); err != nil {
+			return errors.New("ICS 事件必须是全天日期: " + end)
+		}
+	}
+
+	name := strings.TrimSpace(summary)
+	if i := strings.IndexAny(name, " \t"); i > 0 {
+		name = name[:i]
+	}
+	holiday := !strings.Contains(summary, "班")
+
+	for d := from; d.Before(to); d = d.AddDate(0, 0, 1) {
+		y, ok := byYear[d.Year()]
+		if !ok {
+			y = &Year{Year: d.Year()}
+			byYear[d.Year()] = y
+		}
+		y.Days = append(y.Days, Day{Date: d.Format("2006-01-02"), Name: name, Holiday: holiday})
+	}
+	return nil
+}
@@ -1,10 +1,13 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"os"
+	_ "time/tzdata" // 内置时区数据，精简镜像中没有系统时区库
 	"todolist/handlers"
+	"todolist/jobs"
 	"todolist/models"
 
 	"github.com/gin-contrib/cors"
@@ -33,6 +36,23 @@ func main() {
 	if err := models.InitDB(); err != nil {
 		log.Fatal("数据库连接失败:", err)
 	}
+	// 初始化附件存储
+	if err := models.InitStorage(); err != nil {
+		log.Fatal(err)
+	}
+	// 加载管理员上传的节假日安排，覆盖内置数据
+	if err := models.LoadHolidayCalendars(); err != nil {
+		log.Fatal("加载节假日数据失败:", err)
+	}
+
+	// 启动后台任务
+	if err := jobs.StartReminderScheduler(context.Background()); err != nil {
+		log.Fatal(err)
+	}
+	jobs.StartTrashPurger(context.Background())
+	jobs.StartAutoArchiver(context.Background())
+	jobs.StartPositionRebalancer(context.Background())
+	jobs.StartBlobCleaner(context.Background())
 
 	// 创建Gin引擎
 	r := gin.Default()
@@ -41,7 +61,7 @@ func main() {
 	config := cors.DefaultConfig()
 	config.AllowAllOrigins = true
 	config.AllowMethods = []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"}
-	config.AllowHeaders = []string{"Origin", "Content-Type", "Authorization"}
+	config.AllowHeaders = []string{"Origin", "Content-Type", "Authorization", handlers.WorkspaceHeader}
 	r.Use(cors.New(config))
 
 	// API基础路由组
@@ -57,15 +77,149 @@ func main() {
 		{
 			// 用户相关路由
 			auth.POST("/change-password", handlers.ChangePassword)
+			auth.GET("/settings", handlers.GetSettings)
+			auth.PUT("/settings", handlers.UpdateSettings)
+
+			// 农历日期转换
+			auth.GET("/lunar", handlers.ConvertLunarDate)
+
+			// 工作区管理，路径中指定工作区，不受请求头影响
+			workspaces := auth.Group("/workspaces")
+			{
+				workspaces.GET("", handlers.GetWorkspaces)
+				workspaces.POST("", handlers.CreateWorkspace)
+				workspaces.PUT("/:id", handlers.UpdateWorkspace)
+				workspaces.DELETE("/:id", handlers.DeleteWorkspace)
+				workspaces.GET("/:id/members", handlers.GetWorkspaceMembers)
+				workspaces.POST("/:id/members", handlers.AddWorkspaceMember)
+				workspaces.PUT("/:id/members/:user_id", handlers.UpdateWorkspaceMember)
+				workspaces.DELETE("/:id/members/:user_id", handlers.RemoveWorkspaceMember)
+			}
+
+			// 以下路由的数据属于当前工作区 (请求头 X-Workspace-ID，未指定时为个人工作区)
+			// 只对之后注册的路由生效
+			auth.Use(handlers.WorkspaceMiddleware())
+
+			// 提醒相关路由
+			reminders := auth.Group("/reminders")
+			{
+				reminders.GET("", handlers.GetReminders)
+				reminders.POST("/:id/snooze", handlers.SnoozeReminder)
+				reminders.POST("/:id/dismiss", handlers.DismissReminder)
+				reminders.DELETE("/:id", handlers.DeleteReminder)
+			}
+
+			// 已归档的待办事项
+			auth.GET("/archive", handlers.GetArchivedTodos)
+
+			// 回收站
+			trash := auth.Group("/trash")
+			{
+				trash.GET("", handlers.GetTrash)
+				trash.DELETE("", handlers.EmptyTrash)
+				trash.POST("/:id/restore", handlers.RestoreTodo)
+				trash.DELETE("/:id", handlers.PurgeTrashedTodo)
+			}
+
+			// 时间记录
+			auth.GET("/timer", handlers.GetRunningTimer)
+			timeEntries := auth.Group("/time-entries")
+			{
+				timeEntries.GET("/daily", handlers.GetDailyTimeTotals)
+				timeEntries.GET("/export", handlers.ExportTimeEntries)
+				timeEntries.DELETE("/:id", handlers.DeleteTimeEntry)
+			}
+
+			// 报表
+			auth.GET("/reports/estimates", handlers.GetEstimateReport)
+
+			// 工作日历
+			auth.GET("/calendar/workday", handlers.GetWorkday)
+			auth.GET("/calendar/:year", handlers.GetCalendarYear)
 
 			// Todo相关路由
 			todos := auth.Group("/todos")
 			{
 				todos.GET("", handlers.GetAllTodos)
+				todos.GET("/search", handlers.SearchTodos)
+				todos.GET("/overdue", handlers.GetOverdueTodos)
+				todos.GET("/today", handlers.GetTodayTodos)
+				todos.GET("/upcoming", handlers.GetUpcomingTodos)
+				todos.GET("/export", handlers.ExportTodos)
 				todos.GET("/:id", handlers.GetTodoByID)
 				todos.POST("", handlers.CreateTodo)
 				todos.PUT("/:id", handlers.UpdateTodo)
 				todos.DELETE("/:id", handlers.DeleteTodo)
+				todos.POST("/:id/tags", handlers.AddTodoTags)
+				todos.DELETE("/:id/tags/:tag_id", handlers.RemoveTodoTag)
+				todos.GET("/:id/subtree", handlers.GetTodoSubtree)
+				todos.GET("/:id/progress", handlers.GetTodoProgress)
+				todos.POST("/:id/move", handlers.MoveTodo)
+				todos.POST("/:id/archive", handlers.ArchiveTodo)
+				todos.POST("/:id/unarchive", handlers.UnarchiveTodo)
+				todos.GET("/:id/history", handlers.GetTodoHistory)
+				todos.POST("/:id/revert/:rev", handlers.RevertTodo)
+				todos.GET("/:id/comments", handlers.GetTodoComments)
+				todos.POST("/:id/comments", handlers.CreateTodoComment)
+				todos.PUT("/:id/comments/:comment_id", handlers.UpdateTodoComment)
+				todos.DELETE("/:id/comments/:comment_id", handlers.DeleteTodoComment)
+				todos.GET("/:id/attachments", handlers.GetTodoAttachments)
+				todos.POST("/:id/attachments", handlers.UploadTodoAttachment)
+				todos.GET("/:id/attachments/:attachment_id", handlers.DownloadTodoAttachment)
+				todos.DELETE("/:id/attachments/:attachment_id", handlers.DeleteTodoAttachment)
+				todos.GET("/:id/dependencies", handlers.GetTodoDependencies)
+				todos.POST("/:id/dependencies", handlers.AddTodoDependencies)
+				todos.DELETE("/:id/dependencies/:blocker_id", handlers.RemoveTodoDependency)
+				todos.POST("/:id/timer/start", handlers.StartTodoTimer)
+				todos.POST("/:id/timer/stop", handlers.StopTodoTimer)
+				todos.GET("/:id/time-entries", handlers.GetTodoTimeEntries)
+				todos.POST("/:id/time-entries", handlers.CreateTimeEntry)
+				todos.GET("/:id/recurrence", handlers.GetTodoRecurrence)
+				todos.PUT("/:id/recurrence", handlers.SetTodoRecurrence)
+				todos.DELETE("/:id/recurrence", handlers.DeleteTodoRecurrence)
+				todos.POST("/:id/skip", handlers.SkipTodoOccurrence)
+				todos.GET("/:id/reminders", handlers.GetTodoReminders)
+				todos.POST("/:id/reminders", handlers.CreateTodoReminder)
+			}
+
+			// 项目相关路由
+			projects := auth.Group("/projects")
+			{
+				projects.GET("", handlers.GetProjects)
+				projects.GET("/:id", handlers.GetProject)
+				projects.POST("", handlers.CreateProject)
+				projects.PUT("/:id", handlers.UpdateProject)
+				projects.DELETE("/:id", handlers.DeleteProject)
+				projects.GET("/:id/todos", handlers.GetProjectTodos)
+				projects.GET("/:id/members", handlers.GetProjectMembers)
+				projects.POST("/:id/members", handlers.AddProjectMember)
+				projects.PUT("/:id/members/:user_id", handlers.UpdateProjectMember)
+				projects.DELETE("/:id/members/:user_id", handlers.RemoveProjectMember)
+			}
+
+			// 自定义字段
+			customFields := auth.Group("/custom-fields")
+			{
+				customFields.GET("", handlers.GetCustomFields)
+				customFields.POST("", handlers.CreateCustomField)
+				customFields.PUT("/:id", handlers.UpdateCustomField)
+				customFields.DELETE("/:id", handlers.DeleteCustomField)
+			}
+
+			// 标签相关路由
+			tags := auth.Group("/tags")
+			{
+				tags.GET("", handlers.GetTags)
+				tags.POST("", handlers.CreateTag)
+				tags.PUT("/:id", handlers.UpdateTag)
+				tags.DELETE("/:id", handlers.DeleteTag)
+			}
+
+			// 管理员路由
+			admin := auth.Group("/admin")
+			admin.Use(handlers.AdminMiddleware())
+			{
+				admin.POST("/calendar", handlers.UploadCalendar)
 			}
 		}
 	}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"todolist/models"
+
+	"github.com/joho/godotenv"
+	"gorm.io/gorm"
+)
+
+// 每批处理的待办事项数量
+const batchSize = 500
+
+// tasks 可执行的回填任务，key 为 -task 参数的取值
+var tasks = map[string]func(tx *gorm.DB, todo *models.Todo) error{
+	// 重建全文检索索引
+	"search": models.IndexTodo,
+	// 填充标题拼音列
+	"pinyin": models.FillTodoPinyin,
+	// 计算智能排序分数
+	"score": models.RefreshSmartScore,
+}
+
+func main() {
+	task := flag.String("task", "", "回填任务: search, pinyin 或 score")
+	flag.Parse()
+
+	run, ok := tasks[*task]
+	if !ok {
+		log.Fatalf("未知的回填任务: %q", *task)
+	}
+
+	if err := godotenv.Load(); err != nil {
+		log.Println("未找到 .env 文件，将使用系统环境变量或默认值")
+	}
+	if err := models.InitDB(); err != nil {
+		log.Fatal("数据库连接失败:", err)
+	}
+
+	// 按ID分批遍历，避免一次性加载所有待办事项
+	var lastID uint
+	total := 0
+	for {
+		var todos []models.Todo
+		if err := models.DB.Where("id > ?", lastID).Order("id ASC").Limit(batchSize).Find(&todos).Error; err != nil {
+			log.Fatal("读取待办事项失败:", err)
+		}
+		if len(todos) == 0 {
+			break
+		}
+		err := models.DB.Transaction(func(tx *gorm.DB) error {
+			for i := range todos {
+				if err := run(tx, &todos[i]); err != nil {
+					return fmt.Errorf("处理待办事项 %d 失败: %w", todos[i].ID, err)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			log.Fatal(err)
+		}
+		lastID = todos[len(todos)-1].ID
+		total += len(todos)
+		fmt.Printf("已处理 %d 条待办事项\n", total)
+	}
+	fmt.Printf("回填任务 %s 完成，共处理 %d 条待办事项\n", *task, total)
+}
@@ -10,6 +10,7 @@ require (
 	github.com/go-redis/redis/v8 v8.11.5
 	github.com/golang-jwt/jwt v3.2.2+incompatible
 	github.com/joho/godotenv v1.5.1
+	github.com/mozillazg/go-pinyin v0.21.0
 	golang.org/x/crypto v0.36.0
 	gorm.io/driver/mysql v1.5.4
 	gorm.io/gorm v1.25.7
@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+	"todolist/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// GetArchivedTodos 分页返回已归档的待办事项 (带缓存)，默认最近归档的在前
+// 筛选、排序和分页参数与待办事项列表相同
+func GetArchivedTodos(c *gin.Context) {
+	// 从上下文中获取当前用户ID
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "用户未认证"})
+		return
+	}
+	currentUserID := userID.(uint)
+
+	listQuery, err := parseTodoListQuery(c, "-archived_at")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	listQuery.Archived = true
+
+	listTodos(c, currentUserID, listQuery)
+}
+
+// ArchiveTodo 归档待办事项，子任务一并归档 (带缓存清除)
+func ArchiveTodo(c *gin.Context) {
+	changeArchiveState(c, func(tx *gorm.DB, actorID uint, todo *models.Todo) ([]uint, error) {
+		return models.ArchiveTodo(tx, actorID, todo, time.Now())
+	})
+}
+
+// UnarchiveTodo 取消归档，同一次归档的子任务一并取消 (带缓存清除)
+func UnarchiveTodo(c *gin.Context) {
+	changeArchiveState(c, models.UnarchiveTodo)
+}
+
+// changeArchiveState 在事务中归档或取消归档待办事项 (需要 editor 角色)，并清除受影响的缓存
+func changeArchiveState(c *gin.Context, change func(tx *gorm.DB, actorID uint, todo *models.Todo) ([]uint, error)) {
+	// 从上下文中获取当前用户ID
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "用户未认证"})
+		return
+	}
+	currentUserID := userID.(uint)
+
+	todo, ok := loadAccessibleTodo(c, currentUserID, models.RoleEditor)
+	if !ok {
+		return
+	}
+
+	var affectedIDs []uint
+	err := models.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if affectedIDs, err = change(tx, currentUserID, todo); err != nil {
+			return err
+		}
+		return tx.Preload("Tags").First(todo, todo.ID).Error
+	})
+	if errors.Is(err, models.ErrAlreadyArchived) || errors.Is(err, models.ErrNotArchived) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "更新归档状态失败"})
+		return
+	}
+
+	// --- 清除相关缓存 ---
+	clearSharedCache(todo.UserID, todo.ProjectID)
+	for _, id := range affectedIDs {
+		clearTodoCache(id)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"todo": todo, "affected": len(affectedIDs)})
+}
@@ -0,0 +1,235 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"todolist/models"
+	"todolist/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 附件文件名的最大字符数
+const maxFilenameRunes = 255
+
+// multipart 请求中除文件内容外其他部分 (边界、字段头等) 允许的大小
+const multipartOverhead = 1 << 20
+
+// loadAttachment 查找待办事项下的附件，未找到时写入 404 响应
+func loadAttachment(c *gin.Context, todoID uint) (*models.Attachment, bool) {
+	var attachment models.Attachment
+	if err := models.DB.Where("id = ? AND todo_id = ?", c.Param("attachment_id"), todoID).First(&attachment).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "附件未找到"})
+		return nil, false
+	}
+	return &attachment, true
+}
+
+// cleanFilename 去掉客户端文件名中的路径部分并限制长度
+func cleanFilename(name string) string {
+	name = strings.TrimSpace(filepath.Base(strings.ReplaceAll(name, "\\", "/")))
+	if name == "" || name == "." || name == ".." || name == "/" {
+		return "attachment"
+	}
+	if runes := []rune(name); len(runes) > maxFilenameRunes {
+		name = string(runes[len(runes)-maxFilenameRunes:])
+	}
+	return name
+}
+
+// GetTodoAttachments 返回待办事项的附件列表，按上传先后排列
+func GetTodoAttachments(c *gin.Context) {
+	// 从上下文中获取当前用户ID
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "用户未认证"})
+		return
+	}
+	currentUserID := userID.(uint)
+
+	todo, ok := loadAccessibleTodo(c, currentUserID, models.RoleViewer)
+	if !ok {
+		return
+	}
+	var attachments []models.Attachment
+	if err := models.DB.Where("todo_id = ?", todo.ID).Order("id ASC").Find(&attachments).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取附件失败"})
+		return
+	}
+	c.JSON(http.StatusOK, attachments)
+}
+
+// UploadTodoAttachment 通过 multipart/form-data 的 file 字段上传附件
+// 类型按文件内容识别；内容按 SHA-256 去重，已保存过的内容不会重复写入存储
+func UploadTodoAttachment(c *gin.Context) {
+	// 从上下文中获取当前用户ID
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "用户未认证"})
+		return
+	}
+	currentUserID := userID.(uint)
+
+	todo, ok := loadAccessibleTodo(c, currentUserID, models.RoleEditor)
+	if !ok {
+		return
+	}
+
+	maxBytes := models.AttachmentMaxBytes()
+	tooLarge := fmt.Sprintf("附件不能超过 %d MB", maxBytes>>20)
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxBytes+multipartOverhead)
+	header, err := c.FormFile("file")
+	if err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": tooLarge})
+		} else {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "请通过 file 字段上传文件"})
+		}
+		return
+	}
+	if header.Size > maxBytes {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": tooLarge})
+		return
+	}
+	if header.Size == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "不能上传空文件"})
+		return
+	}
+	file, err := header.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "读取上传文件失败"})
+		return
+	}
+	defer file.Close()
+
+	// 识别类型只需要前 512 字节
+	head := make([]byte, 512)
+	n, err := io.ReadFull(file, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "读取上传文件失败"})
+		return
+	}
+	contentType := http.DetectContentType(head[:n])
+	if mediaType, _, _ := mime.ParseMediaType(contentType); !models.AttachmentTypes[mediaType] {
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": fmt.Sprintf("不支持的附件类型: %s", mediaType)})
+		return
+	}
+
+	hash := sha256.New()
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "上传附件失败"})
+		return
+	}
+	if _, err := io.Copy(hash, file); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "读取上传文件失败"})
+		return
+	}
+	sum := hex.EncodeToString(hash.Sum(nil))
+
+	// 先登记再写入，清理任务不会删除刚登记的内容；清理任务正在删除同一内容时，登记会等待删除完成
+	if err := models.TouchBlob(sum, header.Size, contentType); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "上传附件失败"})
+		return
+	}
+	stored, err := models.Blobs.Exists(c.Request.Context(), sum)
+	if err == nil && !stored {
+		if _, err = file.Seek(0, io.SeekStart); err == nil {
+			err = models.Blobs.Put(c.Request.Context(), sum, file, header.Size, contentType)
+		}
+	}
+	if err != nil {
+		fmt.Printf("Store attachment %s error: %v\n", sum, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "上传附件失败"})
+		return
+	}
+
+	attachment := models.Attachment{
+		TodoID:      todo.ID,
+		UserID:      currentUserID,
+		Filename:    cleanFilename(header.Filename),
+		ContentType: contentType,
+		Size:        header.Size,
+		Hash:        sum,
+	}
+	if err := models.DB.Create(&attachment).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "上传附件失败"})
+		return
+	}
+	c.JSON(http.StatusCreated, attachment)
+}
+
+// DownloadTodoAttachment 下载附件内容
+func DownloadTodoAttachment(c *gin.Context) {
+	// 从上下文中获取当前用户ID
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "用户未认证"})
+		return
+	}
+	currentUserID := userID.(uint)
+
+	todo, ok := loadAccessibleTodo(c, currentUserID, models.RoleViewer)
+	if !ok {
+		return
+	}
+	attachment, ok := loadAttachment(c, todo.ID)
+	if !ok {
+		return
+	}
+
+	content, err := models.Blobs.Open(c.Request.Context(), attachment.Hash)
+	if errors.Is(err, storage.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "下载附件失败"})
+		return
+	}
+	defer content.Close()
+
+	// 始终作为下载返回，并禁止浏览器重新猜测类型
+	c.DataFromReader(http.StatusOK, attachment.Size, attachment.ContentType, content, map[string]string{
+		"Content-Disposition":    mime.FormatMediaType("attachment", map[string]string{"filename": attachment.Filename}),
+		"X-Content-Type-Options": "nosniff",
+		"ETag":                   `"` + attachment.Hash + `"`,
+	})
+}
+
+// DeleteTodoAttachment 删除附件，上传者和待办事项所有者可以删除
+// 内容不再被引用后由后台任务清理
+func DeleteTodoAttachment(c *gin.Context) {
+	// 从上下文中获取当前用户ID
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "用户未认证"})
+		return
+	}
+	currentUserID := userID.(uint)
+
+	todo, ok := loadAccessibleTodo(c, currentUserID, models.RoleEditor)
+	if !ok {
+		return
+	}
+	attachment, ok := loadAttachment(c, todo.ID)
+	if !ok {
+		return
+	}
+	if attachment.UserID != currentUserID && !isTodoOwner(currentUserID, todo) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "无权删除该附件"})
+		return
+	}
+
+	if err := models.DB.Delete(attachment).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "删除附件失败"})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
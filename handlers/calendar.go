@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"io"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"todolist/calendar"
+	"todolist/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// 上传日历文件的大小上限
+	maxCalendarUploadBytes = 1 << 20
+	// 工作日推算的最大天数
+	maxWorkdayOffset = 366
+)
+
+// GetCalendarYear 返回某年的节假日和调休安排
+func GetCalendarYear(c *gin.Context) {
+	year, err := strconv.Atoi(c.Param("year"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的年份"})
+		return
+	}
+	y, ok := calendar.Default().Year(year)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "该年份没有节假日数据，按周一至周五为工作日处理"})
+		return
+	}
+	c.JSON(http.StatusOK, y)
+}
+
+// GetWorkday 推算工作日：?date=YYYY-MM-DD&add=N 返回 date 之后第 N 个工作日 (N 可为负数)
+// 不传 add 时只判断 date 是否为工作日
+func GetWorkday(c *gin.Context) {
+	date, err := time.Parse("2006-01-02", c.Query("date"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "date 必须为 YYYY-MM-DD"})
+		return
+	}
+	cal := calendar.Default()
+	resp := gin.H{"date": date.Format("2006-01-02"), "workday": cal.IsWorkday(date)}
+	if d, ok := cal.Lookup(date); ok {
+		resp["name"] = d.Name
+	}
+	if raw := c.Query("add"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < -maxWorkdayOffset || n > maxWorkdayOffset {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "add 必须为 -366 到 366 之间的整数"})
+			return
+		}
+		resp["result"] = cal.AddWorkdays(date, n).Format("2006-01-02")
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// UploadCalendar 管理员上传节假日安排，支持 JSON (单年) 和 ICS (可包含多年) 两种格式
+// 可以直接以请求体上传 (Content-Type 为 application/json 或 text/calendar)，
+// 也可以用 multipart/form-data 的 file 字段上传 .json/.ics 文件
+func UploadCalendar(c *gin.Context) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxCalendarUploadBytes)
+
+	format, _, _ := mime.ParseMediaType(c.GetHeader("Content-Type"))
+	var data []byte
+	var err error
+	if format == "multipart/form-data" {
+		file, header, ferr := c.Request.FormFile("file")
+		if ferr != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "缺少 file 字段"})
+			return
+		}
+		defer file.Close()
+		switch strings.ToLower(filepath.Ext(header.Filename)) {
+		case ".json":
+			format = "application/json"
+		case ".ics":
+			format = "text/calendar"
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{"error": "仅支持 .json 或 .ics 文件"})
+			return
+		}
+		data, err = io.ReadAll(file)
+	} else {
+		data, err = io.ReadAll(c.Request.Body)
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "读取上传内容失败或文件过大"})
+		return
+	}
+
+	var years []*calendar.Year
+	switch format {
+	case "application/json":
+		y, err := calendar.ParseJSON(data)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		years = []*calendar.Year{y}
+	case "text/calendar":
+		years, err = calendar.ParseICS(data)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	default:
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "仅支持 application/json 或 text/calendar"})
+		return
+	}
+
+	if err := models.SaveHolidayCalendars(years, c.GetString("username")); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "保存节假日数据失败"})
+		return
+	}
+
+	loaded := make([]int, len(years))
+	for i, y := range years {
+		loaded[i] = y.Year
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "节假日数据已更新", "years": loaded})
+}
@@ -0,0 +1,225 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+	"todolist/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// 评论内容的最大字符数
+const maxCommentRunes = 5000
+
+// 评论列表游标的排序标识
+const commentCursorSort = "comments"
+
+// CommentRequest 发表/编辑评论请求结构
+type CommentRequest struct {
+	Body string `json:"body"`
+}
+
+// validate 校验并规范化评论内容
+func (req *CommentRequest) validate() error {
+	req.Body = strings.TrimSpace(req.Body)
+	if req.Body == "" {
+		return fmt.Errorf("评论内容不能为空")
+	}
+	if len([]rune(req.Body)) > maxCommentRunes {
+		return fmt.Errorf("评论内容不能超过 %d 个字符", maxCommentRunes)
+	}
+	return nil
+}
+
+// commentPage 评论列表的分页响应
+type commentPage struct {
+	Comments   []models.Comment `json:"comments"`
+	NextCursor string           `json:"next_cursor"`
+}
+
+// loadComment 查找待办事项下的评论，未找到时写入 404 响应
+func loadComment(c *gin.Context, todoID uint) (*models.Comment, bool) {
+	var comment models.Comment
+	if err := models.DB.Where("id = ? AND todo_id = ?", c.Param("comment_id"), todoID).First(&comment).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "评论未找到"})
+		return nil, false
+	}
+	return &comment, true
+}
+
+// GetTodoComments 分页返回待办事项的评论，按发表时间先后排列
+func GetTodoComments(c *gin.Context) {
+	// 从上下文中获取当前用户ID
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "用户未认证"})
+		return
+	}
+	currentUserID := userID.(uint)
+
+	todo, ok := loadAccessibleTodo(c, currentUserID, models.RoleViewer)
+	if !ok {
+		return
+	}
+	page, err := parsePageParams(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	query := models.DB.Where("todo_id = ?", todo.ID)
+	if page.Cursor != nil {
+		if page.Cursor.Sort != commentCursorSort {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "无效的分页游标"})
+			return
+		}
+		query = query.Where("id > ?", page.Cursor.ID)
+	}
+
+	var comments []models.Comment
+	// 多取一条用于判断是否还有下一页
+	if err := query.Order("id ASC").Limit(page.Limit + 1).Find(&comments).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取评论失败"})
+		return
+	}
+
+	resp := commentPage{Comments: comments}
+	if len(comments) > page.Limit {
+		resp.Comments = comments[:page.Limit]
+		resp.NextCursor = encodeCursor(pageCursor{ID: resp.Comments[page.Limit-1].ID, Sort: commentCursorSort})
+	}
+	setNextLink(c, resp.NextCursor)
+	c.JSON(http.StatusOK, resp)
+}
+
+// CreateTodoComment 在待办事项下发表评论 (带缓存清除)
+// 发表评论视为对待办事项的更新，同时刷新其 updated_at
+func CreateTodoComment(c *gin.Context) {
+	// 从上下文中获取当前用户ID
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "用户未认证"})
+		return
+	}
+	currentUserID := userID.(uint)
+
+	todo, ok := loadAccessibleTodo(c, currentUserID, models.RoleEditor)
+	if !ok {
+		return
+	}
+	var req CommentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求数据"})
+		return
+	}
+	if err := req.validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	comment := models.Comment{TodoID: todo.ID, UserID: currentUserID, Body: req.Body}
+	err := models.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&comment).Error; err != nil {
+			return err
+		}
+		return tx.Model(todo).Update("updated_at", time.Now()).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "发表评论失败"})
+		return
+	}
+
+	// --- 清除相关缓存 ---
+	clearSharedCache(todo.UserID, todo.ProjectID) // updated_at 变化影响列表排序
+	clearTodoCache(todo.ID)                       // 详情中的评论数变化
+
+	c.JSON(http.StatusCreated, comment)
+}
+
+// UpdateTodoComment 编辑评论，只有作者可以编辑，记录编辑时间和次数
+func UpdateTodoComment(c *gin.Context) {
+	// 从上下文中获取当前用户ID
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "用户未认证"})
+		return
+	}
+	currentUserID := userID.(uint)
+
+	todo, ok := loadAccessibleTodo(c, currentUserID, models.RoleEditor)
+	if !ok {
+		return
+	}
+	comment, ok := loadComment(c, todo.ID)
+	if !ok {
+		return
+	}
+	if comment.UserID != currentUserID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "只能编辑自己的评论"})
+		return
+	}
+	var req CommentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求数据"})
+		return
+	}
+	if err := req.validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	// 内容没有变化时不算一次编辑
+	if req.Body == comment.Body {
+		c.JSON(http.StatusOK, comment)
+		return
+	}
+
+	err := models.DB.Model(comment).Updates(map[string]interface{}{
+		"body":       req.Body,
+		"edited_at":  time.Now(),
+		"edit_count": gorm.Expr("edit_count + 1"),
+	}).Error
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "编辑评论失败"})
+		return
+	}
+
+	models.DB.First(comment, comment.ID)
+	c.JSON(http.StatusOK, comment)
+}
+
+// DeleteTodoComment 删除评论，评论作者和待办事项所有者可以删除 (带缓存清除)
+func DeleteTodoComment(c *gin.Context) {
+	// 从上下文中获取当前用户ID
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "用户未认证"})
+		return
+	}
+	currentUserID := userID.(uint)
+
+	todo, ok := loadAccessibleTodo(c, currentUserID, models.RoleEditor)
+	if !ok {
+		return
+	}
+	comment, ok := loadComment(c, todo.ID)
+	if !ok {
+		return
+	}
+	if comment.UserID != currentUserID && !isTodoOwner(currentUserID, todo) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "无权删除该评论"})
+		return
+	}
+
+	if err := models.DB.Delete(comment).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "删除评论失败"})
+		return
+	}
+
+	// --- 清除相关缓存 ---
+	clearTodoCache(todo.ID) // 详情中的评论数变化
+
+	c.Status(http.StatusNoContent)
+}
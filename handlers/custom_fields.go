@@ -0,0 +1,197 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"todolist/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// CustomFieldRequest 创建/修改自定义字段的请求结构，修改时不传的字段保持不变，类型不能修改
+type CustomFieldRequest struct {
+	Name    *string   `json:"name"`
+	Type    string    `json:"type"`
+	Options *[]string `json:"options"`
+}
+
+// customFieldNameTaken 检查用户在工作区中是否已有同名字段，excludeID 为正在修改的字段
+func customFieldNameTaken(userID, workspaceID uint, name string, excludeID uint) bool {
+	var count int64
+	models.DB.Model(&models.CustomField{}).Where("user_id = ? AND workspace_id = ? AND name = ? AND id <> ?", userID, workspaceID, name, excludeID).Count(&count)
+	return count > 0
+}
+
+// loadOwnCustomField 加载当前用户在当前工作区中定义的、路径参数 id 指定的字段
+func loadOwnCustomField(c *gin.Context, userID uint, field *models.CustomField) error {
+	return models.DB.Where("id = ? AND user_id = ? AND workspace_id = ?", c.Param("id"), userID, c.GetUint("workspace_id")).First(field).Error
+}
+
+// GetCustomFields 返回当前用户在当前工作区中定义的自定义字段，按创建顺序
+func GetCustomFields(c *gin.Context) {
+	// 从上下文中获取当前用户ID
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "用户未认证"})
+		return
+	}
+	currentUserID := userID.(uint)
+
+	fields, err := models.LoadCustomFields(models.DB, currentUserID, c.GetUint("workspace_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取自定义字段失败"})
+		return
+	}
+	c.JSON(http.StatusOK, fields)
+}
+
+// CreateCustomField 在当前工作区中定义新的自定义字段
+func CreateCustomField(c *gin.Context) {
+	// 从上下文中获取当前用户ID
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "用户未认证"})
+		return
+	}
+	currentUserID := userID.(uint)
+
+	var req CustomFieldRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求数据"})
+		return
+	}
+	field := models.CustomField{UserID: currentUserID, WorkspaceID: c.GetUint("workspace_id"), Type: req.Type}
+	if req.Name != nil {
+		field.Name = *req.Name
+	}
+	if req.Options != nil {
+		field.Options = *req.Options
+	}
+	if err := field.Normalize(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if customFieldNameTaken(currentUserID, field.WorkspaceID, field.Name, 0) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "字段名已存在"})
+		return
+	}
+	var count int64
+	if err := models.DB.Model(&models.CustomField{}).Where("user_id = ? AND workspace_id = ?", currentUserID, field.WorkspaceID).Count(&count).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "创建自定义字段失败"})
+		return
+	}
+	if count >= models.MaxCustomFields {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("最多定义 %d 个自定义字段", models.MaxCustomFields)})
+		return
+	}
+
+	if err := models.DB.Create(&field).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "创建自定义字段失败"})
+		return
+	}
+	c.JSON(http.StatusCreated, field)
+}
+
+// UpdateCustomField 修改自定义字段的名称或选项
+// 选项按新列表整体替换，仍被待办事项使用的选项不能移除
+func UpdateCustomField(c *gin.Context) {
+	// 从上下文中获取当前用户ID
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "用户未认证"})
+		return
+	}
+	currentUserID := userID.(uint)
+
+	var field models.CustomField
+	if err := loadOwnCustomField(c, currentUserID, &field); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "自定义字段未找到或无权修改"})
+		return
+	}
+	var req CustomFieldRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求数据"})
+		return
+	}
+	if req.Type != "" && req.Type != field.Type {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "字段类型不能修改"})
+		return
+	}
+	oldOptions := field.Options
+	if req.Name != nil {
+		field.Name = *req.Name
+	}
+	if req.Options != nil {
+		field.Options = *req.Options
+	}
+	if err := field.Normalize(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if customFieldNameTaken(currentUserID, field.WorkspaceID, field.Name, field.ID) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "字段名已存在"})
+		return
+	}
+
+	kept := make(map[string]bool, len(field.Options))
+	for _, o := range field.Options {
+		kept[o] = true
+	}
+	var removed []string
+	for _, o := range oldOptions {
+		if !kept[o] {
+			removed = append(removed, o)
+		}
+	}
+	inUse, err := models.CustomOptionsInUse(models.DB, &field, removed)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "修改自定义字段失败"})
+		return
+	}
+	if len(inUse) > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "选项仍在使用中，不能移除", "options": inUse})
+		return
+	}
+	if err := models.DB.Model(&field).Select("name", "options").Updates(&field).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "修改自定义字段失败"})
+		return
+	}
+	c.JSON(http.StatusOK, field)
+}
+
+// DeleteCustomField 删除自定义字段及所有待办事项上的值 (带缓存清除)
+func DeleteCustomField(c *gin.Context) {
+	// 从上下文中获取当前用户ID
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "用户未认证"})
+		return
+	}
+	currentUserID := userID.(uint)
+
+	var field models.CustomField
+	if err := loadOwnCustomField(c, currentUserID, &field); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "自定义字段未找到或无权删除"})
+		return
+	}
+	var todoIDs []uint
+	err := models.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		todoIDs, err = models.DeleteCustomField(tx, &field)
+		return err
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "删除自定义字段失败"})
+		return
+	}
+
+	// --- 清除相关缓存 ---
+	clearUserCache(currentUserID)
+	clearTodoMembersCache(todoIDs)
+	for _, id := range todoIDs {
+		clearTodoCache(id)
+	}
+
+	c.Status(http.StatusNoContent)
+}
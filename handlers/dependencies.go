@@ -0,0 +1,204 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"todolist/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// 一次请求最多添加的前置任务数
+const maxBlockersPerRequest = 100
+
+// clearDependentsCache 待办事项的完成或删除状态变化后，被它们阻塞的待办事项的 blocked/actionable 随之变化
+// 依赖可以跨项目，递增这些待办事项的所有者和所在共享项目成员的列表缓存版本号
+func clearDependentsCache(blockerIDs []uint) {
+	dependentIDs, err := models.DependentIDs(models.DB, blockerIDs)
+	if err != nil {
+		fmt.Printf("Load dependents error for %d todos: %v\n", len(blockerIDs), err)
+		return
+	}
+	if len(dependentIDs) == 0 {
+		return
+	}
+	var ownerIDs []uint
+	if err := models.DB.Model(&models.Todo{}).Where("id IN ?", dependentIDs).Distinct().Pluck("user_id", &ownerIDs).Error; err != nil {
+		fmt.Printf("Load dependent owners error: %v\n", err)
+	}
+	for _, id := range ownerIDs {
+		clearUserCache(id)
+	}
+	clearTodoMembersCache(dependentIDs)
+}
+
+// DependenciesRequest 添加前置任务的请求结构
+type DependenciesRequest struct {
+	BlockerIDs []uint `json:"blocker_ids" binding:"required"`
+}
+
+// dependencyResponse 返回待办事项的依赖状态、前置任务 (blocked_by) 和被它阻塞的待办事项 (blocking)
+// 只列出当前用户能看到的待办事项，共享项目的成员看不到所有者的私有待办事项
+func dependencyResponse(c *gin.Context, status int, userID uint, todo *models.Todo) {
+	var blockedBy, blocking []models.Todo
+	blockerIDs := models.DB.Model(&models.TodoDependency{}).Select("blocker_id").Where("todo_id = ?", todo.ID)
+	blockedIDs := models.DB.Model(&models.TodoDependency{}).Select("todo_id").Where("blocker_id = ?", todo.ID)
+	err := models.VisibleTodos(models.DB, userID, todo.WorkspaceID).Where("id IN (?)", blockerIDs).Order("id ASC").Find(&blockedBy).Error
+	if err == nil {
+		err = models.VisibleTodos(models.DB, userID, todo.WorkspaceID).Where("id IN (?)", blockedIDs).Order("id ASC").Find(&blocking).Error
+	}
+	if err == nil {
+		err = models.FillDependencyFlags(models.DB, blockedBy)
+	}
+	if err == nil {
+		err = models.FillDependencyFlags(models.DB, blocking)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取依赖失败"})
+		return
+	}
+	fillDependencyFlags(todo)
+	c.JSON(status, gin.H{
+		"todo_id":    todo.ID,
+		"blocked":    todo.Blocked,
+		"actionable": todo.Actionable,
+		"blocked_by": blockedBy,
+		"blocking":   blocking,
+	})
+}
+
+// visibleTodoIDs 从 ids 中筛选出当前用户在工作区中能看到的待办事项ID，按ID排序
+// 用于在响应中列出其他待办事项的ID时，不泄露共享项目所有者的私有待办事项
+func visibleTodoIDs(userID, workspaceID uint, ids []uint) ([]uint, error) {
+	visible := []uint{}
+	if len(ids) == 0 {
+		return visible, nil
+	}
+	err := models.VisibleTodos(models.DB, userID, workspaceID).Where("id IN ?", ids).Order("id ASC").Pluck("id", &visible).Error
+	return visible, err
+}
+
+// GetTodoDependencies 返回待办事项的前置任务和被它阻塞的待办事项
+// 回收站中的待办事项不会出现在结果中
+func GetTodoDependencies(c *gin.Context) {
+	// 从上下文中获取当前用户ID
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "用户未认证"})
+		return
+	}
+	currentUserID := userID.(uint)
+
+	todo, ok := loadAccessibleTodo(c, currentUserID, models.RoleViewer)
+	if !ok {
+		return
+	}
+	dependencyResponse(c, http.StatusOK, currentUserID, todo)
+}
+
+// AddTodoDependencies 为待办事项添加前置任务 (带缓存清除)，会形成循环依赖时拒绝
+func AddTodoDependencies(c *gin.Context) {
+	// 从上下文中获取当前用户ID
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "用户未认证"})
+		return
+	}
+	currentUserID := userID.(uint)
+
+	todo, ok := loadAccessibleTodo(c, currentUserID, models.RoleEditor)
+	if !ok {
+		return
+	}
+	var req DependenciesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求数据"})
+		return
+	}
+	if len(req.BlockerIDs) > maxBlockersPerRequest {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("一次最多添加 %d 个前置任务", maxBlockersPerRequest)})
+		return
+	}
+	// 前置任务必须与待办事项属于同一所有者、当前用户能看到且不在回收站中
+	blockerIDs := dedupeIDs(req.BlockerIDs)
+	var count int64
+	if len(blockerIDs) > 0 {
+		if err := models.VisibleTodos(models.DB.Model(&models.Todo{}), currentUserID, todo.WorkspaceID).
+			Where("id IN ? AND user_id = ?", blockerIDs, todo.UserID).Count(&count).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "添加依赖失败"})
+			return
+		}
+	}
+	if int(count) != len(blockerIDs) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "前置任务不存在或无权使用"})
+		return
+	}
+
+	err := models.DB.Transaction(func(tx *gorm.DB) error {
+		return models.AddDependencies(tx, todo.UserID, todo.ID, blockerIDs)
+	})
+	if errors.Is(err, models.ErrDependencyCycle) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "添加依赖失败"})
+		return
+	}
+
+	// --- 清除相关缓存 ---
+	clearSharedCache(todo.UserID, todo.ProjectID) // 列表中的依赖状态变化
+
+	dependencyResponse(c, http.StatusOK, currentUserID, todo)
+}
+
+// RemoveTodoDependency 移除待办事项的某个前置任务 (带缓存清除)
+func RemoveTodoDependency(c *gin.Context) {
+	// 从上下文中获取当前用户ID
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "用户未认证"})
+		return
+	}
+	currentUserID := userID.(uint)
+
+	todo, ok := loadAccessibleTodo(c, currentUserID, models.RoleEditor)
+	if !ok {
+		return
+	}
+	blockerID, err := strconv.ParseUint(c.Param("blocker_id"), 10, 64)
+	if err != nil || blockerID == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的前置任务ID"})
+		return
+	}
+
+	result := models.DB.Where("todo_id = ? AND blocker_id = ?", todo.ID, blockerID).Delete(&models.TodoDependency{})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "移除依赖失败"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "依赖不存在"})
+		return
+	}
+
+	// --- 清除相关缓存 ---
+	clearSharedCache(todo.UserID, todo.ProjectID)
+
+	c.Status(http.StatusNoContent)
+}
+
+// dedupeIDs 去掉重复的ID，保持原有顺序
+func dedupeIDs(ids []uint) []uint {
+	seen := make(map[uint]bool, len(ids))
+	out := make([]uint, 0, len(ids))
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			out = append(out, id)
+		}
+	}
+	return out
+}
@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"todolist/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TestDependentFlagsRefreshAcrossProjects 前置任务完成或删除后，只能看到被阻塞的待办事项的成员列表中依赖状态随之更新
+func TestDependentFlagsRefreshAcrossProjects(t *testing.T) {
+	requireTestDB(t)
+	router := testRouter()
+	ownerID, _ := createTestUser(t, "owner")
+	memberID, _ := createTestUser(t, "member")
+	var member models.User
+	models.DB.First(&member, memberID)
+
+	owner := testClient{t: t, router: router, userID: ownerID}
+	var team models.Workspace
+	owner.mustDo(http.StatusCreated, "POST", "/api/workspaces", gin.H{"name": "依赖"}, &team)
+	owner.mustDo(http.StatusCreated, "POST", fmt.Sprintf("/api/workspaces/%d/members", team.ID), gin.H{"username": member.Username, "role": models.WorkspaceRoleMember}, nil)
+	owner = owner.in(team.ID)
+	memberClient := testClient{t: t, router: router, userID: memberID, workspaceID: team.ID}
+
+	// 前置任务在成员看不到的项目中，被阻塞的待办事项在共享给成员的项目中
+	var private, shared models.Project
+	owner.mustDo(http.StatusCreated, "POST", "/api/projects", gin.H{"name": "私有项目"}, &private)
+	owner.mustDo(http.StatusCreated, "POST", "/api/projects", gin.H{"name": "共享项目"}, &shared)
+	owner.mustDo(http.StatusCreated, "POST", fmt.Sprintf("/api/projects/%d/members", shared.ID), gin.H{"username": member.Username, "role": models.RoleViewer}, nil)
+	var blocker, dependent models.Todo
+	owner.mustDo(http.StatusCreated, "POST", "/api/todos", gin.H{"todo": gin.H{"title": "前置任务", "project_id": private.ID}}, &blocker)
+	owner.mustDo(http.StatusCreated, "POST", "/api/todos", gin.H{"todo": gin.H{"title": "后续任务", "project_id": shared.ID}}, &dependent)
+	owner.mustDo(http.StatusOK, "POST", fmt.Sprintf("/api/todos/%d/dependencies", dependent.ID), gin.H{"blocker_ids": []uint{blocker.ID}}, nil)
+
+	// blocked 返回成员列表中被阻塞的待办事项的依赖状态，actionable 返回它是否出现在 ?actionable=true 中
+	state := func() (blocked, actionable bool) {
+		t.Helper()
+		var page todoPage
+		memberClient.mustDo(http.StatusOK, "GET", "/api/todos", nil, &page)
+		found := false
+		for _, todo := range page.Todos {
+			if todo.ID == dependent.ID {
+				found = true
+				blocked = todo.Blocked != nil && *todo.Blocked
+			}
+		}
+		if !found {
+			t.Fatal("成员的列表中没有共享项目中的待办事项")
+		}
+		memberClient.mustDo(http.StatusOK, "GET", "/api/todos?actionable=true", nil, &page)
+		return blocked, containsTodo(page.Todos, dependent.ID)
+	}
+
+	if blocked, actionable := state(); !blocked || actionable {
+		t.Fatalf("添加依赖后 blocked=%v actionable=%v", blocked, actionable)
+	}
+	blockerPath := fmt.Sprintf("/api/todos/%d", blocker.ID)
+	owner.mustDo(http.StatusOK, "PUT", blockerPath, gin.H{"completed": true}, nil)
+	if blocked, actionable := state(); blocked || !actionable {
+		t.Errorf("完成前置任务后 blocked=%v actionable=%v", blocked, actionable)
+	}
+	owner.mustDo(http.StatusOK, "PUT", blockerPath, gin.H{"completed": false}, nil)
+	if blocked, actionable := state(); !blocked || actionable {
+		t.Errorf("重新打开前置任务后 blocked=%v actionable=%v", blocked, actionable)
+	}
+	owner.mustDo(http.StatusNoContent, "DELETE", blockerPath, nil, nil)
+	if blocked, actionable := state(); blocked || !actionable {
+		t.Errorf("删除前置任务后 blocked=%v actionable=%v", blocked, actionable)
+	}
+	owner.mustDo(http.StatusOK, "POST", fmt.Sprintf("/api/trash/%d/restore", blocker.ID), nil, nil)
+	if blocked, actionable := state(); !blocked || actionable {
+		t.Errorf("恢复前置任务后 blocked=%v actionable=%v", blocked, actionable)
+	}
+}
@@ -0,0 +1,213 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+	"todolist/calendar"
+	"todolist/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// 即将到期视图默认和最大的天数
+const (
+	defaultUpcomingDays = 7
+	maxUpcomingDays     = 90
+)
+
+// dueView 按截止时间计算的视图 (逾期、今天、即将到期)
+// 视图只包含未完成的待办事项，结果依赖当前时间和用户时区
+type dueView struct {
+	name  string
+	key   string // 写入缓存Key的规范化表示，包含时区和计算基准
+	scope func(*gorm.DB) *gorm.DB
+}
+
+// localDay 返回 now 在 loc 时区下的日期对应的 UTC 零点 (全天待办的存储形式)
+// 以及该日期在 loc 时区下的开始时间
+func localDay(now time.Time, loc *time.Location, offsetDays int) (dateUTC, start time.Time) {
+	y, m, d := now.In(loc).Date()
+	return time.Date(y, m, d+offsetDays, 0, 0, 0, 0, time.UTC),
+		time.Date(y, m, d+offsetDays, 0, 0, 0, 0, loc)
+}
+
+// overdueView 逾期：定时待办的截止时间早于当前时间，全天待办的日期早于今天
+func overdueView(now time.Time, loc *time.Location) *dueView {
+	today, _ := localDay(now, loc, 0)
+	// 逾期的边界随时间推移，按分钟缓存
+	key := fmt.Sprintf("overdue|%s|%s", loc.String(), now.UTC().Truncate(time.Minute).Format(time.RFC3339))
+	return overdueBefore(key, now, today)
+}
+
+// workdayOverdueView 按工作日计算的逾期：在休息日 (周末、节假日) 到期的待办顺延到下一个工作日，
+// 因此今天是休息日时，上一个工作日之后到期的待办都还不算逾期；今天是工作日时与 overdueView 相同
+func workdayOverdueView(now time.Time, loc *time.Location, cal *calendar.Calendar) *dueView {
+	today, _ := localDay(now, loc, 0)
+	if cal.IsWorkday(today) {
+		return overdueView(now, loc)
+	}
+	lastWorkday := cal.PrevWorkday(today.AddDate(0, 0, -1))
+	offset := int(lastWorkday.Sub(today).Hours()/24) + 1
+	cutoffDate, cutoffStart := localDay(now, loc, offset)
+	key := fmt.Sprintf("overdue:workday|%s|%s|cal%d", loc.String(), cutoffDate.Format("2006-01-02"), cal.Version())
+	return overdueBefore(key, cutoffStart, cutoffDate)
+}
+
+// overdueBefore 未完成，且定时待办的截止时间早于 timedCutoff、全天待办的日期早于 dateCutoff
+func overdueBefore(key string, timedCutoff, dateCutoff time.Time) *dueView {
+	return &dueView{
+		name: "overdue",
+		key:  key,
+		scope: func(db *gorm.DB) *gorm.DB {
+			return db.Where("completed = ?", false).
+				Where("((all_day = ? AND due_at < ?) OR (all_day = ? AND due_at < ?))", false, timedCutoff, true, dateCutoff)
+		},
+	}
+}
+
+// todayView 今天：截止时间落在用户时区的今天
+func todayView(now time.Time, loc *time.Location) *dueView {
+	return dayRangeView("today", now, loc, 0, 1)
+}
+
+// upcomingView 即将到期：从明天起 days 天内到期
+func upcomingView(now time.Time, loc *time.Location, days int) *dueView {
+	return dayRangeView(fmt.Sprintf("upcoming:%d", days), now, loc, 1, days+1)
+}
+
+// workdayUpcomingView 即将到期：从明天起到第 days 个工作日 (含) 为止到期
+func workdayUpcomingView(now time.Time, loc *time.Location, days int, cal *calendar.Calendar) *dueView {
+	today, _ := localDay(now, loc, 0)
+	last := cal.AddWorkdays(today, days)
+	toDay := int(last.Sub(today).Hours()/24) + 1
+	return dayRangeView(fmt.Sprintf("upcoming:%dwd:cal%d", days, cal.Version()), now, loc, 1, toDay)
+}
+
+// parseWorkdaysParam 解析 workdays 参数，为 true 时视图按工作日历计算
+func parseWorkdaysParam(c *gin.Context) (bool, error) {
+	raw := c.Query("workdays")
+	if raw == "" {
+		return false, nil
+	}
+	v, err := strconv.ParseBool(raw)
+	if err != nil {
+		return false, fmt.Errorf("workdays 必须为 true 或 false")
+	}
+	return v, nil
+}
+
+// dayRangeView 截止日期位于用户时区下 [今天+fromDay, 今天+toDay) 范围内的视图
+func dayRangeView(name string, now time.Time, loc *time.Location, fromDay, toDay int) *dueView {
+	fromDate, fromStart := localDay(now, loc, fromDay)
+	toDate, toStart := localDay(now, loc, toDay)
+	return &dueView{
+		name: name,
+		key:  fmt.Sprintf("%s|%s|%s", name, loc.String(), fromDate.Format("2006-01-02")),
+		scope: func(db *gorm.DB) *gorm.DB {
+			return db.Where("completed = ?", false).
+				Where("((all_day = ? AND due_at >= ? AND due_at < ?) OR (all_day = ? AND due_at >= ? AND due_at < ?))",
+					false, fromStart, toStart, true, fromDate, toDate)
+		},
+	}
+}
+
+// parseUpcomingDays 解析 days 参数
+func parseUpcomingDays(raw string) (int, error) {
+	if raw == "" {
+		return defaultUpcomingDays, nil
+	}
+	days, err := strconv.Atoi(raw)
+	if err != nil || days <= 0 || days > maxUpcomingDays {
+		return 0, fmt.Errorf("days 必须为 1 到 %d 之间的整数", maxUpcomingDays)
+	}
+	return days, nil
+}
+
+// resolveLocation 确定计算视图使用的时区：优先使用 ?tz= 参数，否则使用用户设置的时区
+func resolveLocation(c *gin.Context, userID uint) (*time.Location, error) {
+	if tz := c.Query("tz"); tz != "" {
+		loc, err := time.LoadLocation(tz)
+		if err != nil {
+			return nil, fmt.Errorf("无效的时区: %s", tz)
+		}
+		return loc, nil
+	}
+	var user models.User
+	if err := models.DB.Select("id", "timezone").First(&user, userID).Error; err != nil {
+		return nil, err
+	}
+	return user.Location(), nil
+}
+
+// listDueView 解析通用列表参数后套用截止时间视图，默认按截止时间升序
+func listDueView(c *gin.Context, build func(now time.Time, loc *time.Location) (*dueView, error)) {
+	// 从上下文中获取当前用户ID
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "用户未认证"})
+		return
+	}
+	currentUserID := userID.(uint)
+
+	loc, err := resolveLocation(c, currentUserID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	view, err := build(time.Now(), loc)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	listQuery, err := parseTodoListQuery(c, "due_at")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	listQuery.View = view
+
+	listTodos(c, currentUserID, listQuery)
+}
+
+// GetOverdueTodos 返回已逾期的未完成待办事项，?workdays=true 时休息日到期的待办顺延到下一个工作日
+func GetOverdueTodos(c *gin.Context) {
+	listDueView(c, func(now time.Time, loc *time.Location) (*dueView, error) {
+		workdays, err := parseWorkdaysParam(c)
+		if err != nil {
+			return nil, err
+		}
+		if workdays {
+			return workdayOverdueView(now, loc, calendar.Default()), nil
+		}
+		return overdueView(now, loc), nil
+	})
+}
+
+// GetTodayTodos 返回今天到期的未完成待办事项
+func GetTodayTodos(c *gin.Context) {
+	listDueView(c, func(now time.Time, loc *time.Location) (*dueView, error) {
+		return todayView(now, loc), nil
+	})
+}
+
+// GetUpcomingTodos 返回从明天起 days 天内到期的未完成待办事项，?workdays=true 时 days 按工作日计
+func GetUpcomingTodos(c *gin.Context) {
+	listDueView(c, func(now time.Time, loc *time.Location) (*dueView, error) {
+		days, err := parseUpcomingDays(c.Query("days"))
+		if err != nil {
+			return nil, err
+		}
+		workdays, err := parseWorkdaysParam(c)
+		if err != nil {
+			return nil, err
+		}
+		if workdays {
+			return workdayUpcomingView(now, loc, days, calendar.Default()), nil
+		}
+		return upcomingView(now, loc, days), nil
+	})
+}
@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"todolist/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// 历史记录游标的排序标识
+const historyCursorSort = "history"
+
+// historyPage 修订历史的分页响应
+type historyPage struct {
+	Revisions  []models.TodoRevision `json:"revisions"`
+	NextCursor string                `json:"next_cursor"`
+}
+
+// GetTodoHistory 分页返回待办事项的修订历史，最新的在前
+// 回收站中的待办事项同样可以查看历史
+func GetTodoHistory(c *gin.Context) {
+	// 从上下文中获取当前用户ID
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "用户未认证"})
+		return
+	}
+	currentUserID := userID.(uint)
+
+	var todo models.Todo
+	if err := models.VisibleTodos(models.DB.Unscoped(), currentUserID, c.GetUint("workspace_id")).Where("id = ?", c.Param("id")).First(&todo).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "待办事项未找到或无权访问"})
+		return
+	}
+	if !checkTodoRole(c, currentUserID, &todo, models.RoleViewer) {
+		return
+	}
+
+	page, err := parsePageParams(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 同一待办事项的修订ID与版本号同序，游标只需记录ID
+	query := models.DB.Where("todo_id = ?", todo.ID)
+	if page.Cursor != nil {
+		if page.Cursor.Sort != historyCursorSort {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "无效的分页游标"})
+			return
+		}
+		query = query.Where("id < ?", page.Cursor.ID)
+	}
+
+	var revisions []models.TodoRevision
+	// 多取一条用于判断是否还有下一页
+	if err := query.Order("id DESC").Limit(page.Limit + 1).Find(&revisions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取修订历史失败"})
+		return
+	}
+
+	resp := historyPage{Revisions: revisions}
+	if len(revisions) > page.Limit {
+		resp.Revisions = revisions[:page.Limit]
+		resp.NextCursor = encodeCursor(pageCursor{ID: resp.Revisions[page.Limit-1].ID, Sort: historyCursorSort})
+	}
+	setNextLink(c, resp.NextCursor)
+	c.JSON(http.StatusOK, resp)
+}
+
+// RevertTodo 将待办事项的内容恢复到指定版本之后的状态 (带缓存清除)
+// 只恢复标题、描述、截止时间和优先级，回滚本身作为新的修订记录
+func RevertTodo(c *gin.Context) {
+	// 从上下文中获取当前用户ID
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "用户未认证"})
+		return
+	}
+	currentUserID := userID.(uint)
+
+	rev, err := strconv.Atoi(c.Param("rev"))
+	if err != nil || rev <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的版本号"})
+		return
+	}
+
+	todo, ok := loadAccessibleTodo(c, currentUserID, models.RoleEditor)
+	if !ok {
+		return
+	}
+	oldDueAt := todo.DueAt
+
+	var reverted bool
+	err = models.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if reverted, err = models.RevertTodo(tx, currentUserID, todo, rev); err != nil {
+			return err
+		}
+		return tx.Preload("Tags").First(todo, todo.ID).Error
+	})
+	if errors.Is(err, models.ErrRevisionNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "回滚待办事项失败"})
+		return
+	}
+
+	if reverted {
+		// --- 清除相关缓存 ---
+		clearSharedCache(todo.UserID, todo.ProjectID)
+		clearTodoCache(todo.ID)
+
+		// 截止时间变化后重新计算相对提醒
+		if !sameTime(oldDueAt, todo.DueAt) {
+			if err := models.RescheduleTodoReminders(todo); err != nil {
+				fmt.Printf("Reschedule reminders error for todo %d: %v\n", todo.ID, err)
+			}
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"todo": todo, "reverted": reverted})
+}
@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+	"todolist/lunar"
+
+	"github.com/gin-gonic/gin"
+)
+
+// lunarDateResponse 公历日期及其对应的农历日期
+type lunarDateResponse struct {
+	Solar string `json:"solar"` // YYYY-MM-DD
+	Year  int    `json:"lunar_year"`
+	Month int    `json:"lunar_month"`
+	Day   int    `json:"lunar_day"`
+	Leap  bool   `json:"leap"`
+	Text  string `json:"text"` // 如 2025年闰6月10日
+}
+
+func newLunarDateResponse(solar time.Time, d lunar.Date) lunarDateResponse {
+	return lunarDateResponse{
+		Solar: solar.Format("2006-01-02"),
+		Year:  d.Year,
+		Month: d.Month,
+		Day:   d.Day,
+		Leap:  d.Leap,
+		Text:  d.String(),
+	}
+}
+
+// ConvertLunarDate 公历与农历日期互相转换，便于为农历重复待办设置第一次的截止时间
+// ?date=YYYY-MM-DD 公历转农历；?lunar=YYYY-MM-DD&leap=true 农历转公历
+func ConvertLunarDate(c *gin.Context) {
+	if s := c.Query("date"); s != "" {
+		t, err := time.Parse("2006-01-02", s)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "date 必须为 YYYY-MM-DD"})
+			return
+		}
+		d, err := lunar.FromSolar(t)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, newLunarDateResponse(t, d))
+		return
+	}
+
+	if s := c.Query("lunar"); s != "" {
+		var d lunar.Date
+		if _, err := fmt.Sscanf(s, "%d-%d-%d", &d.Year, &d.Month, &d.Day); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "lunar 必须为 YYYY-MM-DD"})
+			return
+		}
+		d.Leap = c.Query("leap") == "true"
+		t, err := lunar.ToSolar(d)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, newLunarDateResponse(t, d))
+		return
+	}
+
+	c.JSON(http.StatusBadRequest, gin.H{"error": "请提供 date 或 lunar 参数"})
+}
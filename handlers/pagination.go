@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// 默认每页条数
+	defaultPageLimit = 50
+	// 每页最大条数
+	maxPageLimit = 200
+)
+
+// pageCursor 分页游标的内容，对客户端不透明
+type pageCursor struct {
+	ID     uint          `json:"id"`          // 上一页最后一条记录的ID
+	Sort   string        `json:"s,omitempty"` // 生成游标时使用的排序条件
+	Values []interface{} `json:"v,omitempty"` // 上一页最后一条记录的排序字段值
+}
+
+// encodeCursor 将游标编码为URL安全的字符串
+func encodeCursor(cur pageCursor) string {
+	data, _ := json.Marshal(cur)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeCursor 解析客户端传入的游标
+func decodeCursor(s string) (pageCursor, error) {
+	var cur pageCursor
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return cur, errors.New("无效的分页游标")
+	}
+	if err := json.Unmarshal(data, &cur); err != nil || cur.ID == 0 {
+		return cur, errors.New("无效的分页游标")
+	}
+	return cur, nil
+}
+
+// pageParams 解析后的分页参数
+type pageParams struct {
+	Limit  int
+	Cursor *pageCursor
+	Raw    string // 原始游标字符串，用于生成缓存Key
+}
+
+// parsePageParams 解析 limit 和 cursor 查询参数
+func parsePageParams(c *gin.Context) (pageParams, error) {
+	p := pageParams{Limit: defaultPageLimit}
+
+	if limitStr := c.Query("limit"); limitStr != "" {
+		limit, err := strconv.Atoi(limitStr)
+		if err != nil || limit <= 0 {
+			return p, errors.New("limit 必须为正整数")
+		}
+		if limit > maxPageLimit {
+			limit = maxPageLimit
+		}
+		p.Limit = limit
+	}
+
+	if raw := c.Query("cursor"); raw != "" {
+		cur, err := decodeCursor(raw)
+		if err != nil {
+			return p, err
+		}
+		p.Cursor = &cur
+		p.Raw = raw
+	}
+
+	return p, nil
+}
+
+// setNextLink 在响应头中写入下一页的 Link 和 X-Next-Cursor
+func setNextLink(c *gin.Context, nextCursor string) {
+	if nextCursor == "" {
+		return
+	}
+	query := url.Values{}
+	for k, v := range c.Request.URL.Query() {
+		query[k] = v
+	}
+	query.Set("cursor", nextCursor)
+	next := url.URL{Path: c.Request.URL.Path, RawQuery: query.Encode()}
+	c.Header("Link", fmt.Sprintf("<%s>; rel=\"next\"", next.String()))
+	c.Header("X-Next-Cursor", nextCursor)
+}
@@ -0,0 +1,266 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"todolist/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 项目名最大长度 (按字符计)
+const maxProjectNameRunes = 128
+
+// ProjectRequest 创建/修改项目请求结构，修改时字段均可选
+type ProjectRequest struct {
+	Name     *string `json:"name"`
+	Color    *string `json:"color"`
+	Archived *bool   `json:"archived"`
+}
+
+// validate 校验并规范化请求中的字段
+func (req *ProjectRequest) validate() error {
+	if req.Name != nil {
+		name := strings.TrimSpace(*req.Name)
+		if name == "" {
+			return errors.New("项目名不能为空")
+		}
+		if len([]rune(name)) > maxProjectNameRunes {
+			return fmt.Errorf("项目名不能超过 %d 个字符", maxProjectNameRunes)
+		}
+		req.Name = &name
+	}
+	if req.Color != nil && !tagColorPattern.MatchString(*req.Color) {
+		return errors.New("颜色格式应为 #RRGGBB")
+	}
+	return nil
+}
+
+// checkTargetProject 校验待办事项要移入的项目：项目属于该工作区，用户在项目中至少是 editor，且项目未归档
+// 返回项目中待办事项的所有者 (项目的创建者)；projectID 为 nil 表示不属于任何项目，总是允许，所有者为用户自己
+func checkTargetProject(userID, workspaceID uint, projectID *uint) (uint, error) {
+	if projectID == nil {
+		return userID, nil
+	}
+	var project models.Project
+	if err := models.VisibleProjects(models.DB, userID, workspaceID).Where("id = ?", *projectID).First(&project).Error; err != nil {
+		return 0, fmt.Errorf("项目 %d 不存在或无权使用", *projectID)
+	}
+	role, err := models.ProjectRole(models.DB, userID, &project)
+	if err != nil {
+		return 0, err
+	}
+	if !models.RoleAtLeast(role, models.RoleEditor) {
+		return 0, fmt.Errorf("项目 %d 不存在或无权使用", *projectID)
+	}
+	if project.Archived {
+		return 0, fmt.Errorf("项目 %d 已归档", *projectID)
+	}
+	return project.UserID, nil
+}
+
+// GetProjects 获取当前用户的项目列表，包括共享给他的项目，role 为他在项目中的角色
+// ?archived=false (默认) 只返回未归档的项目，true 只返回已归档的，all 返回全部
+func GetProjects(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "用户未认证"})
+		return
+	}
+	currentUserID := userID.(uint)
+
+	query := models.VisibleProjects(models.DB, currentUserID, c.GetUint("workspace_id"))
+	switch c.DefaultQuery("archived", "false") {
+	case "false":
+		query = query.Where("archived = ?", false)
+	case "true":
+		query = query.Where("archived = ?", true)
+	case "all":
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "archived 必须为 true、false 或 all"})
+		return
+	}
+
+	var projects []models.Project
+	if err := query.Order("id ASC").Find(&projects).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取项目失败"})
+		return
+	}
+	if err := models.FillProjectRoles(models.DB, currentUserID, projects); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取项目失败"})
+		return
+	}
+	c.JSON(http.StatusOK, projects)
+}
+
+// GetProject 获取单个项目，共享项目的所有成员都可以查看
+func GetProject(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "用户未认证"})
+		return
+	}
+
+	project, role, ok := loadAccessibleProject(c, userID.(uint), models.RoleViewer)
+	if !ok {
+		return
+	}
+	project.Role = role
+	c.JSON(http.StatusOK, project)
+}
+
+// CreateProject 在当前工作区中创建项目
+func CreateProject(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "用户未认证"})
+		return
+	}
+
+	var req ProjectRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求数据"})
+		return
+	}
+	if req.Name == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "项目名不能为空"})
+		return
+	}
+	if err := req.validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	project := models.Project{UserID: userID.(uint), WorkspaceID: c.GetUint("workspace_id"), Name: *req.Name, Color: models.DefaultProjectColor}
+	if req.Color != nil {
+		project.Color = *req.Color
+	}
+	if req.Archived != nil {
+		project.Archived = *req.Archived
+	}
+	if err := models.DB.Create(&project).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "创建项目失败"})
+		return
+	}
+	project.Role = models.RoleOwner
+	c.JSON(http.StatusCreated, project)
+}
+
+// UpdateProject 修改项目名称、颜色或归档状态，需要 owner 角色
+func UpdateProject(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "用户未认证"})
+		return
+	}
+
+	project, role, ok := loadAccessibleProject(c, userID.(uint), models.RoleOwner)
+	if !ok {
+		return
+	}
+
+	var req ProjectRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求数据"})
+		return
+	}
+	if err := req.validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	updates := map[string]interface{}{}
+	if req.Name != nil {
+		updates["name"] = *req.Name
+	}
+	if req.Color != nil {
+		updates["color"] = *req.Color
+	}
+	if req.Archived != nil {
+		updates["archived"] = *req.Archived
+	}
+	if len(updates) > 0 {
+		if err := models.DB.Model(project).Updates(updates).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "更新项目失败"})
+			return
+		}
+	}
+	project.Role = role
+	c.JSON(http.StatusOK, project)
+}
+
+// DeleteProject 删除项目 (带缓存清除)，需要 owner 角色，共享关系一并删除
+// ?mode=orphan (默认) 保留其中的待办事项并移出项目，?mode=cascade 一并删除
+func DeleteProject(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "用户未认证"})
+		return
+	}
+	currentUserID := userID.(uint)
+
+	mode := c.DefaultQuery("mode", models.ProjectDeleteOrphan)
+	if mode != models.ProjectDeleteOrphan && mode != models.ProjectDeleteCascade {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "mode 必须为 orphan 或 cascade"})
+		return
+	}
+
+	project, _, ok := loadAccessibleProject(c, currentUserID, models.RoleOwner)
+	if !ok {
+		return
+	}
+	// 删除后成员关系随之删除，先取出成员用于清除缓存
+	memberIDs, err := models.MemberIDs(models.DB, project.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "删除项目失败"})
+		return
+	}
+
+	todoIDs, err := models.DeleteProject(project, mode, currentUserID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "删除项目失败"})
+		return
+	}
+
+	// --- 清除相关缓存 ---
+	clearUserCache(project.UserID)
+	for _, id := range memberIDs {
+		clearUserCache(id)
+	}
+	for _, id := range todoIDs {
+		clearTodoCache(id)
+	}
+	if mode == models.ProjectDeleteCascade {
+		clearDependentsCache(todoIDs) // 移入回收站的前置任务不再阻塞其他项目中的待办事项
+	}
+	fmt.Printf("Cache cleared for user %d and %d todos of project %d\n", currentUserID, len(todoIDs), project.ID) // 日志
+
+	c.Status(http.StatusNoContent)
+}
+
+// GetProjectTodos 分页返回项目中的待办事项，支持与列表接口相同的筛选和排序参数
+// 共享项目的所有成员都可以查看
+func GetProjectTodos(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "用户未认证"})
+		return
+	}
+	currentUserID := userID.(uint)
+
+	project, _, ok := loadAccessibleProject(c, currentUserID, models.RoleViewer)
+	if !ok {
+		return
+	}
+
+	listQuery, err := parseTodoListQuery(c, defaultTodoSort)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	listQuery.Project = &idFilter{ID: &project.ID}
+
+	listTodos(c, currentUserID, listQuery)
+}
@@ -0,0 +1,313 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+	"todolist/lunar"
+	"todolist/models"
+	"todolist/recurrence"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	// 预览后续发生时间的默认个数和最大个数
+	defaultUpcomingOccurrences = 5
+	maxUpcomingOccurrences     = 50
+)
+
+// recurrenceInput 请求体中的重复规则
+type recurrenceInput struct {
+	RRule   string   `json:"rrule"`   // RFC 5545 RRULE，如 FREQ=WEEKLY;BYDAY=MO,TU,WE,TH,FR
+	ExDates []string `json:"exdates"` // 跳过的日期 (YYYY-MM-DD)
+}
+
+// parse 校验重复规则和跳过的日期
+func (in *recurrenceInput) parse() (*recurrence.Rule, error) {
+	rule, err := recurrence.Parse(in.RRule)
+	if err != nil {
+		return nil, err
+	}
+	for _, d := range in.ExDates {
+		if _, err := time.Parse("2006-01-02", d); err != nil {
+			return nil, fmt.Errorf("无效的跳过日期: %s", d)
+		}
+	}
+	return rule, nil
+}
+
+// attachSeries 在事务中为刚创建的待办事项创建重复系列，待办事项成为系列的第一个实例
+func attachSeries(tx *gorm.DB, todo *models.Todo, in *recurrenceInput, loc *time.Location) error {
+	rule, err := in.parse()
+	if err != nil {
+		return err
+	}
+	series, err := models.NewSeries(todo, rule, in.ExDates, loc)
+	if err != nil {
+		return err
+	}
+	if err := tx.Create(series).Error; err != nil {
+		return err
+	}
+	todo.SeriesID, todo.OccurrenceAt = &series.ID, todo.DueAt
+	return tx.Model(todo).Updates(map[string]interface{}{"series_id": series.ID, "occurrence_at": todo.DueAt}).Error
+}
+
+// loadTodoSeries 加载待办事项所属的重复系列
+func loadTodoSeries(db *gorm.DB, todo *models.Todo) (*models.RecurringSeries, error) {
+	if todo.SeriesID == nil {
+		return nil, nil
+	}
+	var series models.RecurringSeries
+	if err := db.Where("id = ? AND user_id = ?", *todo.SeriesID, todo.UserID).First(&series).Error; err != nil {
+		return nil, err
+	}
+	return &series, nil
+}
+
+// seriesResponse 重复系列的详情，包括后续几个发生时间的预览
+func seriesResponse(series *models.RecurringSeries, todo *models.Todo, n int) (gin.H, error) {
+	upcoming, err := series.Upcoming(todo.Occurrence(), n)
+	if err != nil {
+		return nil, err
+	}
+	resp := gin.H{
+		"series":   series,
+		"exdates":  series.ExDateList(),
+		"upcoming": upcoming,
+	}
+	// 农历重复同时返回对应的农历日期，便于前端展示
+	if rule, err := series.Rule(); err == nil && rule.RScale == recurrence.ScaleChinese {
+		labels := make([]string, 0, len(upcoming))
+		for _, t := range upcoming {
+			d, err := lunar.FromSolar(t.In(series.Location()))
+			if err != nil {
+				return nil, err
+			}
+			labels = append(labels, d.String())
+		}
+		resp["upcoming_lunar"] = labels
+	}
+	return resp, nil
+}
+
+// GetTodoRecurrence 返回待办事项的重复规则和后续发生时间 (?count= 默认 5，最多 50)
+func GetTodoRecurrence(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "用户未认证"})
+		return
+	}
+
+	n := defaultUpcomingOccurrences
+	if s := c.Query("count"); s != "" {
+		v, err := strconv.Atoi(s)
+		if err != nil || v <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "count 必须为正整数"})
+			return
+		}
+		if v > maxUpcomingOccurrences {
+			v = maxUpcomingOccurrences
+		}
+		n = v
+	}
+
+	todo, ok := loadAccessibleTodo(c, userID.(uint), models.RoleViewer)
+	if !ok {
+		return
+	}
+	series, err := loadTodoSeries(models.DB, todo)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取重复规则失败"})
+		return
+	}
+	if series == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "该待办事项不是重复待办"})
+		return
+	}
+
+	resp, err := seriesResponse(series, todo, n)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取重复规则失败"})
+		return
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// SetTodoRecurrence 设置或修改待办事项的重复规则
+// 普通待办事项会成为新系列的第一个实例 (必须有截止时间)；已属于系列时修改整个系列的规则
+func SetTodoRecurrence(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "用户未认证"})
+		return
+	}
+	currentUserID := userID.(uint)
+
+	var req recurrenceInput
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求数据"})
+		return
+	}
+	rule, err := req.parse()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	todo, ok := loadAccessibleTodo(c, currentUserID, models.RoleEditor)
+	if !ok {
+		return
+	}
+	if todo.SeriesID == nil && todo.DueAt == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "重复待办必须设置截止时间"})
+		return
+	}
+	loc, err := resolveLocation(c, currentUserID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var series *models.RecurringSeries
+	err = models.DB.Transaction(func(tx *gorm.DB) error {
+		if todo.SeriesID == nil {
+			if err := attachSeries(tx, todo, &req, loc); err != nil {
+				return err
+			}
+			var err error
+			series, err = loadTodoSeries(tx, todo)
+			return err
+		}
+		var err error
+		if series, err = loadTodoSeries(tx, todo); err != nil {
+			return err
+		}
+		series.RRule = rule.String()
+		series.SetExDates(req.ExDates)
+		return tx.Model(series).Updates(map[string]interface{}{"rrule": series.RRule, "exdates": series.ExDates}).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "设置重复规则失败"})
+		return
+	}
+
+	clearSharedCache(todo.UserID, todo.ProjectID)
+	clearTodoCache(todo.ID)
+
+	resp, err := seriesResponse(series, todo, defaultUpcomingOccurrences)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "设置重复规则失败"})
+		return
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// DeleteTodoRecurrence 停止重复，已生成的实例保留为普通待办事项
+func DeleteTodoRecurrence(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "用户未认证"})
+		return
+	}
+	currentUserID := userID.(uint)
+
+	todo, ok := loadAccessibleTodo(c, currentUserID, models.RoleEditor)
+	if !ok {
+		return
+	}
+	if todo.SeriesID == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "该待办事项不是重复待办"})
+		return
+	}
+
+	var instanceIDs []uint
+	err := models.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Todo{}).Where("series_id = ?", *todo.SeriesID).Pluck("id", &instanceIDs).Error; err != nil {
+			return err
+		}
+		return models.DeleteSeries(tx, *todo.SeriesID)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "停止重复失败"})
+		return
+	}
+
+	clearSharedCache(todo.UserID, todo.ProjectID)
+	for _, id := range instanceIDs {
+		clearTodoCache(id)
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// SkipTodoOccurrence 跳过本次发生：实例不完成，直接顺延到下一个发生时间
+func SkipTodoOccurrence(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "用户未认证"})
+		return
+	}
+	currentUserID := userID.(uint)
+
+	todo, ok := loadAccessibleTodo(c, currentUserID, models.RoleEditor)
+	if !ok {
+		return
+	}
+	series, err := loadTodoSeries(models.DB, todo)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "跳过失败"})
+		return
+	}
+	if series == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "只有重复待办可以跳过"})
+		return
+	}
+	if todo.Completed {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "已完成的实例不能跳过"})
+		return
+	}
+
+	next, err := series.NextOccurrence(todo.Occurrence())
+	if errors.Is(err, models.ErrSeriesEnded) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "跳过失败"})
+		return
+	}
+
+	err = models.DB.Transaction(func(tx *gorm.DB) error {
+		before, err := models.SnapshotTodos(tx, []uint{todo.ID})
+		if err != nil {
+			return err
+		}
+		if err := tx.Model(todo).Updates(map[string]interface{}{"due_at": next, "occurrence_at": next}).Error; err != nil {
+			return err
+		}
+		if err := tx.Preload("Tags").First(todo, todo.ID).Error; err != nil {
+			return err
+		}
+		if err := models.RefreshSmartScore(tx, todo); err != nil {
+			return err
+		}
+		return models.RecordRevisions(tx, currentUserID, models.RevisionSkip, before)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "跳过失败"})
+		return
+	}
+
+	clearSharedCache(todo.UserID, todo.ProjectID)
+	clearTodoCache(todo.ID)
+	if err := models.RescheduleTodoReminders(todo); err != nil {
+		fmt.Printf("Reschedule reminders error for todo %d: %v\n", todo.ID, err)
+	}
+
+	c.JSON(http.StatusOK, todo)
+}
@@ -0,0 +1,268 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+	"todolist/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// 每个待办事项最多的提醒数
+	maxRemindersPerTodo = 10
+	// 相对提醒最多提前的分钟数 (4 周)
+	maxReminderOffset = 4 * 7 * 24 * 60
+	// 稍后提醒默认和最大的分钟数
+	defaultSnoozeMinutes = 10
+	maxSnoozeMinutes     = 24 * 60
+)
+
+// ReminderRequest 创建提醒的请求，remind_at 和 offset_minutes 二选一
+type ReminderRequest struct {
+	RemindAt      *time.Time `json:"remind_at"`      // 绝对时间 (RFC3339)
+	OffsetMinutes *int       `json:"offset_minutes"` // 截止时间前多少分钟
+}
+
+// SnoozeRequest 稍后提醒的请求
+type SnoozeRequest struct {
+	Minutes int `json:"minutes"`
+}
+
+// findUserReminder 查找属于当前用户、且待办事项在当前工作区中的提醒
+func findUserReminder(c *gin.Context, userID uint) (*models.Reminder, bool) {
+	var reminder models.Reminder
+	err := models.DB.Where("id = ? AND user_id = ? AND todo_id IN (?)", c.Param("id"), userID, models.WorkspaceTodoIDs(c.GetUint("workspace_id"))).
+		First(&reminder).Error
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "提醒未找到或无权访问"})
+		return nil, false
+	}
+	return &reminder, true
+}
+
+// saveReminderState 保存提醒的状态和触发时间，并同步延迟队列
+func saveReminderState(reminder *models.Reminder) error {
+	err := models.DB.Model(reminder).Updates(map[string]interface{}{
+		"status":  reminder.Status,
+		"fire_at": reminder.FireAt,
+	}).Error
+	if err != nil {
+		return err
+	}
+	return models.EnqueueReminder(reminder)
+}
+
+// GetTodoReminders 返回待办事项的所有提醒
+func GetTodoReminders(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "用户未认证"})
+		return
+	}
+	currentUserID := userID.(uint)
+
+	var todo models.Todo
+	if err := models.DB.Where("id = ? AND user_id = ? AND workspace_id = ?", c.Param("id"), currentUserID, c.GetUint("workspace_id")).First(&todo).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "待办事项未找到或无权访问"})
+		return
+	}
+
+	var reminders []models.Reminder
+	if err := models.DB.Where("todo_id = ?", todo.ID).Order("id ASC").Find(&reminders).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取提醒失败"})
+		return
+	}
+	c.JSON(http.StatusOK, reminders)
+}
+
+// CreateTodoReminder 为待办事项添加提醒，提醒只对待办事项的所有者开放，共享项目的成员不能设置
+func CreateTodoReminder(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "用户未认证"})
+		return
+	}
+	currentUserID := userID.(uint)
+
+	var req ReminderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求数据"})
+		return
+	}
+	if (req.RemindAt == nil) == (req.OffsetMinutes == nil) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "remind_at 和 offset_minutes 必须且只能设置一个"})
+		return
+	}
+	if req.OffsetMinutes != nil && (*req.OffsetMinutes < 0 || *req.OffsetMinutes > maxReminderOffset) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("offset_minutes 必须为 0 到 %d 之间的整数", maxReminderOffset)})
+		return
+	}
+
+	var todo models.Todo
+	if err := models.DB.Where("id = ? AND user_id = ? AND workspace_id = ?", c.Param("id"), currentUserID, c.GetUint("workspace_id")).First(&todo).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "待办事项未找到或无权更新"})
+		return
+	}
+	if req.OffsetMinutes != nil && todo.DueAt == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "待办事项没有截止时间，不能设置相对提醒"})
+		return
+	}
+	var count int64
+	models.DB.Model(&models.Reminder{}).Where("todo_id = ?", todo.ID).Count(&count)
+	if count >= maxRemindersPerTodo {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("每个待办事项最多 %d 个提醒", maxRemindersPerTodo)})
+		return
+	}
+	loc, err := resolveLocation(c, currentUserID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	reminder := models.Reminder{
+		UserID:        currentUserID,
+		TodoID:        todo.ID,
+		RemindAt:      req.RemindAt,
+		OffsetMinutes: req.OffsetMinutes,
+		Status:        models.ReminderPending,
+	}
+	reminder.FireAt = reminder.ComputeFireAt(&todo, loc)
+	if err := models.DB.Create(&reminder).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "创建提醒失败"})
+		return
+	}
+	if err := models.EnqueueReminder(&reminder); err != nil {
+		// 入队失败时提醒仍在数据库中，重启恢复时会重新入队
+		fmt.Printf("Redis ZAdd error for reminder %d: %v\n", reminder.ID, err)
+	}
+
+	c.JSON(http.StatusCreated, reminder)
+}
+
+// GetReminders 返回当前用户在当前工作区中的提醒，?status= 按状态筛选 (如 fired 为已触发的提醒)，按触发时间倒序
+func GetReminders(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "用户未认证"})
+		return
+	}
+
+	limit := defaultPageLimit
+	if s := c.Query("limit"); s != "" {
+		v, err := strconv.Atoi(s)
+		if err != nil || v <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit 必须为正整数"})
+			return
+		}
+		if v > maxPageLimit {
+			v = maxPageLimit
+		}
+		limit = v
+	}
+
+	query := models.DB.Where("user_id = ? AND todo_id IN (?)", userID.(uint), models.WorkspaceTodoIDs(c.GetUint("workspace_id")))
+	switch status := c.Query("status"); status {
+	case "":
+	case models.ReminderPending, models.ReminderFired, models.ReminderDismissed, models.ReminderCancelled:
+		query = query.Where("status = ?", status)
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的 status"})
+		return
+	}
+
+	var reminders []models.Reminder
+	if err := query.Order("fire_at DESC").Order("id DESC").Limit(limit).Find(&reminders).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取提醒失败"})
+		return
+	}
+	c.JSON(http.StatusOK, reminders)
+}
+
+// SnoozeReminder 稍后提醒：从现在起推迟若干分钟后再次触发 (默认 10 分钟)
+func SnoozeReminder(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "用户未认证"})
+		return
+	}
+
+	var req SnoozeRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求数据"})
+			return
+		}
+	}
+	if req.Minutes == 0 {
+		req.Minutes = defaultSnoozeMinutes
+	}
+	if req.Minutes < 1 || req.Minutes > maxSnoozeMinutes {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("minutes 必须为 1 到 %d 之间的整数", maxSnoozeMinutes)})
+		return
+	}
+
+	reminder, ok := findUserReminder(c, userID.(uint))
+	if !ok {
+		return
+	}
+	if reminder.Status == models.ReminderCancelled {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "提醒已取消"})
+		return
+	}
+
+	fireAt := time.Now().Add(time.Duration(req.Minutes) * time.Minute).UTC()
+	reminder.Status, reminder.FireAt = models.ReminderPending, &fireAt
+	if err := saveReminderState(reminder); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "稍后提醒失败"})
+		return
+	}
+	c.JSON(http.StatusOK, reminder)
+}
+
+// DismissReminder 关闭提醒，不再触发
+func DismissReminder(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "用户未认证"})
+		return
+	}
+
+	reminder, ok := findUserReminder(c, userID.(uint))
+	if !ok {
+		return
+	}
+	reminder.Status = models.ReminderDismissed
+	if err := saveReminderState(reminder); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "关闭提醒失败"})
+		return
+	}
+	c.JSON(http.StatusOK, reminder)
+}
+
+// DeleteReminder 删除提醒
+func DeleteReminder(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "用户未认证"})
+		return
+	}
+
+	reminder, ok := findUserReminder(c, userID.(uint))
+	if !ok {
+		return
+	}
+	if err := models.DB.Delete(reminder).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "删除提醒失败"})
+		return
+	}
+	// 队列中残留的成员在触发时找不到提醒会被忽略，这里尽量移除
+	reminder.Status = models.ReminderCancelled
+	if err := models.EnqueueReminder(reminder); err != nil {
+		fmt.Printf("Redis ZRem error for reminder %d: %v\n", reminder.ID, err)
+	}
+
+	c.Status(http.StatusNoContent)
+}
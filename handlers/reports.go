@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"time"
+	"todolist/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 按周分组未指定范围时统计最近的天数 (12 周)
+const defaultEstimateReportDays = 84
+
+// estimateReportRow 预估报表中的一行
+type estimateReportRow struct {
+	// 项目ID / 标签ID (没有项目 / 没有标签时为 null)，按周分组时为该周周一的日期
+	Key  interface{} `json:"key"`
+	Name string      `json:"name"`
+	models.EstimateGroup
+	// 有预估的待办事项实际耗时与预估之比，大于 1 表示低估；没有预估时为 null
+	ActualRatio *float64 `json:"actual_ratio"`
+}
+
+// weekStart 返回 t 所在周的周一零点 (loc)
+func weekStart(t time.Time, loc *time.Location) time.Time {
+	y, m, d := t.In(loc).Date()
+	day := time.Date(y, m, d, 0, 0, 0, 0, loc)
+	return day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7))
+}
+
+// GetEstimateReport 对比预估耗时和记录的时长，?group_by=project (默认)、tag 或 week
+// from/to 为用户时区中的日期 (包含两端)，只统计在范围内完成的待办事项；
+// 按项目和标签分组时不传则统计全部待办事项，按周分组时按完成时间所在的整周统计，默认最近 12 周
+func GetEstimateReport(c *gin.Context) {
+	// 从上下文中获取当前用户ID
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "用户未认证"})
+		return
+	}
+	currentUserID := userID.(uint)
+
+	groupBy := c.DefaultQuery("group_by", models.EstimateByProject)
+	if groupBy != models.EstimateByProject && groupBy != models.EstimateByTag && groupBy != models.EstimateByWeek {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "group_by 必须为 project、tag 或 week"})
+		return
+	}
+	loc, err := resolveLocation(c, currentUserID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	now := time.Now()
+	q := models.EstimateReportQuery{UserID: currentUserID, WorkspaceID: c.GetUint("workspace_id"), GroupBy: groupBy, Now: now}
+	resp := gin.H{"group_by": groupBy, "timezone": loc.String()}
+	if groupBy == models.EstimateByWeek || c.Query("from") != "" || c.Query("to") != "" {
+		from, to, err := parseDayRange(c, now, loc, defaultEstimateReportDays)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		end := to.AddDate(0, 0, 1)
+		if groupBy == models.EstimateByWeek {
+			// 对齐到整周
+			from, end = weekStart(from, loc), weekStart(to, loc).AddDate(0, 0, 7)
+			for w := from; w.Before(end); w = w.AddDate(0, 0, 7) {
+				q.Weeks = append(q.Weeks, w)
+			}
+		}
+		q.From, q.To = &from, &end
+		resp["from"], resp["to"] = from.Format("2006-01-02"), end.AddDate(0, 0, -1).Format("2006-01-02")
+	}
+
+	groups, err := models.EstimateReport(models.DB, q)
+	if err != nil {
+		fmt.Printf("Estimate report error for user %d: %v\n", currentUserID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "生成报表失败"})
+		return
+	}
+
+	// 分组名称
+	names := map[uint]string{}
+	var ids []uint
+	for _, g := range groups {
+		if g.GroupKey != 0 {
+			ids = append(ids, g.GroupKey)
+		}
+	}
+	if len(ids) > 0 && groupBy != models.EstimateByWeek {
+		var rows []struct {
+			ID   uint
+			Name string
+		}
+		model := interface{}(&models.Project{})
+		if groupBy == models.EstimateByTag {
+			model = &models.Tag{}
+		}
+		if err := models.DB.Model(model).Select("id", "name").Where("id IN ?", ids).Scan(&rows).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "生成报表失败"})
+			return
+		}
+		for _, r := range rows {
+			names[r.ID] = r.Name
+		}
+	}
+
+	rows := make([]estimateReportRow, 0, len(groups))
+	for _, g := range groups {
+		row := estimateReportRow{EstimateGroup: g}
+		switch {
+		case groupBy == models.EstimateByWeek:
+			w := q.Weeks[g.GroupKey]
+			year, week := w.ISOWeek()
+			row.Key, row.Name = w.Format("2006-01-02"), fmt.Sprintf("%d-W%02d", year, week)
+		case g.GroupKey == 0 && groupBy == models.EstimateByProject:
+			row.Name = "无项目"
+		case g.GroupKey == 0:
+			row.Name = "无标签"
+		default:
+			row.Key, row.Name = g.GroupKey, names[g.GroupKey]
+		}
+		if g.EstimateMinutes > 0 {
+			ratio := math.Round(float64(g.EstimatedLoggedSeconds)/float64(g.EstimateMinutes*60)*100) / 100
+			row.ActualRatio = &ratio
+		}
+		rows = append(rows, row)
+	}
+	resp["groups"] = rows
+	c.JSON(http.StatusOK, resp)
+}
@@ -0,0 +1,160 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"todolist/models"
+	"todolist/search"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// 搜索结果默认条数
+	defaultSearchLimit = 20
+	// 搜索结果最大条数
+	maxSearchLimit = 100
+	// 描述高亮片段的最大字符数
+	snippetRunes = 80
+)
+
+// searchHighlights 命中字段的高亮片段
+type searchHighlights struct {
+	Title       string `json:"title,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+// searchResult 单条搜索结果
+type searchResult struct {
+	Todo       models.Todo      `json:"todo"`
+	Score      float64          `json:"score"`
+	Highlights searchHighlights `json:"highlights"`
+}
+
+// SearchTodos 在当前用户能看到的待办事项 (包括共享项目中的) 标题和描述中全文检索，按相关度排序
+// 输入为纯字母时还会按标题拼音 (全拼或首字母) 匹配
+func SearchTodos(c *gin.Context) {
+	// 从上下文中获取当前用户ID
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "用户未认证"})
+		return
+	}
+	currentUserID := userID.(uint)
+	workspaceID := c.GetUint("workspace_id")
+
+	q := strings.TrimSpace(c.Query("q"))
+	if q == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "搜索关键词不能为空"})
+		return
+	}
+	limit := defaultSearchLimit
+	if limitStr := c.Query("limit"); limitStr != "" {
+		n, err := strconv.Atoi(limitStr)
+		if err != nil || n <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit 必须为正整数"})
+			return
+		}
+		if n > maxSearchLimit {
+			n = maxSearchLimit
+		}
+		limit = n
+	}
+
+	terms := search.QueryTerms(q)
+	results := []searchResult{}
+
+	// 全文检索：只在当前用户在该工作区中能看到的待办事项的索引中查找
+	var ranked []search.Result
+	if len(terms) > 0 {
+		var rows []models.TodoSearchTerm
+		visibleIDs := models.VisibleTodos(models.DB.Model(&models.Todo{}).Select("todos.id"), currentUserID, workspaceID)
+		if err := models.DB.Where("term IN ? AND todo_id IN (?)", terms, visibleIDs).Find(&rows).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "搜索失败"})
+			return
+		}
+		var totalDocs int64
+		if err := models.VisibleTodos(models.DB.Model(&models.Todo{}), currentUserID, workspaceID).Count(&totalDocs).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "搜索失败"})
+			return
+		}
+		postings := make([]search.Posting, len(rows))
+		for i, r := range rows {
+			postings[i] = search.Posting{DocID: r.TodoID, Term: r.Term, Field: r.Field, Freq: r.Freq}
+		}
+		ranked = search.Rank(postings, terms, int(totalDocs))
+	}
+	if len(ranked) > limit {
+		ranked = ranked[:limit]
+	}
+
+	// 拼音匹配：纯字母输入时按标题全拼或首字母查找，排在全文检索结果之后
+	pinyinQuery := search.NormalizePinyinQuery(q)
+	if pinyinQuery != "" && len(ranked) < limit {
+		pattern := "%" + escapeLike(pinyinQuery) + "%"
+		var ids []uint
+		err := models.VisibleTodos(models.DB.Model(&models.Todo{}), currentUserID, workspaceID).
+			Where("title_pinyin LIKE ? OR title_initials LIKE ?", pattern, pattern).
+			Order("id DESC").Limit(maxSearchLimit).Pluck("id", &ids).Error
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "搜索失败"})
+			return
+		}
+		seen := make(map[uint]bool, len(ranked))
+		for _, r := range ranked {
+			seen[r.DocID] = true
+		}
+		for _, id := range ids {
+			if len(ranked) >= limit {
+				break
+			}
+			if !seen[id] {
+				ranked = append(ranked, search.Result{DocID: id})
+			}
+		}
+	}
+	if len(ranked) == 0 {
+		c.JSON(http.StatusOK, gin.H{"query": q, "results": results})
+		return
+	}
+
+	ids := make([]uint, len(ranked))
+	for i, r := range ranked {
+		ids[i] = r.DocID
+	}
+	var todos []models.Todo
+	if err := models.VisibleTodos(models.DB, currentUserID, workspaceID).Where("id IN ?", ids).Find(&todos).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "搜索失败"})
+		return
+	}
+	byID := make(map[uint]models.Todo, len(todos))
+	for _, t := range todos {
+		byID[t.ID] = t
+	}
+
+	for _, r := range ranked {
+		todo, ok := byID[r.DocID]
+		if !ok {
+			continue // 索引与数据暂时不一致时跳过
+		}
+		highlights := searchHighlights{
+			Title:       search.Snippet(todo.Title, terms, 0),
+			Description: search.Snippet(todo.Description, terms, snippetRunes),
+		}
+		if highlights.Title == "" {
+			if start, end, ok := search.MatchPinyin(todo.Title, pinyinQuery); ok {
+				highlights.Title = search.HighlightRange(todo.Title, start, end)
+			} else if r.Matched == 0 {
+				continue // LIKE 候选未能按音节对齐匹配，丢弃
+			}
+		}
+		results = append(results, searchResult{
+			Todo:       todo,
+			Score:      r.Score,
+			Highlights: highlights,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"query": q, "results": results})
+}
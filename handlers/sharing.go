@@ -0,0 +1,314 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+	"todolist/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// errOtherOwner 共享项目中的待办事项属于项目的创建者，不能在不同所有者的项目或父任务之间移动
+var errOtherOwner = errors.New("不能移动到属于其他用户的项目或父任务下")
+
+// ProjectMemberRequest 添加/修改项目成员的请求结构，修改时只需要 role
+type ProjectMemberRequest struct {
+	Username string `json:"username"`
+	Role     string `json:"role"`
+}
+
+// projectMemberView 项目成员的响应结构，创建者以 owner 角色列在最前面
+type projectMemberView struct {
+	UserID    uint      `json:"user_id"`
+	Username  string    `json:"username"`
+	Role      string    `json:"role"`
+	Creator   bool      `json:"creator"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// loadAccessibleTodo 在当前工作区中查找当前用户可以访问的待办事项并检查角色
+// 看不到该待办事项 (包括属于其他工作区) 时写入 404 响应，角色低于 minRole 时写入 403 响应
+func loadAccessibleTodo(c *gin.Context, userID uint, minRole string) (*models.Todo, bool) {
+	var todo models.Todo
+	if err := models.VisibleTodos(models.DB, userID, c.GetUint("workspace_id")).Where("id = ?", c.Param("id")).First(&todo).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "待办事项未找到或无权访问"})
+		return nil, false
+	}
+	if !checkTodoRole(c, userID, &todo, minRole) {
+		return nil, false
+	}
+	return &todo, true
+}
+
+// checkTodoRole 检查用户对待办事项的角色，角色不足时写入 403 响应
+func checkTodoRole(c *gin.Context, userID uint, todo *models.Todo, minRole string) bool {
+	role, err := models.TodoRole(models.DB, userID, todo)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "检查权限失败"})
+		return false
+	}
+	if !models.RoleAtLeast(role, minRole) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "当前角色无权执行该操作"})
+		return false
+	}
+	return true
+}
+
+// isTodoOwner 用户对待办事项是否具有 owner 角色，查询出错时视为不是
+func isTodoOwner(userID uint, todo *models.Todo) bool {
+	role, err := models.TodoRole(models.DB, userID, todo)
+	if err != nil {
+		fmt.Printf("Load role error for user %d on todo %d: %v\n", userID, todo.ID, err)
+		return false
+	}
+	return role == models.RoleOwner
+}
+
+// loadAccessibleProject 在当前工作区中查找当前用户可以访问的项目并检查角色，返回用户在项目中的角色
+// 看不到该项目 (包括属于其他工作区) 时写入 404 响应，角色低于 minRole 时写入 403 响应
+func loadAccessibleProject(c *gin.Context, userID uint, minRole string) (*models.Project, string, bool) {
+	var project models.Project
+	if err := models.VisibleProjects(models.DB, userID, c.GetUint("workspace_id")).Where("id = ?", c.Param("id")).First(&project).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "项目未找到或无权访问"})
+		return nil, "", false
+	}
+	role, err := models.ProjectRole(models.DB, userID, &project)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "检查权限失败"})
+		return nil, "", false
+	}
+	if !models.RoleAtLeast(role, minRole) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "当前角色无权执行该操作"})
+		return nil, "", false
+	}
+	return &project, role, true
+}
+
+// clearSharedCache 清除待办事项所有者的列表缓存，并扩散到相关共享项目的每个成员
+// projectIDs 为待办事项修改前后所在的项目，nil 表示不属于任何项目
+func clearSharedCache(ownerID uint, projectIDs ...*uint) {
+	clearUserCache(ownerID)
+	cleared := map[uint]bool{ownerID: true}
+	for _, projectID := range projectIDs {
+		if projectID == nil {
+			continue
+		}
+		memberIDs, err := models.MemberIDs(models.DB, *projectID)
+		if err != nil {
+			fmt.Printf("Load members error for project %d: %v\n", *projectID, err)
+			continue
+		}
+		for _, id := range memberIDs {
+			if !cleared[id] {
+				cleared[id] = true
+				clearUserCache(id)
+			}
+		}
+	}
+}
+
+// clearTodoMembersCache 清除能通过共享项目看到这些待办事项的成员的列表缓存，不包括所有者
+func clearTodoMembersCache(todoIDs []uint) {
+	memberIDs, err := models.TodoMemberIDs(models.DB, todoIDs)
+	if err != nil {
+		fmt.Printf("Load project members error for %d todos: %v\n", len(todoIDs), err)
+		return
+	}
+	for _, id := range memberIDs {
+		clearUserCache(id)
+	}
+}
+
+// projectMemberViews 返回项目的创建者和所有成员
+func projectMemberViews(project *models.Project) ([]projectMemberView, error) {
+	var members []models.ProjectMember
+	if err := models.DB.Where("project_id = ?", project.ID).Order("created_at ASC").Find(&members).Error; err != nil {
+		return nil, err
+	}
+	userIDs := []uint{project.UserID}
+	for _, m := range members {
+		userIDs = append(userIDs, m.UserID)
+	}
+	var users []models.User
+	if err := models.DB.Select("id", "username").Where("id IN ?", userIDs).Find(&users).Error; err != nil {
+		return nil, err
+	}
+	names := make(map[uint]string, len(users))
+	for _, u := range users {
+		names[u.ID] = u.Username
+	}
+
+	views := []projectMemberView{{
+		UserID:    project.UserID,
+		Username:  names[project.UserID],
+		Role:      models.RoleOwner,
+		Creator:   true,
+		CreatedAt: project.CreatedAt,
+	}}
+	for _, m := range members {
+		views = append(views, projectMemberView{UserID: m.UserID, Username: names[m.UserID], Role: m.Role, CreatedAt: m.CreatedAt})
+	}
+	return views, nil
+}
+
+// GetProjectMembers 返回项目的创建者和协作成员，所有成员都可以查看
+func GetProjectMembers(c *gin.Context) {
+	// 从上下文中获取当前用户ID
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "用户未认证"})
+		return
+	}
+	currentUserID := userID.(uint)
+
+	project, _, ok := loadAccessibleProject(c, currentUserID, models.RoleViewer)
+	if !ok {
+		return
+	}
+	views, err := projectMemberViews(project)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取项目成员失败"})
+		return
+	}
+	c.JSON(http.StatusOK, views)
+}
+
+// AddProjectMember 按用户名将项目共享给其他用户 (带缓存清除)，需要 owner 角色
+// 只能共享给项目所在工作区的成员，因此个人工作区中的项目不能共享
+func AddProjectMember(c *gin.Context) {
+	// 从上下文中获取当前用户ID
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "用户未认证"})
+		return
+	}
+	currentUserID := userID.(uint)
+
+	project, _, ok := loadAccessibleProject(c, currentUserID, models.RoleOwner)
+	if !ok {
+		return
+	}
+	var req ProjectMemberRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.Username == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求数据"})
+		return
+	}
+	if !models.ValidRole(req.Role) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "role 必须为 viewer、editor 或 owner"})
+		return
+	}
+	var user models.User
+	if err := models.DB.Where("username = ?", req.Username).First(&user).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "用户不存在"})
+		return
+	}
+	if user.ID == project.UserID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "该用户是项目的创建者"})
+		return
+	}
+	if wsRole, err := models.WorkspaceRole(models.DB, user.ID, project.WorkspaceID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "添加项目成员失败"})
+		return
+	} else if wsRole == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "该用户不是项目所在工作区的成员"})
+		return
+	}
+
+	member := models.ProjectMember{ProjectID: project.ID, UserID: user.ID, Role: req.Role}
+	result := models.DB.Where("project_id = ? AND user_id = ?", project.ID, user.ID).FirstOrCreate(&member)
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "添加项目成员失败"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "该用户已是项目成员"})
+		return
+	}
+
+	// --- 清除相关缓存 ---
+	clearUserCache(user.ID) // 共享项目中的待办事项出现在新成员的列表中
+
+	c.JSON(http.StatusCreated, projectMemberView{UserID: user.ID, Username: user.Username, Role: member.Role, CreatedAt: member.CreatedAt})
+}
+
+// UpdateProjectMember 修改成员的角色，需要 owner 角色
+func UpdateProjectMember(c *gin.Context) {
+	// 从上下文中获取当前用户ID
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "用户未认证"})
+		return
+	}
+	currentUserID := userID.(uint)
+
+	project, _, ok := loadAccessibleProject(c, currentUserID, models.RoleOwner)
+	if !ok {
+		return
+	}
+	var req ProjectMemberRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求数据"})
+		return
+	}
+	if !models.ValidRole(req.Role) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "role 必须为 viewer、editor 或 owner"})
+		return
+	}
+
+	var member models.ProjectMember
+	if err := models.DB.Where("project_id = ? AND user_id = ?", project.ID, c.Param("user_id")).First(&member).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "项目成员未找到"})
+		return
+	}
+	if err := models.DB.Model(&member).Update("role", req.Role).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "修改项目成员失败"})
+		return
+	}
+	c.JSON(http.StatusOK, member)
+}
+
+// RemoveProjectMember 移除项目成员 (带缓存清除)
+// owner 可以移除任何成员，其他成员只能移除自己 (退出共享)；项目的创建者不能被移除
+func RemoveProjectMember(c *gin.Context) {
+	// 从上下文中获取当前用户ID
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "用户未认证"})
+		return
+	}
+	currentUserID := userID.(uint)
+
+	project, role, ok := loadAccessibleProject(c, currentUserID, models.RoleViewer)
+	if !ok {
+		return
+	}
+	var member models.ProjectMember
+	err := models.DB.Where("project_id = ? AND user_id = ?", project.ID, c.Param("user_id")).First(&member).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		if c.Param("user_id") == fmt.Sprint(project.UserID) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "不能移除项目的创建者"})
+		} else {
+			c.JSON(http.StatusNotFound, gin.H{"error": "项目成员未找到"})
+		}
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "移除项目成员失败"})
+		return
+	}
+	if member.UserID != currentUserID && role != models.RoleOwner {
+		c.JSON(http.StatusForbidden, gin.H{"error": "当前角色无权执行该操作"})
+		return
+	}
+	if err := models.DB.Where("project_id = ? AND user_id = ?", project.ID, member.UserID).Delete(&models.ProjectMember{}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "移除项目成员失败"})
+		return
+	}
+
+	// --- 清除相关缓存 ---
+	clearUserCache(member.UserID) // 共享项目中的待办事项从该成员的列表中消失
+
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,296 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"todolist/models"
+	"todolist/position"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// subtaskPolicy 确定父任务完成/删除时对子任务的处理策略
+// 优先使用 ?children= 参数，否则使用环境变量 envKey 配置的默认策略 (未配置时为 cascade)
+func subtaskPolicy(c *gin.Context, envKey string) (string, error) {
+	policy := c.Query("children")
+	if policy == "" {
+		policy = getEnvOrDefault(envKey, models.SubtaskPolicyCascade)
+	}
+	switch policy {
+	case models.SubtaskPolicyCascade, models.SubtaskPolicyKeep, models.SubtaskPolicyBlock:
+		return policy, nil
+	}
+	return "", fmt.Errorf("children 必须为 %s、%s 或 %s",
+		models.SubtaskPolicyCascade, models.SubtaskPolicyKeep, models.SubtaskPolicyBlock)
+}
+
+// checkTargetParent 校验父任务：父任务属于该工作区，用户对它至少是 editor，且移动后不会形成环
+// todoID 为 0 表示新建的待办事项，parentID 为 nil 表示顶层任务，返回找到的父任务
+// 调用方还需保证父任务与待办事项属于同一所有者
+func checkTargetParent(userID, workspaceID, todoID uint, parentID *uint) (*models.Todo, error) {
+	if parentID == nil {
+		return nil, nil
+	}
+	var parent models.Todo
+	if err := models.VisibleTodos(models.DB, userID, workspaceID).Where("id = ?", *parentID).First(&parent).Error; err != nil {
+		return nil, fmt.Errorf("父任务 %d 不存在或无权使用", *parentID)
+	}
+	role, err := models.TodoRole(models.DB, userID, &parent)
+	if err != nil {
+		return nil, err
+	}
+	if !models.RoleAtLeast(role, models.RoleEditor) {
+		return nil, fmt.Errorf("父任务 %d 不存在或无权使用", *parentID)
+	}
+	if todoID != 0 {
+		if err := models.CheckMoveTarget(models.DB, parent.UserID, todoID, parentID); err != nil {
+			return nil, err
+		}
+	}
+	return &parent, nil
+}
+
+// loadUserTodoWithDescendants 加载用户在工作区中能看到的待办事项及其所有子孙节点
+// 能看到即至少是 viewer，修改前调用方还需检查角色
+func loadUserTodoWithDescendants(userID, workspaceID uint, id string) (models.Todo, []models.Todo, error) {
+	var todo models.Todo
+	if err := models.VisibleTodos(models.DB.Preload("Tags"), userID, workspaceID).Where("id = ?", id).First(&todo).Error; err != nil {
+		return todo, nil, err
+	}
+	descendants, err := models.LoadDescendants(models.DB.Preload("Tags"), todo.UserID, todo.ID)
+	return todo, descendants, err
+}
+
+// GetTodoSubtree 返回待办事项及其所有子任务组成的树
+func GetTodoSubtree(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "用户未认证"})
+		return
+	}
+
+	todo, descendants, err := loadUserTodoWithDescendants(userID.(uint), c.GetUint("workspace_id"), c.Param("id"))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "待办事项未找到或无权访问"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "获取子任务失败"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, models.BuildSubtree(todo, descendants))
+}
+
+// GetTodoProgress 返回待办事项的子任务完成情况，如 3/5 个子任务已完成
+func GetTodoProgress(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "用户未认证"})
+		return
+	}
+
+	todo, descendants, err := loadUserTodoWithDescendants(userID.(uint), c.GetUint("workspace_id"), c.Param("id"))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "待办事项未找到或无权访问"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "获取子任务失败"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, models.RollupProgress(todo, descendants))
+}
+
+// MoveTodoRequest 移动待办事项的请求结构，至少包含一个字段
+// parent_id 改变父任务；before/after 为目标清单中的相邻待办事项，用于手动排序
+type MoveTodoRequest struct {
+	ParentID optionalID `json:"parent_id"`
+	Before   *uint      `json:"before"` // 移动到该待办事项之前
+	After    *uint      `json:"after"`  // 移动到该待办事项之后
+}
+
+// errSubtaskProject 子任务总是与父任务在同一项目中，不能单独移到其他项目
+var errSubtaskProject = errors.New("子任务必须与父任务在同一项目中，请移动父任务或先将其变为顶层任务")
+
+// errParentProject 同时指定父任务和项目时，项目必须是父任务所在的项目
+var errParentProject = errors.New("project_id 与父任务所在的项目不一致")
+
+// resolveTargetList 校验将待办事项移到清单 list，MoveTodo 和 UpdateTodo 共用
+// 父任务变化时检查新父任务，并将 list 的项目改为父任务所在的项目 (整棵子树跟随)；
+// 父任务不变而项目变化时检查目标项目，子任务不能离开父任务单独移到其他项目。
+// 返回新的父任务，父任务未变化或变为顶层任务时为 nil
+func resolveTargetList(userID uint, todo *models.Todo, list *models.TodoList) (*models.Todo, error) {
+	if !sameID(list.ParentID, todo.ParentID) {
+		parent, err := checkTargetParent(userID, todo.WorkspaceID, todo.ID, list.ParentID)
+		if err != nil {
+			return nil, err
+		}
+		if parent != nil {
+			if parent.UserID != todo.UserID {
+				return nil, errOtherOwner
+			}
+			list.ProjectID = parent.ProjectID
+			return parent, nil
+		}
+	}
+	if sameID(list.ProjectID, todo.ProjectID) {
+		return nil, nil
+	}
+	if list.ParentID != nil {
+		return nil, errSubtaskProject
+	}
+	// 移入其他项目时，同样需要对目标项目有 editor 角色
+	ownerID, err := checkTargetProject(userID, todo.WorkspaceID, list.ProjectID)
+	if err != nil {
+		return nil, err
+	}
+	if ownerID != todo.UserID {
+		return nil, errOtherOwner
+	}
+	return nil, nil
+}
+
+// moveSubtreeToProject 将待办事项及其所有子孙 (subtreeIDs) 一起移到项目 projectID
+func moveSubtreeToProject(tx *gorm.DB, subtreeIDs []uint, projectID *uint) error {
+	return tx.Model(&models.Todo{}).Where("id IN ?", subtreeIDs).Update("project_id", projectID).Error
+}
+
+// loadMoveAnchor 加载排序参照的待办事项，id 为空时返回 nil
+// 参照项必须是用户能看到的、与被移动的待办事项属于同一所有者 (因而也属于同一工作区)
+func loadMoveAnchor(userID uint, todo *models.Todo, id *uint) (*models.Todo, error) {
+	if id == nil {
+		return nil, nil
+	}
+	if *id == todo.ID {
+		return nil, errors.New("不能以自身作为排序参照")
+	}
+	var anchor models.Todo
+	if err := models.VisibleTodos(models.DB, userID, todo.WorkspaceID).Where("id = ? AND user_id = ?", *id, todo.UserID).First(&anchor).Error; err != nil {
+		return nil, fmt.Errorf("排序参照的待办事项 %d 不存在或无权使用", *id)
+	}
+	return &anchor, nil
+}
+
+// MoveTodo 调整待办事项在清单中的位置，或连同子任务移动到另一个父任务下 (带缓存清除)
+// 传 before/after 时移动到参照项所在的清单并排在其前/后，只修改被移动的这一条记录；
+// 只传 parent_id 时排到新父任务下的末尾。移动到其他父任务下时，整棵子树跟随新父任务所属的项目
+func MoveTodo(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "用户未认证"})
+		return
+	}
+	currentUserID := userID.(uint)
+
+	todo, descendants, err := loadUserTodoWithDescendants(currentUserID, c.GetUint("workspace_id"), c.Param("id"))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "待办事项未找到或无权更新"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "移动待办事项失败"})
+		}
+		return
+	}
+	if !checkTodoRole(c, currentUserID, &todo, models.RoleEditor) {
+		return
+	}
+
+	var req MoveTodoRequest
+	if err := c.ShouldBindJSON(&req); err != nil || (!req.ParentID.Set && req.Before == nil && req.After == nil) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求体必须包含 parent_id、before 或 after 字段"})
+		return
+	}
+	after, err := loadMoveAnchor(currentUserID, &todo, req.After)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	before, err := loadMoveAnchor(currentUserID, &todo, req.Before)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 确定目标清单：有参照项时为参照项所在的清单，否则为 parent_id 指定的父任务下
+	list := models.ListOf(&todo)
+	if req.ParentID.Set {
+		list.ParentID = req.ParentID.Value
+	}
+	anchor := after
+	if anchor == nil {
+		anchor = before
+	}
+	if anchor != nil {
+		anchorList := models.ListOf(anchor)
+		if after != nil && before != nil &&
+			(!sameID(after.ProjectID, before.ProjectID) || !sameID(after.ParentID, before.ParentID)) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "before 和 after 必须属于同一清单"})
+			return
+		}
+		if req.ParentID.Set && !sameID(anchorList.ParentID, req.ParentID.Value) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "parent_id 与 before/after 所在的清单不一致"})
+			return
+		}
+		list = anchorList
+	}
+	parentChanged := !sameID(list.ParentID, todo.ParentID)
+	parent, err := resolveTargetList(currentUserID, &todo, &list)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	projectChanged := !sameID(list.ProjectID, todo.ProjectID)
+	oldProjectID := todo.ProjectID
+
+	subtreeIDs := append([]uint{todo.ID}, models.TodoIDs(descendants)...)
+	var rebalanced []uint
+	err = models.DB.Transaction(func(tx *gorm.DB) error {
+		key, changed, err := models.PositionBetween(tx, list, &todo, after, before)
+		if err != nil {
+			return err
+		}
+		rebalanced = changed
+		before, err := models.SnapshotTodos(tx, subtreeIDs)
+		if err != nil {
+			return err
+		}
+		updates := map[string]interface{}{"position": key}
+		if parentChanged {
+			updates["parent_id"] = list.ParentID
+		}
+		if err := tx.Model(&todo).Updates(updates).Error; err != nil {
+			return err
+		}
+		if projectChanged || parent != nil {
+			if err := moveSubtreeToProject(tx, subtreeIDs, list.ProjectID); err != nil {
+				return err
+			}
+		}
+		// 只调整顺序时没有记录的字段变化，不产生修订记录
+		return models.RecordRevisions(tx, currentUserID, models.RevisionMove, before)
+	})
+	if errors.Is(err, position.ErrOutOfOrder) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "after 必须排在 before 之前"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "移动待办事项失败"})
+		return
+	}
+
+	// --- 清除相关缓存 ---
+	clearSharedCache(todo.UserID, oldProjectID, list.ProjectID)
+	for _, id := range append(subtreeIDs, rebalanced...) {
+		clearTodoCache(id)
+	}
+
+	todo, descendants, err = loadUserTodoWithDescendants(currentUserID, c.GetUint("workspace_id"), c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取子任务失败"})
+		return
+	}
+	c.JSON(http.StatusOK, models.BuildSubtree(todo, descendants))
+}
@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"todolist/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TestSubtreeFollowsParentProject 通过 PUT 和 /move 修改项目或父任务后，子任务总是与父任务在同一项目中
+func TestSubtreeFollowsParentProject(t *testing.T) {
+	requireTestDB(t)
+	userID, _ := createTestUser(t, "mover")
+	client := testClient{t: t, router: testRouter(), userID: userID}
+
+	var p1, p2 models.Project
+	client.mustDo(http.StatusCreated, "POST", "/api/projects", gin.H{"name": "项目一"}, &p1)
+	client.mustDo(http.StatusCreated, "POST", "/api/projects", gin.H{"name": "项目二"}, &p2)
+	newTodo := func(fields gin.H) uint {
+		t.Helper()
+		var todo models.Todo
+		client.mustDo(http.StatusCreated, "POST", "/api/todos", gin.H{"todo": fields}, &todo)
+		return todo.ID
+	}
+	wantProject := func(want uint, ids ...uint) {
+		t.Helper()
+		for _, id := range ids {
+			var todo models.Todo
+			models.DB.First(&todo, id)
+			if todo.ProjectID == nil {
+				t.Errorf("待办事项 %d 不属于任何项目, want %d", id, want)
+			} else if *todo.ProjectID != want {
+				t.Errorf("待办事项 %d 的项目 = %d, want %d", id, *todo.ProjectID, want)
+			}
+		}
+	}
+
+	parent := newTodo(gin.H{"title": "父任务", "project_id": p1.ID})
+	child := newTodo(gin.H{"title": "子任务", "parent_id": parent})
+	grandchild := newTodo(gin.H{"title": "孙任务", "parent_id": child})
+	wantProject(p1.ID, child, grandchild)
+
+	// 修改父任务的项目，整棵子树跟随
+	client.mustDo(http.StatusOK, "PUT", fmt.Sprintf("/api/todos/%d", parent), gin.H{"project_id": p2.ID}, nil)
+	wantProject(p2.ID, parent, child, grandchild)
+
+	// 子任务不能单独移到其他项目，父任务与项目不一致时拒绝
+	client.mustDo(http.StatusBadRequest, "PUT", fmt.Sprintf("/api/todos/%d", child), gin.H{"project_id": p1.ID}, nil)
+	other := newTodo(gin.H{"title": "其他任务", "project_id": p1.ID})
+	client.mustDo(http.StatusBadRequest, "PUT", fmt.Sprintf("/api/todos/%d", other), gin.H{"parent_id": parent, "project_id": p1.ID}, nil)
+	client.mustDo(http.StatusBadRequest, "POST", "/api/todos", gin.H{"todo": gin.H{"title": "新子任务", "parent_id": parent, "project_id": p1.ID}}, nil)
+	wantProject(p2.ID, child)
+
+	// PUT parent_id 与 /move 的结果相同：连同子任务移到新父任务所在的项目
+	otherChild := newTodo(gin.H{"title": "其他任务的子任务", "parent_id": other})
+	client.mustDo(http.StatusOK, "PUT", fmt.Sprintf("/api/todos/%d", other), gin.H{"parent_id": parent}, nil)
+	wantProject(p2.ID, other, otherChild)
+
+	moved := newTodo(gin.H{"title": "移动的任务", "project_id": p1.ID})
+	movedChild := newTodo(gin.H{"title": "移动的任务的子任务", "parent_id": moved})
+	client.mustDo(http.StatusOK, "POST", fmt.Sprintf("/api/todos/%d/move", moved), gin.H{"parent_id": parent}, nil)
+	wantProject(p2.ID, moved, movedChild)
+
+	// 变为顶层任务并同时指定项目
+	client.mustDo(http.StatusOK, "PUT", fmt.Sprintf("/api/todos/%d", other), gin.H{"parent_id": nil, "project_id": p1.ID}, nil)
+	wantProject(p1.ID, other, otherChild)
+}
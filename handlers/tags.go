@@ -0,0 +1,333 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"todolist/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// 标签名最大长度 (按字符计)
+const maxTagNameRunes = 64
+
+// tagColorPattern 标签颜色格式，如 #ff9800
+var tagColorPattern = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+// TagRequest 创建/修改标签请求结构，修改时字段均可选
+type TagRequest struct {
+	Name  *string `json:"name"`
+	Color *string `json:"color"`
+}
+
+// validate 校验并规范化请求中的字段
+func (req *TagRequest) validate() error {
+	if req.Name != nil {
+		name := strings.TrimSpace(*req.Name)
+		if name == "" {
+			return fmt.Errorf("标签名不能为空")
+		}
+		if len([]rune(name)) > maxTagNameRunes {
+			return fmt.Errorf("标签名不能超过 %d 个字符", maxTagNameRunes)
+		}
+		req.Name = &name
+	}
+	if req.Color != nil && !tagColorPattern.MatchString(*req.Color) {
+		return fmt.Errorf("颜色格式应为 #RRGGBB")
+	}
+	return nil
+}
+
+// tagNameTaken 检查用户在工作区中是否已有同名标签 (排除 excludeID)
+func tagNameTaken(userID, workspaceID uint, name string, excludeID uint) bool {
+	var count int64
+	models.DB.Model(&models.Tag{}).Where("user_id = ? AND workspace_id = ? AND name = ? AND id <> ?", userID, workspaceID, name, excludeID).Count(&count)
+	return count > 0
+}
+
+// clearTagCache 标签被修改或删除后，清除打了该标签的待办事项缓存和用户列表缓存
+func clearTagCache(userID uint, todoIDs []uint) {
+	for _, id := range todoIDs {
+		clearTodoCache(id)
+	}
+	clearUserCache(userID)
+	// 共享项目中打了该标签的待办事项同样出现在成员的列表中
+	clearTodoMembersCache(todoIDs)
+	fmt.Printf("Cache cleared for user %d and %d tagged todos\n", userID, len(todoIDs)) // 日志
+}
+
+// GetTags 获取当前用户在当前工作区中的所有标签
+func GetTags(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "用户未认证"})
+		return
+	}
+
+	var tags []models.Tag
+	if err := models.DB.Where("user_id = ? AND workspace_id = ?", userID, c.GetUint("workspace_id")).Order("name ASC").Find(&tags).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取标签失败"})
+		return
+	}
+	c.JSON(http.StatusOK, tags)
+}
+
+// CreateTag 在当前工作区中创建标签
+func CreateTag(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "用户未认证"})
+		return
+	}
+	currentUserID := userID.(uint)
+	workspaceID := c.GetUint("workspace_id")
+
+	var req TagRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求数据"})
+		return
+	}
+	if req.Name == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "标签名不能为空"})
+		return
+	}
+	if err := req.validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if tagNameTaken(currentUserID, workspaceID, *req.Name, 0) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "标签名已存在"})
+		return
+	}
+
+	tag := models.Tag{UserID: currentUserID, WorkspaceID: workspaceID, Name: *req.Name, Color: models.DefaultTagColor}
+	if req.Color != nil {
+		tag.Color = *req.Color
+	}
+	if err := models.DB.Create(&tag).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "创建标签失败"})
+		return
+	}
+	c.JSON(http.StatusCreated, tag)
+}
+
+// UpdateTag 修改标签名称或颜色 (带缓存清除)
+func UpdateTag(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "用户未认证"})
+		return
+	}
+	currentUserID := userID.(uint)
+
+	var tag models.Tag
+	if err := models.DB.Where("id = ? AND user_id = ? AND workspace_id = ?", c.Param("id"), currentUserID, c.GetUint("workspace_id")).First(&tag).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "标签未找到或无权修改"})
+		return
+	}
+
+	var req TagRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求数据"})
+		return
+	}
+	if err := req.validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	updates := map[string]interface{}{}
+	if req.Name != nil && *req.Name != tag.Name {
+		if tagNameTaken(currentUserID, tag.WorkspaceID, *req.Name, tag.ID) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "标签名已存在"})
+			return
+		}
+		updates["name"] = *req.Name
+	}
+	if req.Color != nil {
+		updates["color"] = *req.Color
+	}
+	if len(updates) == 0 {
+		c.JSON(http.StatusOK, tag)
+		return
+	}
+	if err := models.DB.Model(&tag).Updates(updates).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "更新标签失败"})
+		return
+	}
+
+	// --- 清除相关缓存 ---
+	// 待办事项的缓存中包含标签名称和颜色，需要一并失效
+	todoIDs, err := models.TagTodoIDs(tag.ID)
+	if err != nil {
+		fmt.Printf("查询标签 %d 关联的待办事项失败: %v\n", tag.ID, err)
+	}
+	clearTagCache(currentUserID, todoIDs)
+
+	c.JSON(http.StatusOK, tag)
+}
+
+// DeleteTag 删除标签并解除与待办事项的关联 (带缓存清除)
+func DeleteTag(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "用户未认证"})
+		return
+	}
+	currentUserID := userID.(uint)
+
+	var tag models.Tag
+	if err := models.DB.Where("id = ? AND user_id = ? AND workspace_id = ?", c.Param("id"), currentUserID, c.GetUint("workspace_id")).First(&tag).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "标签未找到或无权删除"})
+		return
+	}
+
+	// 删除前记下关联的待办事项，用于清除缓存
+	todoIDs, err := models.TagTodoIDs(tag.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "删除标签失败"})
+		return
+	}
+	err = models.DB.Transaction(func(tx *gorm.DB) error {
+		before, err := models.SnapshotTodos(tx, todoIDs)
+		if err != nil {
+			return err
+		}
+		if err := tx.Table("todo_tags").Where("tag_id = ?", tag.ID).Delete(nil).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(&tag).Error; err != nil {
+			return err
+		}
+		return models.RecordRevisions(tx, currentUserID, models.RevisionUpdate, before)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "删除标签失败"})
+		return
+	}
+
+	// --- 清除相关缓存 ---
+	clearTagCache(currentUserID, todoIDs)
+
+	c.Status(http.StatusNoContent)
+}
+
+// TodoTagsRequest 为待办事项添加标签的请求结构
+type TodoTagsRequest struct {
+	TagIDs []uint `json:"tag_ids" binding:"required"`
+}
+
+// findUserTags 查找用户在工作区中的标签，任一标签不存在、不属于该用户或不在该工作区时返回错误
+func findUserTags(userID, workspaceID uint, tagIDs []uint) ([]models.Tag, error) {
+	var tags []models.Tag
+	if len(tagIDs) == 0 {
+		return tags, nil
+	}
+	if err := models.DB.Where("id IN ? AND user_id = ? AND workspace_id = ?", tagIDs, userID, workspaceID).Find(&tags).Error; err != nil {
+		return nil, err
+	}
+	found := make(map[uint]bool, len(tags))
+	for _, t := range tags {
+		found[t.ID] = true
+	}
+	for _, id := range tagIDs {
+		if !found[id] {
+			return nil, fmt.Errorf("标签 %d 不存在或无权使用", id)
+		}
+	}
+	return tags, nil
+}
+
+// AddTodoTags 为待办事项添加标签 (带缓存清除)
+// 标签属于待办事项的所有者且在同一工作区，共享项目的成员使用所有者的标签
+func AddTodoTags(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "用户未认证"})
+		return
+	}
+	currentUserID := userID.(uint)
+
+	todo, ok := loadAccessibleTodo(c, currentUserID, models.RoleEditor)
+	if !ok {
+		return
+	}
+
+	var req TodoTagsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求数据"})
+		return
+	}
+	tags, err := findUserTags(todo.UserID, todo.WorkspaceID, req.TagIDs)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Append 对已存在的关联不会重复插入
+	err = models.DB.Transaction(func(tx *gorm.DB) error {
+		before, err := models.SnapshotTodos(tx, []uint{todo.ID})
+		if err != nil {
+			return err
+		}
+		if err := tx.Model(todo).Association("Tags").Append(tags); err != nil {
+			return err
+		}
+		return models.RecordRevisions(tx, currentUserID, models.RevisionUpdate, before)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "添加标签失败"})
+		return
+	}
+
+	// --- 清除相关缓存 ---
+	clearSharedCache(todo.UserID, todo.ProjectID)
+	clearTodoCache(todo.ID)
+
+	models.DB.Preload("Tags").First(todo, todo.ID)
+	c.JSON(http.StatusOK, todo)
+}
+
+// RemoveTodoTag 移除待办事项上的某个标签 (带缓存清除)
+func RemoveTodoTag(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "用户未认证"})
+		return
+	}
+	currentUserID := userID.(uint)
+
+	todo, ok := loadAccessibleTodo(c, currentUserID, models.RoleEditor)
+	if !ok {
+		return
+	}
+	var tag models.Tag
+	if err := models.DB.Where("id = ? AND user_id = ? AND workspace_id = ?", c.Param("tag_id"), todo.UserID, todo.WorkspaceID).First(&tag).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "标签未找到"})
+		return
+	}
+
+	err := models.DB.Transaction(func(tx *gorm.DB) error {
+		before, err := models.SnapshotTodos(tx, []uint{todo.ID})
+		if err != nil {
+			return err
+		}
+		if err := tx.Model(todo).Association("Tags").Delete(&tag); err != nil {
+			return err
+		}
+		return models.RecordRevisions(tx, currentUserID, models.RevisionUpdate, before)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "移除标签失败"})
+		return
+	}
+
+	// --- 清除相关缓存 ---
+	clearSharedCache(todo.UserID, todo.ProjectID)
+	clearTodoCache(todo.ID)
+
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,381 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+	"todolist/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	// 统计和导出时间记录的最大天数
+	maxTimeRangeDays = 366
+	// 时间记录未指定范围时统计最近的天数
+	defaultTimeRangeDays = 7
+	// 时间记录备注的最大字符数
+	maxTimeEntryNoteRunes = 255
+	// 结束时间允许超过服务器当前时间的误差，容忍客户端时钟偏差
+	timeEntryClockSkew = time.Minute
+)
+
+// TimeEntryRequest 手动补录时间记录的请求结构，ended_at 和 duration_minutes 二选一
+type TimeEntryRequest struct {
+	StartedAt       *time.Time `json:"started_at"`
+	EndedAt         *time.Time `json:"ended_at"`
+	DurationMinutes *int       `json:"duration_minutes"`
+	Note            string     `json:"note"`
+}
+
+// toEntry 校验请求并生成时间记录
+func (req *TimeEntryRequest) toEntry(now time.Time) (*models.TimeEntry, error) {
+	if req.StartedAt == nil {
+		return nil, errors.New("started_at 不能为空")
+	}
+	if (req.EndedAt == nil) == (req.DurationMinutes == nil) {
+		return nil, errors.New("ended_at 和 duration_minutes 必须且只能传一个")
+	}
+	end := req.EndedAt
+	if req.DurationMinutes != nil {
+		t := req.StartedAt.Add(time.Duration(*req.DurationMinutes) * time.Minute)
+		end = &t
+	}
+	if !end.After(*req.StartedAt) {
+		return nil, errors.New("结束时间必须晚于开始时间")
+	}
+	if end.Sub(*req.StartedAt) > models.MaxTimeEntryDuration {
+		return nil, fmt.Errorf("单条时间记录不能超过 %d 小时", int(models.MaxTimeEntryDuration/time.Hour))
+	}
+	if end.After(now.Add(timeEntryClockSkew)) {
+		return nil, errors.New("不能补录未来的时间")
+	}
+	if len([]rune(req.Note)) > maxTimeEntryNoteRunes {
+		return nil, fmt.Errorf("备注不能超过 %d 个字符", maxTimeEntryNoteRunes)
+	}
+	return &models.TimeEntry{StartedAt: *req.StartedAt, EndedAt: end, Source: models.TimeEntryManual, Note: req.Note}, nil
+}
+
+// parseDayRange 解析 from/to 参数 (YYYY-MM-DD，包含两端，按 loc 解释)，返回两端日期在 loc 中的零点
+// 都不传时为截至今天的最近 defaultDays 天
+func parseDayRange(c *gin.Context, now time.Time, loc *time.Location, defaultDays int) (from, to time.Time, err error) {
+	y, m, d := now.In(loc).Date()
+	to = time.Date(y, m, d, 0, 0, 0, 0, loc)
+	if v := c.Query("to"); v != "" {
+		if to, err = time.ParseInLocation("2006-01-02", v, loc); err != nil {
+			return from, to, errors.New("to 必须为 YYYY-MM-DD")
+		}
+	}
+	from = to.AddDate(0, 0, -(defaultDays - 1))
+	if v := c.Query("from"); v != "" {
+		if from, err = time.ParseInLocation("2006-01-02", v, loc); err != nil {
+			return from, to, errors.New("from 必须为 YYYY-MM-DD")
+		}
+	}
+	if from.After(to) {
+		return from, to, errors.New("from 不能晚于 to")
+	}
+	if from.AddDate(0, 0, maxTimeRangeDays).Before(to) {
+		return from, to, fmt.Errorf("时间范围不能超过 %d 天", maxTimeRangeDays)
+	}
+	return from, to, nil
+}
+
+// StartTodoTimer 为待办事项启动计时器，同一用户正在运行的其他计时器会被停止
+func StartTodoTimer(c *gin.Context) {
+	// 从上下文中获取当前用户ID
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "用户未认证"})
+		return
+	}
+	currentUserID := userID.(uint)
+
+	todo, ok := loadAccessibleTodo(c, currentUserID, models.RoleEditor)
+	if !ok {
+		return
+	}
+
+	var entry, stopped *models.TimeEntry
+	err := models.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		entry, stopped, err = models.StartTimer(tx, currentUserID, todo.ID, time.Now())
+		return err
+	})
+	if errors.Is(err, models.ErrTimerRunning) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "启动计时器失败"})
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"entry": entry, "stopped": stopped})
+}
+
+// StopTodoTimer 停止待办事项上正在运行的计时器
+func StopTodoTimer(c *gin.Context) {
+	// 从上下文中获取当前用户ID
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "用户未认证"})
+		return
+	}
+	currentUserID := userID.(uint)
+
+	todo, ok := loadAccessibleTodo(c, currentUserID, models.RoleEditor)
+	if !ok {
+		return
+	}
+
+	var entry *models.TimeEntry
+	err := models.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		entry, err = models.StopTimer(tx, currentUserID, todo.ID, time.Now())
+		return err
+	})
+	if errors.Is(err, models.ErrTimerNotRunning) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "停止计时器失败"})
+		return
+	}
+	c.JSON(http.StatusOK, entry)
+}
+
+// GetRunningTimer 返回当前用户正在运行的计时器，没有时 entry 为 null
+func GetRunningTimer(c *gin.Context) {
+	// 从上下文中获取当前用户ID
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "用户未认证"})
+		return
+	}
+	currentUserID := userID.(uint)
+
+	entry, err := models.RunningTimer(models.DB, currentUserID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取计时器失败"})
+		return
+	}
+	resp := gin.H{"entry": entry}
+	if entry != nil {
+		resp["elapsed_seconds"] = int64(entry.Duration(time.Now()) / time.Second)
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// GetTodoTimeEntries 返回待办事项的时间记录 (最近开始的在前) 和累计时长
+func GetTodoTimeEntries(c *gin.Context) {
+	// 从上下文中获取当前用户ID
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "用户未认证"})
+		return
+	}
+	currentUserID := userID.(uint)
+
+	todo, ok := loadAccessibleTodo(c, currentUserID, models.RoleViewer)
+	if !ok {
+		return
+	}
+	now := time.Now()
+	var entries []models.TimeEntry
+	if err := models.DB.Where("todo_id = ?", todo.ID).Order("started_at DESC").Order("id DESC").Find(&entries).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取时间记录失败"})
+		return
+	}
+	total, err := models.TodoTimeTotal(models.DB, todo.ID, now)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取时间记录失败"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"entries": entries, "total_seconds": total})
+}
+
+// CreateTimeEntry 为待办事项手动补录一段时间
+func CreateTimeEntry(c *gin.Context) {
+	// 从上下文中获取当前用户ID
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "用户未认证"})
+		return
+	}
+	currentUserID := userID.(uint)
+
+	todo, ok := loadAccessibleTodo(c, currentUserID, models.RoleEditor)
+	if !ok {
+		return
+	}
+	var req TimeEntryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求数据"})
+		return
+	}
+	entry, err := req.toEntry(time.Now())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	entry.UserID, entry.TodoID = currentUserID, todo.ID
+	if err := models.DB.Create(entry).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "补录时间失败"})
+		return
+	}
+	c.JSON(http.StatusCreated, entry)
+}
+
+// DeleteTimeEntry 删除当前用户在当前工作区中的一条时间记录，正在运行的计时器同样可以删除 (放弃本次计时)
+func DeleteTimeEntry(c *gin.Context) {
+	// 从上下文中获取当前用户ID
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "用户未认证"})
+		return
+	}
+	currentUserID := userID.(uint)
+
+	result := models.DB.Where("id = ? AND user_id = ? AND todo_id IN (?)", c.Param("id"), currentUserID, models.WorkspaceTodoIDs(c.GetUint("workspace_id"))).
+		Delete(&models.TimeEntry{})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "删除时间记录失败"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "时间记录未找到或无权删除"})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// GetDailyTimeTotals 按天汇总当前用户在当前工作区中的时长，?from=&to= 为用户时区中的日期 (包含两端)
+// 跨越午夜的记录分别计入两天，正在运行的计时到当前时间
+func GetDailyTimeTotals(c *gin.Context) {
+	// 从上下文中获取当前用户ID
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "用户未认证"})
+		return
+	}
+	currentUserID := userID.(uint)
+
+	loc, err := resolveLocation(c, currentUserID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	now := time.Now()
+	from, to, err := parseDayRange(c, now, loc, defaultTimeRangeDays)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 与范围有交集的记录
+	var entries []models.TimeEntry
+	err = models.DB.Where("user_id = ? AND started_at < ?", currentUserID, to.AddDate(0, 0, 1)).
+		Where("(ended_at IS NULL OR ended_at > ?)", from).
+		Where("todo_id IN (?)", models.WorkspaceTodoIDs(c.GetUint("workspace_id"))).Find(&entries).Error
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "统计时长失败"})
+		return
+	}
+	days := models.DailyTotals(entries, now, loc, from, to)
+	var total int64
+	for _, d := range days {
+		total += d.Seconds
+	}
+	c.JSON(http.StatusOK, gin.H{"timezone": loc.String(), "days": days, "total_seconds": total})
+}
+
+// ExportTimeEntries 导出当前用户在当前工作区中、?from=&to= 范围内开始的时间记录，?format=csv (默认) 或 json
+func ExportTimeEntries(c *gin.Context) {
+	// 从上下文中获取当前用户ID
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "用户未认证"})
+		return
+	}
+	currentUserID := userID.(uint)
+
+	format := c.DefaultQuery("format", "csv")
+	if format != "csv" && format != "json" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format 必须为 csv 或 json"})
+		return
+	}
+	loc, err := resolveLocation(c, currentUserID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	now := time.Now()
+	from, to, err := parseDayRange(c, now, loc, defaultTimeRangeDays)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var entries []models.TimeEntry
+	err = models.DB.Where("user_id = ? AND started_at >= ? AND started_at < ?", currentUserID, from, to.AddDate(0, 0, 1)).
+		Where("todo_id IN (?)", models.WorkspaceTodoIDs(c.GetUint("workspace_id"))).
+		Order("started_at ASC").Order("id ASC").Find(&entries).Error
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "导出时间记录失败"})
+		return
+	}
+	if format == "json" {
+		c.JSON(http.StatusOK, gin.H{"entries": entries})
+		return
+	}
+
+	// 回收站中的待办事项同样导出标题
+	titles := map[uint]string{}
+	todoIDs := make([]uint, 0, len(entries))
+	for _, e := range entries {
+		todoIDs = append(todoIDs, e.TodoID)
+	}
+	if len(todoIDs) > 0 {
+		var todos []models.Todo
+		if err := models.DB.Unscoped().Select("id", "title").Where("id IN ?", todoIDs).Find(&todos).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "导出时间记录失败"})
+			return
+		}
+		for _, t := range todos {
+			titles[t.ID] = t.Title
+		}
+	}
+
+	filename := fmt.Sprintf("time-entries-%s-%s.csv", from.Format("20060102"), to.Format("20060102"))
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	c.Status(http.StatusOK)
+	// 写入 UTF-8 BOM，Excel 才能正确识别中文
+	c.Writer.WriteString("\ufeff")
+	w := csv.NewWriter(c.Writer)
+	w.Write([]string{"id", "todo_id", "todo_title", "started_at", "ended_at", "duration_seconds", "source", "note"})
+	for i := range entries {
+		e := &entries[i]
+		ended := ""
+		if e.EndedAt != nil {
+			ended = e.EndedAt.In(loc).Format(time.RFC3339)
+		}
+		w.Write([]string{
+			strconv.FormatUint(uint64(e.ID), 10),
+			strconv.FormatUint(uint64(e.TodoID), 10),
+			titles[e.TodoID],
+			e.StartedAt.In(loc).Format(time.RFC3339),
+			ended,
+			strconv.FormatInt(int64(e.Duration(now)/time.Second), 10),
+			e.Source,
+			e.Note,
+		})
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		fmt.Printf("Export time entries error for user %d: %v\n", currentUserID, err)
+	}
+}
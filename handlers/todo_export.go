@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"todolist/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 一次最多导出的待办事项数
+const maxExportTodos = 10000
+
+// ExportTodos 导出当前用户能看到的待办事项 (包括共享项目中的) 及自定义字段，?format=csv (默认) 或 json
+// 支持与列表相同的筛选和排序参数，不分页；超过上限时只导出前面的部分并设置 X-Export-Truncated 响应头
+func ExportTodos(c *gin.Context) {
+	// 从上下文中获取当前用户ID
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "用户未认证"})
+		return
+	}
+	currentUserID := userID.(uint)
+
+	format := c.DefaultQuery("format", "csv")
+	if format != "csv" && format != "json" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format 必须为 csv 或 json"})
+		return
+	}
+	listQuery, err := parseTodoListQuery(c, defaultTodoSort)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	listQuery.Page = pageParams{}
+	loc, err := resolveLocation(c, currentUserID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var todos []models.Todo
+	query := listQuery.apply(models.VisibleTodos(models.DB.Preload("Tags"), currentUserID, c.GetUint("workspace_id")))
+	if err := query.Limit(maxExportTodos + 1).Find(&todos).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "导出待办事项失败"})
+		return
+	}
+	if len(todos) > maxExportTodos {
+		todos = todos[:maxExportTodos]
+		c.Header("X-Export-Truncated", "true")
+	}
+	fields, err := models.LoadCustomFields(models.DB, currentUserID, c.GetUint("workspace_id"))
+	if err == nil {
+		err = models.FillCustomFields(models.DB, todos)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "导出待办事项失败"})
+		return
+	}
+
+	if format == "json" {
+		c.JSON(http.StatusOK, gin.H{"custom_fields": fields, "todos": todos})
+		return
+	}
+
+	projects := map[uint]string{}
+	var userProjects []models.Project
+	if err := models.VisibleProjects(models.DB.Select("id", "name"), currentUserID, c.GetUint("workspace_id")).Find(&userProjects).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "导出待办事项失败"})
+		return
+	}
+	for _, p := range userProjects {
+		projects[p.ID] = p.Name
+	}
+	formatTime := func(t *time.Time) string {
+		if t == nil {
+			return ""
+		}
+		return t.In(loc).Format(time.RFC3339)
+	}
+
+	filename := fmt.Sprintf("todos-%s.csv", time.Now().In(loc).Format("20060102"))
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	c.Status(http.StatusOK)
+	// 写入 UTF-8 BOM，Excel 才能正确识别中文
+	c.Writer.WriteString("\ufeff")
+	w := csv.NewWriter(c.Writer)
+	header := []string{"id", "title", "description", "completed", "completed_at", "due_at", "all_day", "priority", "project", "tags", "estimate_minutes", "created_at", "updated_at"}
+	for _, f := range fields {
+		header = append(header, f.Name)
+	}
+	w.Write(header)
+	for i := range todos {
+		t := &todos[i]
+		due := formatTime(t.DueAt)
+		if t.DueAt != nil && t.AllDay {
+			due = t.DueAt.UTC().Format("2006-01-02")
+		}
+		project := ""
+		if t.ProjectID != nil {
+			project = projects[*t.ProjectID]
+		}
+		tags := make([]string, len(t.Tags))
+		for j, tag := range t.Tags {
+			tags[j] = tag.Name
+		}
+		estimate := ""
+		if t.EstimateMinutes != nil {
+			estimate = strconv.Itoa(*t.EstimateMinutes)
+		}
+		record := []string{
+			strconv.FormatUint(uint64(t.ID), 10),
+			t.Title,
+			t.Description,
+			strconv.FormatBool(t.Completed),
+			formatTime(t.CompletedAt),
+			due,
+			strconv.FormatBool(t.AllDay),
+			t.Priority.String(),
+			project,
+			strings.Join(tags, "; "),
+			estimate,
+			formatTime(&t.CreatedAt),
+			formatTime(&t.UpdatedAt),
+		}
+		for _, f := range fields {
+			record = append(record, models.FormatCustomValue(t.CustomFields[strconv.FormatUint(uint64(f.ID), 10)]))
+		}
+		w.Write(record)
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		fmt.Printf("Export todos error for user %d: %v\n", currentUserID, err)
+	}
+}
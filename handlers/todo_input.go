@@ -0,0 +1,199 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+	"todolist/calendar"
+	"todolist/models"
+)
+
+// dueInput 请求体中的 due_at 字段
+// 取值可以是 RFC3339 时间 (定时待办)、YYYY-MM-DD 日期 (全天待办) 或 null (清除截止时间)
+type dueInput struct {
+	Set    bool       // 请求体中是否出现了该字段
+	At     *time.Time // 为 nil 表示清除截止时间
+	AllDay bool
+}
+
+// UnmarshalJSON 解析 due_at，字段值为 null 时也会被调用，从而区分 "未传" 和 "清除"
+func (d *dueInput) UnmarshalJSON(b []byte) error {
+	d.Set = true
+	if bytes.Equal(b, []byte("null")) {
+		d.At, d.AllDay = nil, false
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return errors.New("due_at 必须为字符串或 null")
+	}
+	if s == "" {
+		d.At, d.AllDay = nil, false
+		return nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		d.At, d.AllDay = &t, false
+		return nil
+	}
+	if t, err := time.Parse("2006-01-02", s); err == nil {
+		// 全天待办存储为该日期的 UTC 零点
+		d.At, d.AllDay = &t, true
+		return nil
+	}
+	return errors.New("due_at 必须为 RFC3339 时间或 YYYY-MM-DD 日期")
+}
+
+// applyTo 将截止时间写入待办事项
+func (d dueInput) applyTo(todo *models.Todo) {
+	if d.Set {
+		todo.DueAt, todo.AllDay = d.At, d.AllDay
+	}
+}
+
+// columns 返回需要更新的数据库列
+func (d dueInput) columns() map[string]interface{} {
+	if !d.Set {
+		return nil
+	}
+	return map[string]interface{}{"due_at": d.At, "all_day": d.AllDay}
+}
+
+// optionalID 请求体中可为 null 的关联ID字段，如 project_id
+type optionalID struct {
+	Set   bool  // 请求体中是否出现了该字段
+	Value *uint // 为 nil 表示解除关联
+}
+
+// UnmarshalJSON 解析关联ID，字段值为 null 时也会被调用
+func (o *optionalID) UnmarshalJSON(b []byte) error {
+	o.Set = true
+	if bytes.Equal(b, []byte("null")) {
+		o.Value = nil
+		return nil
+	}
+	var id uint
+	if err := json.Unmarshal(b, &id); err != nil || id == 0 {
+		return errors.New("关联ID必须为正整数或 null")
+	}
+	o.Value = &id
+	return nil
+}
+
+// 预估耗时的最大分钟数 (1000 小时)
+const maxEstimateMinutes = 60000
+
+// optionalMinutes 请求体中可为 null 的分钟数字段，如 estimate_minutes
+type optionalMinutes struct {
+	Set   bool // 请求体中是否出现了该字段
+	Value *int // 为 nil 表示清除
+}
+
+// UnmarshalJSON 解析分钟数，字段值为 null 时也会被调用
+func (o *optionalMinutes) UnmarshalJSON(b []byte) error {
+	o.Set = true
+	if bytes.Equal(b, []byte("null")) {
+		o.Value = nil
+		return nil
+	}
+	var n int
+	if err := json.Unmarshal(b, &n); err != nil || n < 0 || n > maxEstimateMinutes {
+		return fmt.Errorf("estimate_minutes 必须为 0 到 %d 之间的整数或 null", maxEstimateMinutes)
+	}
+	o.Value = &n
+	return nil
+}
+
+// sameID 比较两个可为空的关联ID是否相同
+func sameID(a, b *uint) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// sameTime 比较两个可为空的时间是否相同
+func sameTime(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
+
+// todoInput 创建待办事项的请求结构
+// 嵌入 models.Todo 以复用其字段，需要特殊解析的字段在这里覆盖
+type todoInput struct {
+	models.Todo
+	DueAt     dueInput         `json:"due_at"`
+	Priority  *models.Priority `json:"priority"`   // 指针用于区分更新时 "未传" 和 "none"
+	TagIDs    []uint           `json:"tag_ids"`    // 创建时关联的标签，更新标签请使用标签接口
+	ProjectID optionalID       `json:"project_id"` // 所属项目，更新时传 null 移出项目
+	ParentID  optionalID       `json:"parent_id"`  // 父任务，更新时传 null 变为顶层任务
+	// 创建时设置的重复规则，修改规则请使用重复规则接口
+	Recurrence *recurrenceInput `json:"recurrence"`
+	// 是否已完成，覆盖 models.Todo 中的字段，指针用于区分更新时 "未传" 和 "未完成"
+	Completed *bool `json:"completed"`
+	// 预估耗时 (分钟)，更新时传 null 清除
+	EstimateMinutes optionalMinutes `json:"estimate_minutes"`
+	// 自定义字段的值，键为字段ID，更新时传 null 清除该字段，未出现的字段不变
+	CustomFields map[string]json.RawMessage `json:"custom_fields"`
+	// 解析后的自定义字段值，由 parseCustomFields 填充
+	customValues map[uint]*models.CustomFieldValue
+	// 以工作日计的截止日期，如 3 表示今天之后第 3 个工作日 (全天)，0 表示今天或之后的第一个工作日
+	DueInWorkdays *int `json:"due_in_workdays"`
+}
+
+// 工作日截止日期的最大天数
+const maxDueInWorkdays = 366
+
+// resolveWorkdayDue 按用户时区的今天和工作日历，将 due_in_workdays 换算为全天截止日期
+func (in *todoInput) resolveWorkdayDue(now time.Time, loc *time.Location) error {
+	if in.DueInWorkdays == nil {
+		return nil
+	}
+	if in.DueAt.Set {
+		return errors.New("due_at 和 due_in_workdays 不能同时设置")
+	}
+	n := *in.DueInWorkdays
+	if n < 0 || n > maxDueInWorkdays {
+		return fmt.Errorf("due_in_workdays 必须为 0 到 %d 之间的整数", maxDueInWorkdays)
+	}
+	y, m, d := now.In(loc).Date()
+	due := calendar.Default().AddWorkdays(time.Date(y, m, d, 0, 0, 0, 0, time.UTC), n)
+	in.DueAt = dueInput{Set: true, At: &due, AllDay: true}
+	return nil
+}
+
+// parseCustomFields 按用户在工作区中的字段定义校验请求中的自定义字段值
+func (in *todoInput) parseCustomFields(userID, workspaceID uint) error {
+	var err error
+	in.customValues, err = models.ParseCustomValues(models.DB, userID, workspaceID, in.CustomFields)
+	return err
+}
+
+// toTodo 转换为待办事项模型并设置所属用户和工作区
+func (in *todoInput) toTodo(userID, workspaceID uint) models.Todo {
+	todo := in.Todo
+	todo.ID = 0 // ID 由数据库生成，忽略客户端传入的值
+	todo.UserID = userID
+	// 工作区由请求头决定，忽略客户端传入的值
+	todo.WorkspaceID = workspaceID
+	todo.Tags = nil // 标签只能通过 tag_ids 关联已有标签
+	in.DueAt.applyTo(&todo)
+	if in.Priority != nil {
+		todo.Priority = *in.Priority
+	}
+	if in.Completed != nil {
+		todo.Completed = *in.Completed
+	}
+	todo.ProjectID = in.ProjectID.Value
+	todo.ParentID = in.ParentID.Value
+	todo.EstimateMinutes = in.EstimateMinutes.Value
+	todo.Children = nil
+	todo.SeriesID, todo.OccurrenceAt = nil, nil // 系列只能通过 recurrence 创建
+	// 完成时间由服务端记录，归档只能通过归档接口
+	todo.CompletedAt, todo.ArchivedAt = nil, nil
+	todo.Position = "" // 排序键由服务端分配，调整顺序使用 move 接口
+	return todo
+}
@@ -0,0 +1,703 @@
+package handlers
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"todolist/models"
+	"todolist/search"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// 排序字段的值类型，用于解析游标中的值
+type sortKind int
+
+const (
+	sortKindInt sortKind = iota
+	sortKindString
+	sortKindBool
+	sortKindTime
+	sortKindFloat
+)
+
+// sortField 允许排序的字段定义
+type sortField struct {
+	column string                         // 数据库列
+	kind   sortKind                       // 值类型
+	value  func(*models.Todo) interface{} // 从记录中取出排序值，用于生成游标
+}
+
+// todoSortFields 排序字段白名单
+var todoSortFields = map[string]sortField{
+	"title": {
+		column: "title",
+		kind:   sortKindString,
+		value:  func(t *models.Todo) interface{} { return t.Title },
+	},
+	"priority": {
+		column: "priority",
+		kind:   sortKindInt,
+		value:  func(t *models.Todo) interface{} { return int64(t.Priority) },
+	},
+	// 智能排序分数，见 models.Todo.SmartScore
+	"score": {
+		column: "score",
+		kind:   sortKindInt,
+		value:  func(t *models.Todo) interface{} { return t.Score },
+	},
+	// 手动排序键，只在同一清单 (project_id 和 parent_id 相同) 内有意义
+	"position": {
+		column: "position",
+		kind:   sortKindString,
+		value:  func(t *models.Todo) interface{} { return t.Position },
+	},
+	"completed": {
+		column: "completed",
+		kind:   sortKindBool,
+		value:  func(t *models.Todo) interface{} { return t.Completed },
+	},
+	"created_at": {
+		column: "created_at",
+		kind:   sortKindTime,
+		value:  func(t *models.Todo) interface{} { return t.CreatedAt },
+	},
+	"updated_at": {
+		column: "updated_at",
+		kind:   sortKindTime,
+		value:  func(t *models.Todo) interface{} { return t.UpdatedAt },
+	},
+	// 只用于归档列表，其中的待办事项归档时间都不为空
+	"archived_at": {
+		column: "archived_at",
+		kind:   sortKindTime,
+		value: func(t *models.Todo) interface{} {
+			if t.ArchivedAt == nil {
+				return time.Time{}
+			}
+			return *t.ArchivedAt
+		},
+	},
+	// 没有截止时间的排在最后 (升序时)，用哨兵值代替 NULL 以便游标比较
+	"due_at": {
+		column: "COALESCE(due_at, CAST('9999-12-31 23:59:59' AS DATETIME))",
+		kind:   sortKindTime,
+		value: func(t *models.Todo) interface{} {
+			if t.DueAt == nil {
+				return noDueSentinel
+			}
+			return *t.DueAt
+		},
+	},
+}
+
+// noDueSentinel 没有截止时间的待办事项在排序中使用的值
+var noDueSentinel = time.Date(9999, 12, 31, 23, 59, 59, 0, time.Local)
+
+// 没有值的自定义数字/日期字段在排序中使用的值，升序时排在最后
+const noCustomNumberSentinel = 1e308
+
+var noCustomDateSentinel = time.Date(9999, 12, 31, 0, 0, 0, 0, time.Local)
+
+// customFieldSortPrefix 自定义字段排序和筛选参数的前缀，如 sort=-cf.3、cf.3.min=10
+const customFieldSortPrefix = "cf."
+
+// customSortField 自定义字段的排序定义，通过关联子查询取值；多选字段不能排序
+// 文本和单选按文本排序，没有值的视为空字符串
+func customSortField(f *models.CustomField) (sortField, error) {
+	key := strconv.FormatUint(uint64(f.ID), 10)
+	sub := func(column string) string {
+		return fmt.Sprintf("(SELECT %s FROM custom_field_values WHERE custom_field_values.todo_id = todos.id AND custom_field_values.field_id = %d)", column, f.ID)
+	}
+	switch f.Type {
+	case models.FieldTypeText, models.FieldTypeSelect:
+		return sortField{
+			column: "COALESCE(" + sub("text_value") + ", '')",
+			kind:   sortKindString,
+			value: func(t *models.Todo) interface{} {
+				s, _ := t.CustomFields[key].(string)
+				return s
+			},
+		}, nil
+	case models.FieldTypeNumber:
+		return sortField{
+			column: fmt.Sprintf("COALESCE(%s, %g)", sub("number_value"), noCustomNumberSentinel),
+			kind:   sortKindFloat,
+			value: func(t *models.Todo) interface{} {
+				if n, ok := t.CustomFields[key].(float64); ok {
+					return n
+				}
+				return noCustomNumberSentinel
+			},
+		}, nil
+	case models.FieldTypeDate:
+		return sortField{
+			column: "COALESCE(" + sub("date_value") + ", DATE('9999-12-31'))",
+			kind:   sortKindTime,
+			value: func(t *models.Todo) interface{} {
+				s, _ := t.CustomFields[key].(string)
+				if d, err := time.ParseInLocation("2006-01-02", s, time.Local); err == nil {
+					return d
+				}
+				return noCustomDateSentinel
+			},
+		}, nil
+	}
+	return sortField{}, fmt.Errorf("自定义字段 %s 不支持排序", f.Name)
+}
+
+// customFilter 按自定义字段筛选，op 为 eq (多选字段为包含该选项)、min 或 max (包含边界)
+type customFilter struct {
+	field *models.CustomField
+	op    string
+	value *models.CustomFieldValue
+}
+
+// param 筛选条件对应的查询参数名
+func (f customFilter) param() string {
+	name := customFieldSortPrefix + strconv.FormatUint(uint64(f.field.ID), 10)
+	if f.op != "eq" {
+		name += "." + f.op
+	}
+	return name
+}
+
+// apply 将筛选条件应用到查询上
+func (f customFilter) apply(db *gorm.DB) *gorm.DB {
+	sub := models.DB.Model(&models.CustomFieldValue{}).Select("todo_id").Where("field_id = ?", f.field.ID)
+	ops := map[string]string{"eq": " = ?", "min": " >= ?", "max": " <= ?"}
+	switch f.field.Type {
+	case models.FieldTypeNumber:
+		sub = sub.Where("number_value"+ops[f.op], *f.value.NumberValue)
+	case models.FieldTypeDate:
+		sub = sub.Where("date_value"+ops[f.op], *f.value.DateValue)
+	case models.FieldTypeMultiSelect:
+		sub = sub.Where("JSON_CONTAINS(multi_value, JSON_QUOTE(?))", f.value.MultiValue[0])
+	default:
+		sub = sub.Where("text_value = ?", *f.value.TextValue)
+	}
+	return db.Where("id IN (?)", sub)
+}
+
+// parseCustomFieldQuery 解析自定义字段的筛选参数 (cf.<字段ID>=值、cf.<字段ID>.min=、cf.<字段ID>.max=)
+// 并返回排序中可以使用的自定义字段，只有用到自定义字段时才查询字段定义
+func parseCustomFieldQuery(c *gin.Context, sortRaw string) ([]customFilter, map[string]sortField, error) {
+	params := c.Request.URL.Query()
+	used := strings.Contains(sortRaw, customFieldSortPrefix)
+	for name := range params {
+		used = used || strings.HasPrefix(name, customFieldSortPrefix)
+	}
+	if !used {
+		return nil, nil, nil
+	}
+	fields, err := models.LoadCustomFields(models.DB, c.GetUint("user_id"), c.GetUint("workspace_id"))
+	if err != nil {
+		return nil, nil, err
+	}
+	byID := make(map[string]*models.CustomField, len(fields))
+	sortFields := make(map[string]sortField, len(fields))
+	for i := range fields {
+		key := strconv.FormatUint(uint64(fields[i].ID), 10)
+		byID[key] = &fields[i]
+		if sf, err := customSortField(&fields[i]); err == nil {
+			sortFields[customFieldSortPrefix+key] = sf
+		}
+	}
+
+	var filters []customFilter
+	for name := range params {
+		if !strings.HasPrefix(name, customFieldSortPrefix) {
+			continue
+		}
+		key, op, _ := strings.Cut(strings.TrimPrefix(name, customFieldSortPrefix), ".")
+		field := byID[key]
+		if field == nil {
+			return nil, nil, fmt.Errorf("自定义字段不存在: %s", key)
+		}
+		switch op {
+		case "":
+			op = "eq"
+		case "min", "max":
+			if field.Type != models.FieldTypeNumber && field.Type != models.FieldTypeDate {
+				return nil, nil, fmt.Errorf("自定义字段 %s 不支持范围筛选", field.Name)
+			}
+		default:
+			return nil, nil, fmt.Errorf("不支持的筛选参数: %s", name)
+		}
+		value, err := field.ParseQueryValue(params.Get(name))
+		if err != nil {
+			return nil, nil, err
+		}
+		filters = append(filters, customFilter{field: field, op: op, value: value})
+	}
+	sort.Slice(filters, func(i, j int) bool { return filters[i].param() < filters[j].param() })
+	return filters, sortFields, nil
+}
+
+// 最多允许的排序字段数
+const maxSortKeys = 3
+
+// defaultTodoSort 列表默认排序：按用户手动调整的顺序
+const defaultTodoSort = "position"
+
+// sortKey 一个排序条件
+type sortKey struct {
+	name  string
+	field sortField
+	desc  bool
+}
+
+// idFilter 按关联ID筛选 (如 project_id、parent_id)，ID 为 nil 表示只看没有关联的待办事项
+type idFilter struct {
+	ID *uint
+}
+
+// parseIDFilter 解析关联ID筛选参数，取值为正整数或 none
+func parseIDFilter(c *gin.Context, name string) (*idFilter, error) {
+	v := c.Query(name)
+	if v == "" {
+		return nil, nil
+	}
+	if v == "none" {
+		return &idFilter{}, nil
+	}
+	id, err := strconv.ParseUint(v, 10, 64)
+	if err != nil || id == 0 {
+		return nil, fmt.Errorf("%s 必须为正整数或 none", name)
+	}
+	value := uint(id)
+	return &idFilter{ID: &value}, nil
+}
+
+// String 筛选条件的规范化表示
+func (f idFilter) String() string {
+	if f.ID == nil {
+		return "none"
+	}
+	return strconv.FormatUint(uint64(*f.ID), 10)
+}
+
+// apply 将筛选条件应用到 column 列上
+func (f idFilter) apply(db *gorm.DB, column string) *gorm.DB {
+	if f.ID == nil {
+		return db.Where(column + " IS NULL")
+	}
+	return db.Where(column+" = ?", *f.ID)
+}
+
+// todoListQuery 解析并校验后的列表查询参数
+type todoListQuery struct {
+	Completed     *bool
+	Actionable    *bool // true 只返回可以开始的 (未完成且没有未完成的前置任务)，false 返回其余的
+	CreatedAfter  *time.Time
+	CreatedBefore *time.Time
+	UpdatedSince  *time.Time
+	DueAfter      *time.Time
+	DueBefore     *time.Time
+	Priorities    []models.Priority
+	Project       *idFilter
+	Parent        *idFilter // 按父任务筛选，ID 为 nil 表示只看顶层任务
+	Tags          []string  // 标签名
+	TagMatchAll   bool      // true 表示必须包含所有标签 (AND)，否则包含任一标签即可 (OR)
+	TitleContains string
+	CustomFilters []customFilter
+	View          *dueView // 截止时间视图 (逾期/今天/即将到期)，仅视图接口设置
+	Archived      bool     // true 时只返回已归档的待办事项，否则只返回未归档的，仅归档接口设置
+	Sort          []sortKey
+	Page          pageParams
+}
+
+// parseTodoListQuery 从请求中解析筛选、排序和分页参数
+// defaultSort 为未传 sort 参数时使用的排序
+func parseTodoListQuery(c *gin.Context, defaultSort string) (todoListQuery, error) {
+	var q todoListQuery
+	var err error
+
+	if v := c.Query("completed"); v != "" {
+		completed, err := strconv.ParseBool(v)
+		if err != nil {
+			return q, errors.New("completed 必须为 true 或 false")
+		}
+		q.Completed = &completed
+	}
+	if v := c.Query("actionable"); v != "" {
+		actionable, err := strconv.ParseBool(v)
+		if err != nil {
+			return q, errors.New("actionable 必须为 true 或 false")
+		}
+		q.Actionable = &actionable
+	}
+	if q.CreatedAfter, err = parseTimeParam(c, "created_after"); err != nil {
+		return q, err
+	}
+	if q.CreatedBefore, err = parseTimeParam(c, "created_before"); err != nil {
+		return q, err
+	}
+	if q.UpdatedSince, err = parseTimeParam(c, "updated_since"); err != nil {
+		return q, err
+	}
+	if q.DueAfter, err = parseTimeParam(c, "due_after"); err != nil {
+		return q, err
+	}
+	if q.DueBefore, err = parseTimeParam(c, "due_before"); err != nil {
+		return q, err
+	}
+	if v := c.Query("priority"); v != "" {
+		for _, name := range strings.Split(v, ",") {
+			p, err := models.ParsePriority(strings.TrimSpace(name))
+			if err != nil {
+				return q, err
+			}
+			q.Priorities = append(q.Priorities, p)
+		}
+		sort.Slice(q.Priorities, func(i, j int) bool { return q.Priorities[i] < q.Priorities[j] })
+	}
+	if q.Project, err = parseIDFilter(c, "project_id"); err != nil {
+		return q, err
+	}
+	if q.Parent, err = parseIDFilter(c, "parent_id"); err != nil {
+		return q, err
+	}
+	for _, name := range c.QueryArray("tag") {
+		if name = strings.TrimSpace(name); name != "" {
+			q.Tags = append(q.Tags, name)
+		}
+	}
+	sort.Strings(q.Tags)
+	q.Tags = dedupeSorted(q.Tags)
+	switch c.DefaultQuery("tag_mode", "or") {
+	case "and":
+		q.TagMatchAll = true
+	case "or":
+	default:
+		return q, errors.New("tag_mode 必须为 and 或 or")
+	}
+	q.TitleContains = strings.TrimSpace(c.Query("title"))
+
+	sortRaw := c.DefaultQuery("sort", defaultSort)
+	var customSorts map[string]sortField
+	if q.CustomFilters, customSorts, err = parseCustomFieldQuery(c, sortRaw); err != nil {
+		return q, err
+	}
+	if q.Sort, err = parseSort(sortRaw, customSorts); err != nil {
+		return q, err
+	}
+
+	if q.Page, err = parsePageParams(c); err != nil {
+		return q, err
+	}
+	if q.Page.Cursor != nil {
+		if q.Page.Cursor.Sort != q.sortSignature() || len(q.Page.Cursor.Values) != len(q.Sort) {
+			return q, errors.New("分页游标与排序参数不匹配")
+		}
+	}
+
+	return q, nil
+}
+
+// parseTimeParam 解析时间类型的查询参数，支持 RFC3339 和 YYYY-MM-DD
+func parseTimeParam(c *gin.Context, name string) (*time.Time, error) {
+	v := c.Query(name)
+	if v == "" {
+		return nil, nil
+	}
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return &t, nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", v, time.Local); err == nil {
+		return &t, nil
+	}
+	return nil, fmt.Errorf("%s 必须为 RFC3339 时间或 YYYY-MM-DD 日期", name)
+}
+
+// parseSort 解析 sort 参数，如 "-updated_at,title"，前缀 "-" 表示降序
+// custom 为可以使用的自定义字段排序，如 "cf.3"
+func parseSort(raw string, custom map[string]sortField) ([]sortKey, error) {
+	if raw == "" {
+		return nil, nil
+	}
+	var keys []sortKey
+	seen := map[string]bool{}
+	for _, part := range strings.Split(raw, ",") {
+		part = strings.TrimSpace(part)
+		desc := strings.HasPrefix(part, "-")
+		name := strings.TrimPrefix(part, "-")
+		field, ok := todoSortFields[name]
+		if !ok {
+			field, ok = custom[name]
+		}
+		if !ok {
+			return nil, fmt.Errorf("不支持的排序字段: %s", name)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("重复的排序字段: %s", name)
+		}
+		seen[name] = true
+		keys = append(keys, sortKey{name: name, field: field, desc: desc})
+	}
+	if len(keys) > maxSortKeys {
+		return nil, fmt.Errorf("最多支持 %d 个排序字段", maxSortKeys)
+	}
+	return keys, nil
+}
+
+// sortSignature 排序条件的规范化表示，写入游标防止换了排序后继续使用旧游标
+func (q todoListQuery) sortSignature() string {
+	parts := make([]string, len(q.Sort))
+	for i, k := range q.Sort {
+		if k.desc {
+			parts[i] = "-" + k.name
+		} else {
+			parts[i] = k.name
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+// normalized 规范化后的查询条件 (不含分页)，同样的筛选无论参数顺序如何都得到同一个值
+func (q todoListQuery) normalized() string {
+	v := url.Values{}
+	if q.Completed != nil {
+		v.Set("completed", strconv.FormatBool(*q.Completed))
+	}
+	if q.Actionable != nil {
+		v.Set("actionable", strconv.FormatBool(*q.Actionable))
+	}
+	if q.CreatedAfter != nil {
+		v.Set("created_after", q.CreatedAfter.UTC().Format(time.RFC3339))
+	}
+	if q.CreatedBefore != nil {
+		v.Set("created_before", q.CreatedBefore.UTC().Format(time.RFC3339))
+	}
+	if q.UpdatedSince != nil {
+		v.Set("updated_since", q.UpdatedSince.UTC().Format(time.RFC3339))
+	}
+	if q.DueAfter != nil {
+		v.Set("due_after", q.DueAfter.UTC().Format(time.RFC3339))
+	}
+	if q.DueBefore != nil {
+		v.Set("due_before", q.DueBefore.UTC().Format(time.RFC3339))
+	}
+	for _, p := range q.Priorities {
+		v.Add("priority", p.String())
+	}
+	if q.Project != nil {
+		v.Set("project_id", q.Project.String())
+	}
+	if q.Parent != nil {
+		v.Set("parent_id", q.Parent.String())
+	}
+	for _, name := range q.Tags {
+		v.Add("tag", name)
+	}
+	if len(q.Tags) > 1 && q.TagMatchAll {
+		v.Set("tag_mode", "and")
+	}
+	if q.TitleContains != "" {
+		v.Set("title", q.TitleContains)
+	}
+	for _, f := range q.CustomFilters {
+		v.Set(f.param(), f.value.String())
+	}
+	if q.View != nil {
+		v.Set("view", q.View.key)
+	}
+	if q.Archived {
+		v.Set("archived", "true")
+	}
+	if sig := q.sortSignature(); sig != "" {
+		v.Set("sort", sig)
+	}
+	return v.Encode() // Encode 会按 key 排序
+}
+
+// cacheToken 查询条件的短哈希，用于组成缓存Key
+func (q todoListQuery) cacheToken() string {
+	sum := sha1.Sum([]byte(q.normalized()))
+	return hex.EncodeToString(sum[:8])
+}
+
+// apply 将筛选、排序和游标条件应用到查询上
+func (q todoListQuery) apply(db *gorm.DB) *gorm.DB {
+	if q.Completed != nil {
+		db = db.Where("completed = ?", *q.Completed)
+	}
+	if q.Actionable != nil {
+		db = models.ActionableScope(db, *q.Actionable)
+	}
+	if q.CreatedAfter != nil {
+		db = db.Where("created_at >= ?", *q.CreatedAfter)
+	}
+	if q.CreatedBefore != nil {
+		db = db.Where("created_at < ?", *q.CreatedBefore)
+	}
+	if q.UpdatedSince != nil {
+		db = db.Where("updated_at >= ?", *q.UpdatedSince)
+	}
+	if q.DueAfter != nil {
+		db = db.Where("due_at >= ?", *q.DueAfter)
+	}
+	if q.DueBefore != nil {
+		db = db.Where("due_at < ?", *q.DueBefore)
+	}
+	if len(q.Priorities) > 0 {
+		db = db.Where("priority IN ?", q.Priorities)
+	}
+	if q.Project != nil {
+		db = q.Project.apply(db, "project_id")
+	}
+	if q.Parent != nil {
+		db = q.Parent.apply(db, "parent_id")
+	}
+	if len(q.Tags) > 0 {
+		// 有意按标签名跨所有者匹配：外层查询限定为当前用户在工作区中能看到的待办事项，
+		// 其中共享项目的待办事项带的是项目创建者的标签，成员按同名标签即可筛选它们。
+		// 一个待办事项上的标签都属于它的所有者，且同一工作区中标签名唯一，按 tags.id 计数即可判断是否全部匹配
+		sub := models.DB.Table("todo_tags").
+			Select("todo_tags.todo_id").
+			Joins("JOIN tags ON tags.id = todo_tags.tag_id").
+			Where("tags.name IN ?", q.Tags)
+		if q.TagMatchAll {
+			sub = sub.Group("todo_tags.todo_id").Having("COUNT(DISTINCT tags.id) = ?", len(q.Tags))
+		}
+		db = db.Where("id IN (?)", sub)
+	}
+	for _, f := range q.CustomFilters {
+		db = f.apply(db)
+	}
+	if q.View != nil {
+		db = q.View.scope(db)
+	}
+	if q.Archived {
+		db = db.Where("archived_at IS NOT NULL")
+	} else {
+		db = db.Where("archived_at IS NULL")
+	}
+	if q.TitleContains != "" {
+		pattern := "%" + escapeLike(q.TitleContains) + "%"
+		if py := search.NormalizePinyinQuery(q.TitleContains); py != "" {
+			// 纯字母数字的输入同时按全拼和首字母匹配，如 "mai niunai" 或 "mnn"
+			pyPattern := "%" + escapeLike(py) + "%"
+			db = db.Where("(title LIKE ? OR title_pinyin LIKE ? OR title_initials LIKE ?)", pattern, pyPattern, pyPattern)
+		} else {
+			db = db.Where("title LIKE ?", pattern)
+		}
+	}
+
+	if q.Page.Cursor != nil {
+		if cond, args, err := q.keysetCondition(*q.Page.Cursor); err == nil {
+			db = db.Where(cond, args...)
+		} else {
+			db.AddError(err)
+		}
+	}
+
+	for _, k := range q.Sort {
+		if k.desc {
+			db = db.Order(k.field.column + " DESC")
+		} else {
+			db = db.Order(k.field.column + " ASC")
+		}
+	}
+	// id 作为最后的排序键，保证顺序稳定
+	return db.Order("id ASC")
+}
+
+// keysetCondition 根据游标生成 "位于游标之后" 的条件
+// 对排序键 (k1, k2, ..., id) 展开为:
+// k1 > v1 OR (k1 = v1 AND k2 > v2) OR ... OR (k1 = v1 AND ... AND id > lastID)
+func (q todoListQuery) keysetCondition(cur pageCursor) (string, []interface{}, error) {
+	values := make([]interface{}, len(q.Sort))
+	for i, k := range q.Sort {
+		v, err := cursorValue(k.field.kind, cur.Values[i])
+		if err != nil {
+			return "", nil, err
+		}
+		values[i] = v
+	}
+
+	var ors []string
+	var args []interface{}
+	for i := 0; i <= len(q.Sort); i++ {
+		var ands []string
+		for j := 0; j < i; j++ {
+			ands = append(ands, q.Sort[j].field.column+" = ?")
+			args = append(args, values[j])
+		}
+		if i < len(q.Sort) {
+			op := " > ?"
+			if q.Sort[i].desc {
+				op = " < ?"
+			}
+			ands = append(ands, q.Sort[i].field.column+op)
+			args = append(args, values[i])
+		} else {
+			ands = append(ands, "id > ?")
+			args = append(args, cur.ID)
+		}
+		ors = append(ors, "("+strings.Join(ands, " AND ")+")")
+	}
+	return "(" + strings.Join(ors, " OR ") + ")", args, nil
+}
+
+// cursorValue 将游标中反序列化出的值转换回对应的Go类型
+func cursorValue(kind sortKind, raw interface{}) (interface{}, error) {
+	invalid := errors.New("无效的分页游标")
+	switch kind {
+	case sortKindInt:
+		if f, ok := raw.(float64); ok {
+			return int64(f), nil
+		}
+	case sortKindFloat:
+		if f, ok := raw.(float64); ok {
+			return f, nil
+		}
+	case sortKindString:
+		if s, ok := raw.(string); ok {
+			return s, nil
+		}
+	case sortKindBool:
+		if b, ok := raw.(bool); ok {
+			return b, nil
+		}
+	case sortKindTime:
+		if s, ok := raw.(string); ok {
+			if t, err := time.Parse(time.RFC3339Nano, s); err == nil {
+				return t, nil
+			}
+		}
+	}
+	return nil, invalid
+}
+
+// nextCursor 根据本页最后一条记录生成下一页游标
+func (q todoListQuery) nextCursor(last *models.Todo) string {
+	cur := pageCursor{ID: last.ID, Sort: q.sortSignature()}
+	for _, k := range q.Sort {
+		cur.Values = append(cur.Values, k.field.value(last))
+	}
+	return encodeCursor(cur)
+}
+
+// escapeLike 转义 LIKE 模式中的通配符
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+// dedupeSorted 去除已排序切片中的重复元素
+func dedupeSorted(values []string) []string {
+	var out []string
+	for i, v := range values {
+		if i == 0 || v != values[i-1] {
+			out = append(out, v)
+		}
+	}
+	return out
+}
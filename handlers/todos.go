@@ -2,13 +2,17 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 	"todolist/models"
 
 	"github.com/gin-gonic/gin"
 	"github.com/go-redis/redis/v8"
+	"gorm.io/gorm"
 )
 
 const (
@@ -18,22 +22,43 @@ const (
 
 // ---- 缓存 Key 生成函数 ----
 
-// getUserTodosKey 生成用户待办事项列表的缓存Key
+// getUserTodosKey 生成用户待办事项列表的缓存Key前缀
 func getUserTodosKey(userID uint) string {
 	return fmt.Sprintf("user:%d:todos", userID)
 }
 
+// getUserTodosVersionKey 生成用户列表缓存版本号的Key
+// 列表按页缓存，清除缓存时只需递增版本号，旧版本的分页缓存自然过期
+func getUserTodosVersionKey(userID uint) string {
+	return models.UserTodosVersionKey(userID)
+}
+
+// getUserTodosPageKey 生成用户待办事项列表某一页的缓存Key
+// 同一用户下不同工作区、不同的筛选/排序条件通过工作区ID和规范化查询的哈希区分
+func getUserTodosPageKey(userID, workspaceID uint, version int64, q todoListQuery) string {
+	return fmt.Sprintf("%s:w%d:v%d:q%s:l%d:c%s", getUserTodosKey(userID), workspaceID, version, q.cacheToken(), q.Page.Limit, q.Page.Raw)
+}
+
 // getTodoKey 生成单个待办事项的缓存Key
 func getTodoKey(todoID uint) string {
-	return fmt.Sprintf("todo:%d", todoID)
+	return models.TodoCacheKey(todoID)
+}
+
+// getUserTodosVersion 获取用户列表缓存的当前版本号
+func getUserTodosVersion(userID uint) int64 {
+	version, err := models.Rdb.Get(models.Ctx, getUserTodosVersionKey(userID)).Int64()
+	if err != nil && err != redis.Nil {
+		fmt.Printf("Redis Get error for version of user %d: %v\n", userID, err)
+	}
+	return version
 }
 
 // ---- 缓存清除函数 ----
 
 // clearUserCache 清除指定用户的所有相关缓存
 func clearUserCache(userID uint) {
-	userTodosKey := getUserTodosKey(userID)
-	models.Rdb.Del(models.Ctx, userTodosKey)
+	// 递增版本号，使该用户所有分页缓存失效
+	models.Rdb.Incr(models.Ctx, getUserTodosVersionKey(userID))
 	// 如果有其他与用户相关的缓存，也在此处清除
 }
 
@@ -42,7 +67,76 @@ func clearTodoCache(todoID uint) {
 	models.Rdb.Del(models.Ctx, getTodoKey(todoID))
 }
 
-// GetAllTodos 返回当前用户的待办事项 (带缓存)
+// prepareNewTodo 校验新建待办事项的项目、父任务、标签和重复规则
+// 指定了父任务时继承父任务所属的项目，同时指定的项目必须与之相同；
+// 在共享项目中新建的待办事项属于项目的创建者，标签和自定义字段也使用创建者的；
+// 项目和父任务必须属于待办事项所在的工作区
+func prepareNewTodo(userID uint, in *todoInput, todo *models.Todo) error {
+	if in.Recurrence != nil {
+		if _, err := in.Recurrence.parse(); err != nil {
+			return err
+		}
+		if todo.DueAt == nil {
+			return errors.New("重复待办必须设置截止时间")
+		}
+	}
+	parent, err := checkTargetParent(userID, todo.WorkspaceID, 0, todo.ParentID)
+	if err != nil {
+		return err
+	}
+	if parent != nil {
+		if in.ProjectID.Set && !sameID(todo.ProjectID, parent.ProjectID) {
+			return errParentProject
+		}
+		todo.ProjectID = parent.ProjectID
+	}
+	ownerID, err := checkTargetProject(userID, todo.WorkspaceID, todo.ProjectID)
+	if err != nil {
+		return err
+	}
+	if parent != nil && parent.UserID != ownerID {
+		return errors.New("父任务与项目不属于同一所有者")
+	}
+	todo.UserID = ownerID
+	tags, err := findUserTags(ownerID, todo.WorkspaceID, in.TagIDs)
+	if err != nil {
+		return err
+	}
+	todo.Tags = tags
+	return in.parseCustomFields(ownerID, todo.WorkspaceID)
+}
+
+// inputLocation 解析请求中依赖用户时区的字段：换算 due_in_workdays，并返回创建重复系列使用的时区
+// 没有待办事项用到时区时不查询用户
+func inputLocation(c *gin.Context, userID uint, inputs ...*todoInput) (*time.Location, error) {
+	var loc *time.Location
+	for _, in := range inputs {
+		if in.Recurrence == nil && in.DueInWorkdays == nil {
+			continue
+		}
+		if loc == nil {
+			var err error
+			if loc, err = resolveLocation(c, userID); err != nil {
+				return nil, err
+			}
+		}
+		if err := in.resolveWorkdayDue(time.Now(), loc); err != nil {
+			return nil, err
+		}
+	}
+	return loc, nil
+}
+
+// todoPage 待办事项列表的分页响应
+type todoPage struct {
+	Todos      []models.Todo `json:"todos"`
+	NextCursor string        `json:"next_cursor"`
+}
+
+// GetAllTodos 分页返回当前用户的待办事项 (带缓存)，包括共享给他的项目中的待办事项
+// 支持筛选 (completed, created_after, created_before, updated_since, due_after, due_before, priority, title)、
+// 多字段排序 (sort=-updated_at,title) 以及 ?limit=&cursor= 游标分页
+// 默认按手动排序键 (position) 排列，sort=completed,-score 为智能排序
 func GetAllTodos(c *gin.Context) {
 	// 从上下文中获取当前用户ID
 	userID, exists := c.Get("user_id")
@@ -52,14 +146,26 @@ func GetAllTodos(c *gin.Context) {
 	}
 	currentUserID := userID.(uint)
 
+	listQuery, err := parseTodoListQuery(c, defaultTodoSort)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	listTodos(c, currentUserID, listQuery)
+}
+
+// listTodos 按解析好的查询条件返回一页待办事项，列表和各视图接口共用 (带缓存)
+func listTodos(c *gin.Context, currentUserID uint, listQuery todoListQuery) {
 	// --- 缓存读取 ---
-	cacheKey := getUserTodosKey(currentUserID)
-	cachedTodos, err := models.Rdb.Get(models.Ctx, cacheKey).Result()
+	cacheKey := getUserTodosPageKey(currentUserID, c.GetUint("workspace_id"), getUserTodosVersion(currentUserID), listQuery)
+	cachedPage, err := models.Rdb.Get(models.Ctx, cacheKey).Result()
 	if err == nil {
 		// 缓存命中
-		var todos []models.Todo
-		if json.Unmarshal([]byte(cachedTodos), &todos) == nil {
-			c.JSON(http.StatusOK, todos)
+		var resp todoPage
+		if json.Unmarshal([]byte(cachedPage), &resp) == nil {
+			setNextLink(c, resp.NextCursor)
+			c.JSON(http.StatusOK, resp)
 			fmt.Println("Cache hit for key:", cacheKey) // 日志
 			return
 		} else {
@@ -74,17 +180,36 @@ func GetAllTodos(c *gin.Context) {
 	fmt.Println("Cache miss for key:", cacheKey) // 日志
 
 	// --- 缓存未命中或出错，查询数据库 ---
+	// 多取一条用于判断是否还有下一页
+	limit := listQuery.Page.Limit
 	var todos []models.Todo
-	result := models.DB.Where("user_id = ?", currentUserID).Find(&todos)
+	query := listQuery.apply(models.VisibleTodos(models.DB.Preload("Tags"), currentUserID, c.GetUint("workspace_id")))
+	result := query.Limit(limit + 1).Find(&todos)
 	if result.Error != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取待办事项失败"})
 		return
 	}
 
+	// 自定义字段随页面一起缓存，按自定义字段排序时生成游标也需要它
+	if err := models.FillCustomFields(models.DB, todos); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取待办事项失败"})
+		return
+	}
+	resp := todoPage{Todos: todos}
+	if len(todos) > limit {
+		resp.Todos = todos[:limit]
+		resp.NextCursor = listQuery.nextCursor(&resp.Todos[limit-1])
+	}
+	// 依赖状态随页面一起缓存，前置任务的变化同样会递增版本号
+	if err := models.FillDependencyFlags(models.DB, resp.Todos); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取待办事项失败"})
+		return
+	}
+
 	// --- 结果存入缓存 ---
-	todosJSON, err := json.Marshal(todos)
+	pageJSON, err := json.Marshal(resp)
 	if err == nil {
-		err = models.Rdb.Set(models.Ctx, cacheKey, todosJSON, cacheDuration).Err()
+		err = models.Rdb.Set(models.Ctx, cacheKey, pageJSON, cacheDuration).Err()
 		if err != nil {
 			fmt.Printf("Redis Set error for key %s: %v\n", cacheKey, err)
 			// 缓存写入失败不应阻塞主流程，记录日志即可
@@ -95,10 +220,11 @@ func GetAllTodos(c *gin.Context) {
 		fmt.Printf("JSON Marshal error when caching todos for user %d: %v\n", currentUserID, err)
 	}
 
-	c.JSON(http.StatusOK, todos)
+	setNextLink(c, resp.NextCursor)
+	c.JSON(http.StatusOK, resp)
 }
 
-// GetTodoByID 根据ID获取当前用户的待办事项 (带缓存)
+// GetTodoByID 根据ID获取当前用户能看到的待办事项 (带缓存)
 func GetTodoByID(c *gin.Context) {
 	// 从上下文中获取当前用户ID
 	userID, userExists := c.Get("user_id")
@@ -116,22 +242,47 @@ func GetTodoByID(c *gin.Context) {
 		return
 	}
 
+	// ?include=children 时返回整棵子任务树，子任务随时变化，不走缓存
+	switch c.Query("include") {
+	case "":
+	case "children":
+		todo, descendants, err := loadUserTodoWithDescendants(currentUserID, c.GetUint("workspace_id"), todoIDStr)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "待办事项未找到或无权访问"})
+			return
+		}
+		c.JSON(http.StatusOK, models.BuildSubtree(todo, descendants))
+		return
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "include 仅支持 children"})
+		return
+	}
+
 	// --- 缓存读取 ---
 	cacheKey := getTodoKey(todoID)
 	cachedTodo, err := models.Rdb.Get(models.Ctx, cacheKey).Result()
 	if err == nil {
 		var todo models.Todo
 		if json.Unmarshal([]byte(cachedTodo), &todo) == nil {
-			// 检查缓存中的Todo是否属于当前用户
-			if todo.UserID == currentUserID {
-				c.JSON(http.StatusOK, todo)
-				fmt.Println("Cache hit for key:", cacheKey) // 日志
+			// 检查当前用户是否能访问缓存中的Todo (当前工作区中自己的或共享项目中的)
+			if todo.WorkspaceID != c.GetUint("workspace_id") {
+				c.JSON(http.StatusNotFound, gin.H{"error": "待办事项未找到或无权访问"})
+				return
+			}
+			// 看不到时与查询数据库一样返回 404，不暴露该待办事项是否存在
+			role, err := models.TodoRole(models.DB, currentUserID, &todo)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "检查权限失败"})
 				return
-			} else {
-				// 用户无权访问此缓存项
-				c.JSON(http.StatusForbidden, gin.H{"error": "无权访问该待办事项"})
+			}
+			if role == "" {
+				c.JSON(http.StatusNotFound, gin.H{"error": "待办事项未找到或无权访问"})
 				return
 			}
+			fillDependencyFlags(&todo)
+			c.JSON(http.StatusOK, todo)
+			fmt.Println("Cache hit for key:", cacheKey) // 日志
+			return
 		} else {
 			fmt.Println("Cache data corrupted for key:", cacheKey)
 		}
@@ -142,10 +293,24 @@ func GetTodoByID(c *gin.Context) {
 
 	// --- 缓存未命中，查询数据库 ---
 	var todo models.Todo
-	if err := models.DB.Where("id = ? AND user_id = ?", todoID, currentUserID).First(&todo).Error; err != nil {
+	if err := models.VisibleTodos(models.DB.Preload("Tags"), currentUserID, c.GetUint("workspace_id")).Where("id = ?", todoID).First(&todo).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "待办事项未找到或无权访问"})
 		return
 	}
+	// 评论数随详情一起缓存，评论增删时清除该缓存
+	count, err := models.CountComments(todo.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取待办事项失败"})
+		return
+	}
+	todo.CommentCount = &count
+	// 自定义字段值只随待办事项的更新变化，同样清除该缓存
+	withFields := []models.Todo{todo}
+	if err := models.FillCustomFields(models.DB, withFields); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取待办事项失败"})
+		return
+	}
+	todo.CustomFields = withFields[0].CustomFields
 
 	// --- 结果存入缓存 ---
 	todoJSON, err := json.Marshal(todo)
@@ -160,9 +325,40 @@ func GetTodoByID(c *gin.Context) {
 		fmt.Printf("JSON Marshal error when caching todo %d: %v\n", todoID, err)
 	}
 
+	fillDependencyFlags(&todo)
 	c.JSON(http.StatusOK, todo)
 }
 
+// joinIDs 将ID列表拼接为逗号分隔的字符串，用于响应头
+func joinIDs(ids []uint) string {
+	parts := make([]string, len(ids))
+	for i, id := range ids {
+		parts[i] = strconv.FormatUint(uint64(id), 10)
+	}
+	return strings.Join(parts, ",")
+}
+
+// fillDependencyFlags 为单个待办事项填充依赖状态
+// 详情缓存不随前置任务的变化清除，因此每次返回前重新计算，出错时不返回这两个字段
+func fillDependencyFlags(todo *models.Todo) {
+	todos := []models.Todo{*todo}
+	if err := models.FillDependencyFlags(models.DB, todos); err != nil {
+		fmt.Printf("Fill dependency flags error for todo %d: %v\n", todo.ID, err)
+		return
+	}
+	todo.Blocked, todo.Actionable = todos[0].Blocked, todos[0].Actionable
+}
+
+// fillCustomFields 为单个待办事项填充自定义字段值，出错时不返回该字段
+func fillCustomFields(todo *models.Todo) {
+	todos := []models.Todo{*todo}
+	if err := models.FillCustomFields(models.DB, todos); err != nil {
+		fmt.Printf("Fill custom fields error for todo %d: %v\n", todo.ID, err)
+		return
+	}
+	todo.CustomFields = todos[0].CustomFields
+}
+
 // CreateTodo 创建待办事项（支持单个和批量创建）(带缓存清除)
 func CreateTodo(c *gin.Context) {
 	// 从上下文中获取当前用户ID
@@ -176,8 +372,8 @@ func CreateTodo(c *gin.Context) {
 	contentType := c.GetHeader("Content-Type")
 	if contentType == "application/json" {
 		var payload struct {
-			Single *models.Todo  `json:"todo"`
-			Batch  []models.Todo `json:"todos"`
+			Single *todoInput  `json:"todo"`
+			Batch  []todoInput `json:"todos"`
 		}
 
 		if err := c.ShouldBindJSON(&payload); err != nil {
@@ -187,33 +383,107 @@ func CreateTodo(c *gin.Context) {
 
 		// 单个创建
 		if payload.Single != nil {
-			payload.Single.UserID = currentUserID
-			if err := models.DB.Create(payload.Single).Error; err != nil {
+			loc, err := inputLocation(c, currentUserID, payload.Single)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			todo := payload.Single.toTodo(currentUserID, c.GetUint("workspace_id"))
+			if err := prepareNewTodo(currentUserID, payload.Single, &todo); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			err = models.DB.Transaction(func(tx *gorm.DB) error {
+				// 新建的待办事项排在所在清单的末尾
+				var err error
+				if todo.Position, err = models.NextPosition(tx, models.ListOf(&todo)); err != nil {
+					return err
+				}
+				if err := tx.Create(&todo).Error; err != nil {
+					return err
+				}
+				if err := models.SetCustomValues(tx, todo.ID, payload.Single.customValues); err != nil {
+					return err
+				}
+				if payload.Single.Recurrence != nil {
+					if err := attachSeries(tx, &todo, payload.Single.Recurrence, loc); err != nil {
+						return err
+					}
+				}
+				if err := models.IndexTodo(tx, &todo); err != nil {
+					return err
+				}
+				return models.RecordCreated(tx, currentUserID, todo.ID)
+			})
+			if err != nil {
 				c.JSON(http.StatusInternalServerError, gin.H{"error": "创建待办事项失败"})
 				return
 			}
-			// --- 清除用户列表缓存 ---
-			clearUserCache(currentUserID)
+			// --- 清除用户列表缓存 (共享项目扩散到每个成员) ---
+			clearSharedCache(todo.UserID, todo.ProjectID)
 			fmt.Println("Cache cleared for user:", currentUserID) // 日志
-			c.JSON(http.StatusCreated, payload.Single)
+			fillCustomFields(&todo)
+			c.JSON(http.StatusCreated, todo)
 			return
 		}
 
 		// 批量创建
 		if len(payload.Batch) > 0 {
+			inputs := make([]*todoInput, len(payload.Batch))
+			for i := range payload.Batch {
+				inputs[i] = &payload.Batch[i]
+			}
+			loc, err := inputLocation(c, currentUserID, inputs...)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			todos := make([]models.Todo, len(payload.Batch))
 			for i := range payload.Batch {
-				payload.Batch[i].UserID = currentUserID
+				todos[i] = payload.Batch[i].toTodo(currentUserID, c.GetUint("workspace_id"))
+				if err := prepareNewTodo(currentUserID, &payload.Batch[i], &todos[i]); err != nil {
+					c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+					return
+				}
 			}
-			if err := models.DB.Create(&payload.Batch).Error; err != nil {
+			err = models.DB.Transaction(func(tx *gorm.DB) error {
+				// 按请求中的顺序依次追加到各自清单的末尾
+				if err := models.AssignPositions(tx, todos); err != nil {
+					return err
+				}
+				if err := tx.Create(&todos).Error; err != nil {
+					return err
+				}
+				for i := range todos {
+					if err := models.SetCustomValues(tx, todos[i].ID, payload.Batch[i].customValues); err != nil {
+						return err
+					}
+					if rec := payload.Batch[i].Recurrence; rec != nil {
+						if err := attachSeries(tx, &todos[i], rec, loc); err != nil {
+							return err
+						}
+					}
+					if err := models.IndexTodo(tx, &todos[i]); err != nil {
+						return err
+					}
+				}
+				return models.RecordCreated(tx, currentUserID, models.TodoIDs(todos)...)
+			})
+			if err != nil {
 				c.JSON(http.StatusInternalServerError, gin.H{"error": "批量创建待办事项失败"})
 				return
 			}
-			// --- 清除用户列表缓存 ---
-			clearUserCache(currentUserID)
+			// --- 清除用户列表缓存 (共享项目扩散到每个成员) ---
+			for i := range todos {
+				clearSharedCache(todos[i].UserID, todos[i].ProjectID)
+			}
 			fmt.Println("Cache cleared for user:", currentUserID) // 日志
+			if err := models.FillCustomFields(models.DB, todos); err != nil {
+				fmt.Printf("Fill custom fields error for user %d: %v\n", currentUserID, err)
+			}
 			c.JSON(http.StatusCreated, gin.H{
 				"message": "批量创建成功",
-				"todos":   payload.Batch,
+				"todos":   todos,
 			})
 			return
 		}
@@ -225,7 +495,7 @@ func CreateTodo(c *gin.Context) {
 	c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "仅支持 application/json"})
 }
 
-// UpdateTodo 更新当前用户的待办事项 (带缓存清除)
+// UpdateTodo 更新待办事项 (带缓存清除)，共享项目中的待办事项需要 editor 角色
 func UpdateTodo(c *gin.Context) {
 	// 从上下文中获取当前用户ID
 	userID, exists := c.Get("user_id")
@@ -235,45 +505,281 @@ func UpdateTodo(c *gin.Context) {
 	}
 	currentUserID := userID.(uint)
 
-	var todo models.Todo
-	// 查找属于当前用户的待办事项
-	if err := models.DB.Where("id = ? AND user_id = ?", c.Param("id"), currentUserID).First(&todo).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "待办事项未找到或无权更新"})
+	// 查找当前用户可以修改的待办事项
+	found, ok := loadAccessibleTodo(c, currentUserID, models.RoleEditor)
+	if !ok {
 		return
 	}
-	originalTodoID := todo.ID // 保存原始ID用于缓存清除
+	todo := *found
+	originalTodoID := todo.ID           // 保存原始ID用于缓存清除
+	originalProjectID := todo.ProjectID // 移出共享项目时原项目的成员同样需要清除缓存
 
-	var updatedTodo models.Todo
+	var updatedTodo todoInput
 	if err := c.ShouldBindJSON(&updatedTodo); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求数据"})
 		return
 	}
 
+	// 重复待办默认只修改本次实例，scope=series 时同时修改系列模板，影响之后生成的实例
+	scope := c.DefaultQuery("scope", "this")
+	if scope != "this" && scope != "series" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "scope 必须为 this 或 series"})
+		return
+	}
+	if scope == "series" && todo.SeriesID == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "只有重复待办可以按系列修改"})
+		return
+	}
+
 	// 更新具体字段，包括零值字段
 	updates := map[string]interface{}{}
 	if updatedTodo.Title != "" {
 		updates["title"] = updatedTodo.Title
+		for k, v := range models.TitlePinyinColumns(updatedTodo.Title) {
+			updates[k] = v
+		}
 	}
 	if updatedTodo.Description != "" {
 		updates["description"] = updatedTodo.Description
 	}
-	updates["completed"] = updatedTodo.Completed
-	if err := models.DB.Model(&todo).Updates(updates).Error; err != nil {
+	// 未传 completed 时不修改完成状态 (以及完成时间和归档)
+	if updatedTodo.Completed != nil {
+		updates["completed"] = *updatedTodo.Completed
+	}
+	// 截止时间：未传则不修改，传 null 则清除；due_in_workdays 按工作日历换算
+	if _, err := inputLocation(c, currentUserID, &updatedTodo); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	for k, v := range updatedTodo.DueAt.columns() {
+		updates[k] = v
+	}
+	if updatedTodo.Priority != nil {
+		updates["priority"] = *updatedTodo.Priority
+	}
+	if updatedTodo.EstimateMinutes.Set {
+		updates["estimate_minutes"] = updatedTodo.EstimateMinutes.Value
+	}
+	if err := updatedTodo.parseCustomFields(todo.UserID, todo.WorkspaceID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	// 移动到其他项目 (传 null 则移出项目) 或其他父任务下 (传 null 则变为顶层任务)
+	// 与 /move 的规则相同：整棵子树跟随父任务所在的项目，子任务不能单独移到其他项目
+	newList := models.ListOf(&todo)
+	if updatedTodo.ProjectID.Set {
+		newList.ProjectID = updatedTodo.ProjectID.Value
+	}
+	if updatedTodo.ParentID.Set {
+		newList.ParentID = updatedTodo.ParentID.Value
+	}
+	parent, err := resolveTargetList(currentUserID, &todo, &newList)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if parent != nil && updatedTodo.ProjectID.Set && !sameID(updatedTodo.ProjectID.Value, parent.ProjectID) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": errParentProject.Error()})
+		return
+	}
+	if !sameID(newList.ParentID, todo.ParentID) {
+		updates["parent_id"] = newList.ParentID
+	}
+	projectChanged := !sameID(newList.ProjectID, todo.ProjectID)
+	// 项目变化时一起移动的子孙节点
+	var subtreeIDs []uint
+	if projectChanged {
+		descendants, err := models.LoadDescendants(models.DB, todo.UserID, todo.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "更新待办事项失败"})
+			return
+		}
+		subtreeIDs = append([]uint{todo.ID}, models.TodoIDs(descendants)...)
+	}
+
+	// 系列模板只包含标题、描述、优先级和项目，项目只在请求中指定时修改
+	seriesUpdates := map[string]interface{}{}
+	if scope == "series" {
+		for _, k := range []string{"title", "description", "priority"} {
+			if v, ok := updates[k]; ok {
+				seriesUpdates[k] = v
+			}
+		}
+		if updatedTodo.ProjectID.Set {
+			seriesUpdates["project_id"] = newList.ProjectID
+		}
+	}
+
+	// 完成父任务时按策略处理未完成的子任务
+	completing := updatedTodo.Completed != nil && *updatedTodo.Completed && !todo.Completed
+	reopening := updatedTodo.Completed != nil && !*updatedTodo.Completed && todo.Completed
+	var cascadeIDs []uint
+	if completing {
+		policy, err := subtaskPolicy(c, "SUBTASK_COMPLETE_POLICY")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		descendants, err := models.LoadDescendants(models.DB, todo.UserID, todo.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "更新待办事项失败"})
+			return
+		}
+		for _, d := range descendants {
+			if !d.Completed {
+				cascadeIDs = append(cascadeIDs, d.ID)
+			}
+		}
+		if policy == models.SubtaskPolicyBlock && len(cascadeIDs) > 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": "存在未完成的子任务", "open_subtasks": len(cascadeIDs)})
+			return
+		}
+		if policy != models.SubtaskPolicyCascade {
+			cascadeIDs = nil
+		}
+
+		// 还有未完成的前置任务时拒绝，?force=true 时仍然完成，并在响应头中列出这些前置任务
+		// 当前用户看不到的前置任务同样阻塞，但只计入数量，不列出ID
+		blockers, err := models.OpenBlockers(models.DB, append([]uint{todo.ID}, cascadeIDs...))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "更新待办事项失败"})
+			return
+		}
+		if len(blockers) > 0 {
+			visibleBlockers, err := visibleTodoIDs(currentUserID, todo.WorkspaceID, blockers)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "更新待办事项失败"})
+				return
+			}
+			force, _ := strconv.ParseBool(c.Query("force"))
+			if !force {
+				c.JSON(http.StatusConflict, gin.H{"error": "存在未完成的前置任务", "blocked_by": visibleBlockers, "open_blockers": len(blockers)})
+				return
+			}
+			c.Header("X-Open-Blockers", joinIDs(visibleBlockers))
+		}
+	}
+	// 记录完成时间；重新打开已归档的待办事项时取消归档，回到默认列表
+	now := time.Now()
+	if completing {
+		updates["completed_at"] = now
+	} else if reopening {
+		updates["completed_at"] = nil
+		updates["archived_at"] = nil
+	}
+
+	// 更新记录并同步检索索引
+	var nextTodo *models.Todo
+	var rebalanced []uint
+	// 换到其他清单 (项目或父任务变化) 时排到新清单的末尾
+	listChanged := projectChanged || !sameID(newList.ParentID, todo.ParentID)
+	// 修改前需要记录状态的待办事项，移动项目时包括整棵子树 (一并完成的子任务也在其中)
+	snapshotIDs := append([]uint{originalTodoID}, cascadeIDs...)
+	if projectChanged {
+		snapshotIDs = subtreeIDs
+	}
+
+	err = models.DB.Transaction(func(tx *gorm.DB) error {
+		// 修改前的状态，用于写入修订记录
+		before, err := models.SnapshotTodos(tx, snapshotIDs)
+		if err != nil {
+			return err
+		}
+		if listChanged {
+			key, err := models.NextPosition(tx, newList)
+			if err != nil {
+				return err
+			}
+			updates["position"] = key
+		}
+		if err := tx.Model(&todo).Updates(updates).Error; err != nil {
+			return err
+		}
+		if projectChanged {
+			if err := moveSubtreeToProject(tx, subtreeIDs, newList.ProjectID); err != nil {
+				return err
+			}
+		}
+		if err := models.SetCustomValues(tx, originalTodoID, updatedTodo.customValues); err != nil {
+			return err
+		}
+		if len(cascadeIDs) > 0 {
+			if err := tx.Model(&models.Todo{}).Where("id IN ?", cascadeIDs).
+				Updates(map[string]interface{}{"completed": true, "completed_at": now}).Error; err != nil {
+				return err
+			}
+		}
+		if err := tx.Preload("Tags").First(&todo, originalTodoID).Error; err != nil {
+			return err
+		}
+		// 优先级或截止时间可能变化，重新计算智能排序分数
+		if err := models.RefreshSmartScore(tx, &todo); err != nil {
+			return err
+		}
+		if err := models.IndexTodo(tx, &todo); err != nil {
+			return err
+		}
+		if err := models.RecordRevisions(tx, currentUserID, models.RevisionUpdate, before); err != nil {
+			return err
+		}
+		if todo.SeriesID == nil || (len(seriesUpdates) == 0 && !completing) {
+			return nil
+		}
+		series, err := loadTodoSeries(tx, &todo)
+		if err != nil {
+			return err
+		}
+		if len(seriesUpdates) > 0 {
+			if err := tx.Model(series).Updates(seriesUpdates).Error; err != nil {
+				return err
+			}
+		}
+		// 完成重复待办的一个实例时生成下一个实例
+		if completing {
+			nextTodo, rebalanced, err = models.CreateNextInstance(tx, series, &todo)
+		}
+		return err
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "更新待办事项失败"})
 		return
 	}
 
 	// --- 清除相关缓存 ---
-	clearUserCache(currentUserID)                                                        // 清除用户列表缓存
-	clearTodoCache(originalTodoID)                                                       // 清除单个待办事项缓存
+	clearSharedCache(todo.UserID, originalProjectID, todo.ProjectID) // 清除所有者和共享成员的列表缓存
+	clearTodoCache(originalTodoID)                                   // 清除单个待办事项缓存
+	for _, id := range cascadeIDs {
+		clearTodoCache(id) // 清除被一并完成的子任务缓存
+	}
+	for _, id := range subtreeIDs {
+		clearTodoCache(id) // 清除随之移到其他项目的子任务缓存
+	}
+	for _, id := range rebalanced {
+		clearTodoCache(id) // 清除为下一个实例腾出位置时被重新分配位置的待办事项缓存
+	}
+	if completing || reopening {
+		clearDependentsCache(append([]uint{originalTodoID}, cascadeIDs...)) // 被它阻塞的待办事项的依赖状态变化
+	}
 	fmt.Printf("Cache cleared for user %d and todo %d\n", currentUserID, originalTodoID) // 日志
 
-	// 重新获取更新后的待办事项 (这一步会触发缓存写入)
-	models.DB.First(&todo, originalTodoID)
+	// 截止时间变化后重新计算相对提醒，新实例的提醒入队
+	if updatedTodo.DueAt.Set {
+		if err := models.RescheduleTodoReminders(&todo); err != nil {
+			fmt.Printf("Reschedule reminders error for todo %d: %v\n", todo.ID, err)
+		}
+	}
+	if nextTodo != nil {
+		if err := models.RescheduleTodoReminders(nextTodo); err != nil {
+			fmt.Printf("Reschedule reminders error for todo %d: %v\n", nextTodo.ID, err)
+		}
+		c.Header("X-Next-Todo-ID", fmt.Sprint(nextTodo.ID)) // 新生成的下一个重复实例
+	}
+	fillCustomFields(&todo)
 	c.JSON(http.StatusOK, todo)
 }
 
-// DeleteTodo 删除当前用户的待办事项 (带缓存清除)
+// DeleteTodo 将待办事项移入所有者的回收站 (带缓存清除)，子任务按策略一并移入或上移一级
+// 共享项目中的待办事项需要 editor 角色
 func DeleteTodo(c *gin.Context) {
 	// 从上下文中获取当前用户ID
 	userID, exists := c.Get("user_id")
@@ -283,22 +789,66 @@ func DeleteTodo(c *gin.Context) {
 	}
 	currentUserID := userID.(uint)
 
-	var todo models.Todo
-	// 查找属于当前用户的待办事项
-	if err := models.DB.Where("id = ? AND user_id = ?", c.Param("id"), currentUserID).First(&todo).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "待办事项未找到或无权删除"})
+	// 查找当前用户可以删除的待办事项
+	todo, ok := loadAccessibleTodo(c, currentUserID, models.RoleEditor)
+	if !ok {
 		return
 	}
 	deletedTodoID := todo.ID // 保存ID用于缓存清除
 
-	if err := models.DB.Delete(&todo).Error; err != nil {
+	// 按策略处理子任务
+	policy, err := subtaskPolicy(c, "SUBTASK_DELETE_POLICY")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	descendants, err := models.LoadDescendants(models.DB, todo.UserID, todo.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "删除待办事项失败"})
+		return
+	}
+	if policy == models.SubtaskPolicyBlock && len(descendants) > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "请先删除或移走子任务", "subtasks": len(descendants)})
+		return
+	}
+	affectedIDs := models.TodoIDs(descendants)
+
+	err = models.DB.Transaction(func(tx *gorm.DB) error {
+		if policy == models.SubtaskPolicyCascade {
+			return models.DeleteTodos(tx, currentUserID, append([]uint{deletedTodoID}, affectedIDs...))
+		}
+		// keep：直接子任务上移一级，挂到被删除任务的父任务下
+		var childIDs []uint
+		for _, d := range descendants {
+			if d.ParentID != nil && *d.ParentID == deletedTodoID {
+				childIDs = append(childIDs, d.ID)
+			}
+		}
+		before, err := models.SnapshotTodos(tx, childIDs)
+		if err != nil {
+			return err
+		}
+		if err := tx.Model(&models.Todo{}).Where("parent_id = ?", deletedTodoID).Update("parent_id", todo.ParentID).Error; err != nil {
+			return err
+		}
+		if err := models.RecordRevisions(tx, currentUserID, models.RevisionUpdate, before); err != nil {
+			return err
+		}
+		return models.DeleteTodos(tx, currentUserID, []uint{deletedTodoID})
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "删除待办事项失败"})
 		return
 	}
 
 	// --- 清除相关缓存 ---
-	clearUserCache(currentUserID)                                                       // 清除用户列表缓存
-	clearTodoCache(deletedTodoID)                                                       // 清除单个待办事项缓存
+	clearSharedCache(todo.UserID, todo.ProjectID) // 清除所有者和共享成员的列表缓存
+	clearTodoCache(deletedTodoID)                 // 清除单个待办事项缓存
+	for _, id := range affectedIDs {
+		clearTodoCache(id) // 清除被删除或上移的子任务缓存
+	}
+	// 回收站中的前置任务不再阻塞，被它们阻塞的待办事项的依赖状态随之变化
+	clearDependentsCache(append([]uint{deletedTodoID}, affectedIDs...))
 	fmt.Printf("Cache cleared for user %d and todo %d\n", currentUserID, deletedTodoID) // 日志
 
 	c.Status(http.StatusNoContent)
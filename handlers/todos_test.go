@@ -0,0 +1,39 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"todolist/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TestUpdateWithoutCompletedKeepsState 更新时未传 completed 不改变完成和归档状态
+func TestUpdateWithoutCompletedKeepsState(t *testing.T) {
+	requireTestDB(t)
+	userID, _ := createTestUser(t, "archiver")
+	client := testClient{t: t, router: testRouter(), userID: userID}
+
+	var todo models.Todo
+	client.mustDo(http.StatusCreated, "POST", "/api/todos", gin.H{"todo": gin.H{"title": "写周报"}}, &todo)
+	path := fmt.Sprintf("/api/todos/%d", todo.ID)
+	client.mustDo(http.StatusOK, "PUT", path, gin.H{"completed": true}, nil)
+	client.mustDo(http.StatusOK, "POST", path+"/archive", nil, nil)
+
+	// 只修改标题
+	client.mustDo(http.StatusOK, "PUT", path, gin.H{"title": "写月报"}, nil)
+	var got models.Todo
+	models.DB.First(&got, todo.ID)
+	if got.Title != "写月报" || !got.Completed || got.CompletedAt == nil || got.ArchivedAt == nil {
+		t.Fatalf("只修改标题后: title=%q completed=%v completed_at=%v archived_at=%v", got.Title, got.Completed, got.CompletedAt, got.ArchivedAt)
+	}
+
+	// 明确重新打开时取消完成和归档
+	client.mustDo(http.StatusOK, "PUT", path, gin.H{"completed": false}, nil)
+	got = models.Todo{}
+	models.DB.First(&got, todo.ID)
+	if got.Completed || got.CompletedAt != nil || got.ArchivedAt != nil {
+		t.Errorf("重新打开后: completed=%v completed_at=%v archived_at=%v", got.Completed, got.CompletedAt, got.ArchivedAt)
+	}
+}
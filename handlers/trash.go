@@ -0,0 +1,193 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+	"todolist/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// 回收站列表游标的排序标识
+const trashCursorSort = "trash"
+
+// trashPage 回收站列表的分页响应
+type trashPage struct {
+	Todos         []models.Todo `json:"todos"`
+	NextCursor    string        `json:"next_cursor"`
+	RetentionDays int           `json:"retention_days"`
+}
+
+// GetTrash 分页返回当前工作区回收站中的待办事项，最近删除的在前
+// 回收站不走列表缓存，删除、恢复和清空都不需要额外失效
+func GetTrash(c *gin.Context) {
+	// 从上下文中获取当前用户ID
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "用户未认证"})
+		return
+	}
+	currentUserID := userID.(uint)
+
+	page, err := parsePageParams(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	query := models.DB.Unscoped().Preload("Tags").
+		Where("user_id = ? AND workspace_id = ? AND deleted_at IS NOT NULL", currentUserID, c.GetUint("workspace_id"))
+	if page.Cursor != nil {
+		deletedAt, err := trashCursorTime(page.Cursor)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		query = query.Where("(deleted_at < ? OR (deleted_at = ? AND id < ?))", deletedAt, deletedAt, page.Cursor.ID)
+	}
+
+	var todos []models.Todo
+	// 多取一条用于判断是否还有下一页
+	if err := query.Order("deleted_at DESC").Order("id DESC").Limit(page.Limit + 1).Find(&todos).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取回收站失败"})
+		return
+	}
+
+	resp := trashPage{Todos: todos, RetentionDays: models.TrashRetentionDays()}
+	if len(todos) > page.Limit {
+		resp.Todos = todos[:page.Limit]
+		last := resp.Todos[page.Limit-1]
+		resp.NextCursor = encodeCursor(pageCursor{
+			ID:     last.ID,
+			Sort:   trashCursorSort,
+			Values: []interface{}{last.DeletedAt.Time.Format(time.RFC3339Nano)},
+		})
+	}
+	setNextLink(c, resp.NextCursor)
+	c.JSON(http.StatusOK, resp)
+}
+
+// trashCursorTime 从回收站游标中取出上一页最后一条记录的删除时间
+func trashCursorTime(cur *pageCursor) (time.Time, error) {
+	invalid := errors.New("无效的分页游标")
+	if cur.Sort != trashCursorSort || len(cur.Values) != 1 {
+		return time.Time{}, invalid
+	}
+	s, ok := cur.Values[0].(string)
+	if !ok {
+		return time.Time{}, invalid
+	}
+	t, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		return time.Time{}, invalid
+	}
+	return t, nil
+}
+
+// trashTodoID 解析路径中的待办事项ID，无效时写入 400 响应
+func trashTodoID(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil || id == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的待办事项ID"})
+		return 0, false
+	}
+	return uint(id), true
+}
+
+// RestoreTodo 从回收站恢复待办事项，与它一并删除的子任务同时恢复 (带缓存清除)
+func RestoreTodo(c *gin.Context) {
+	// 从上下文中获取当前用户ID
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "用户未认证"})
+		return
+	}
+	currentUserID := userID.(uint)
+
+	id, ok := trashTodoID(c)
+	if !ok {
+		return
+	}
+
+	restored, err := models.RestoreTodo(currentUserID, c.GetUint("workspace_id"), id)
+	if errors.Is(err, models.ErrNotInTrash) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "回收站中未找到该待办事项"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "恢复待办事项失败"})
+		return
+	}
+
+	// --- 清除相关缓存 ---
+	clearUserCache(currentUserID)
+	clearTodoMembersCache(models.TodoIDs(restored)) // 恢复到共享项目中的待办事项
+	clearDependentsCache(models.TodoIDs(restored))  // 恢复的前置任务重新阻塞
+	for i := range restored {
+		clearTodoCache(restored[i].ID)
+		// 回收站期间暂停的提醒重新入队，已错过的会立即触发
+		if err := models.RescheduleTodoReminders(&restored[i]); err != nil {
+			fmt.Printf("Reschedule reminders error for todo %d: %v\n", restored[i].ID, err)
+		}
+	}
+
+	var todo models.Todo
+	if err := models.DB.Preload("Tags").First(&todo, id).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "恢复待办事项失败"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"todo": todo, "restored": len(restored)})
+}
+
+// PurgeTrashedTodo 永久删除回收站中的单个待办事项
+// 同一批删除的子任务仍留在回收站中，可以单独恢复 (恢复为顶层任务)
+func PurgeTrashedTodo(c *gin.Context) {
+	// 从上下文中获取当前用户ID
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "用户未认证"})
+		return
+	}
+	currentUserID := userID.(uint)
+
+	id, ok := trashTodoID(c)
+	if !ok {
+		return
+	}
+
+	workspaceID := c.GetUint("workspace_id")
+	n, err := models.PurgeTrash(func(db *gorm.DB) *gorm.DB {
+		return db.Where("id = ? AND user_id = ? AND workspace_id = ?", id, currentUserID, workspaceID)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "永久删除失败"})
+		return
+	}
+	if n == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "回收站中未找到该待办事项"})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// EmptyTrash 清空当前用户在当前工作区中的回收站，待办事项被永久删除且无法恢复
+func EmptyTrash(c *gin.Context) {
+	// 从上下文中获取当前用户ID
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "用户未认证"})
+		return
+	}
+	currentUserID := userID.(uint)
+
+	n, err := models.EmptyTrash(currentUserID, c.GetUint("workspace_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "清空回收站失败", "deleted": n})
+		return
+	}
+	fmt.Printf("User %d emptied trash: %d todos purged\n", currentUserID, n)
+	c.JSON(http.StatusOK, gin.H{"deleted": n})
+}
@@ -4,12 +4,14 @@ import (
 	"fmt"
 	"net/http"
 	"os"
+	"strings"
 	"time"
 	"todolist/models"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt"
 	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
 )
 
 // jwtKey 从环境变量获取，不再提供默认值
@@ -64,8 +66,15 @@ func Register(c *gin.Context) {
 		Password: string(hashedPassword),
 	}
 
-	// 创建用户
-	if err := models.DB.Create(&user).Error; err != nil {
+	// 创建用户，同时创建他的个人工作区
+	err = models.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&user).Error; err != nil {
+			return err
+		}
+		_, err := models.CreateWorkspace(tx, models.PersonalWorkspaceName, user.ID, true)
+		return err
+	})
+	if err != nil {
 		fmt.Printf("创建用户失败: %v\n", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "创建用户失败"})
 		return
@@ -170,6 +179,22 @@ func AuthMiddleware() gin.HandlerFunc {
 	}
 }
 
+// AdminMiddleware 管理员权限中间件，需在 AuthMiddleware 之后使用
+// 管理员由环境变量 ADMIN_USERNAMES 配置，多个用户名以逗号分隔
+func AdminMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		username := c.GetString("username")
+		for _, name := range strings.Split(os.Getenv("ADMIN_USERNAMES"), ",") {
+			if name = strings.TrimSpace(name); name != "" && name == username {
+				c.Next()
+				return
+			}
+		}
+		c.JSON(http.StatusForbidden, gin.H{"error": "需要管理员权限"})
+		c.Abort()
+	}
+}
+
 // ChangePasswordRequest 修改密码请求结构
 type ChangePasswordRequest struct {
 	OldPassword string `json:"old_password" binding:"required"`
@@ -229,3 +254,85 @@ func ChangePassword(c *gin.Context) {
 
 	c.JSON(http.StatusOK, gin.H{"message": "密码修改成功"})
 }
+
+// UpdateSettingsRequest 修改用户设置请求结构
+type UpdateSettingsRequest struct {
+	Timezone        *string `json:"timezone"`
+	AutoArchiveDays *int    `json:"auto_archive_days"` // 0 表示关闭自动归档
+}
+
+// settingsResponse 用户设置的响应
+func settingsResponse(user *models.User) gin.H {
+	return gin.H{"timezone": user.Location().String(), "auto_archive_days": user.AutoArchiveDays}
+}
+
+// GetSettings 获取当前用户的设置
+func GetSettings(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "用户未认证"})
+		return
+	}
+
+	var user models.User
+	if err := models.DB.First(&user, userID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取用户信息失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, settingsResponse(&user))
+}
+
+// UpdateSettings 修改当前用户的设置
+func UpdateSettings(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "用户未认证"})
+		return
+	}
+
+	var req UpdateSettingsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求数据"})
+		return
+	}
+
+	updates := map[string]interface{}{}
+	if req.Timezone != nil {
+		// 校验是否为有效的 IANA 时区名，如 Asia/Shanghai
+		if _, err := time.LoadLocation(*req.Timezone); err != nil || *req.Timezone == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "无效的时区"})
+			return
+		}
+		updates["timezone"] = *req.Timezone
+	}
+	if req.AutoArchiveDays != nil {
+		if *req.AutoArchiveDays < 0 || *req.AutoArchiveDays > models.MaxAutoArchiveDays {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("auto_archive_days 必须为 0 到 %d 之间的整数", models.MaxAutoArchiveDays)})
+			return
+		}
+		updates["auto_archive_days"] = *req.AutoArchiveDays
+	}
+	if len(updates) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "没有需要修改的设置"})
+		return
+	}
+
+	var user models.User
+	if err := models.DB.First(&user, userID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取用户信息失败"})
+		return
+	}
+	if err := models.DB.Model(&user).Updates(updates).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "更新设置失败"})
+		return
+	}
+	if req.Timezone != nil {
+		user.Timezone = *req.Timezone
+	}
+	if req.AutoArchiveDays != nil {
+		user.AutoArchiveDays = *req.AutoArchiveDays
+	}
+
+	c.JSON(http.StatusOK, settingsResponse(&user))
+}
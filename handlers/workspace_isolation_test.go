@@ -0,0 +1,345 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"testing"
+	"time"
+	"todolist/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 工作区隔离测试需要真实的 MySQL 和 Redis，设置 TEST_DB_NAME 时才运行，
+// 连接参数与 InitDB 相同 (DB_HOST、DB_PORT、DB_USER、DB_PASSWORD、REDIS_ADDR)。
+// 使用单独的变量而不是 DB_NAME，避免误在开发数据库中写入测试数据
+const testDBEnv = "TEST_DB_NAME"
+
+var (
+	testDBOnce sync.Once
+	testDBErr  error
+)
+
+// requireTestDB 连接测试数据库，未配置时跳过测试
+func requireTestDB(t *testing.T) {
+	t.Helper()
+	name := os.Getenv(testDBEnv)
+	if name == "" {
+		t.Skipf("未设置 %s，跳过需要数据库的测试", testDBEnv)
+	}
+	testDBOnce.Do(func() {
+		os.Setenv("DB_NAME", name)
+		testDBErr = models.InitDB()
+	})
+	if testDBErr != nil {
+		t.Fatalf("连接测试数据库失败: %v", testDBErr)
+	}
+}
+
+// testRouter 注册需要数据库的测试用到的路由，与 cmd/api 中的注册方式相同；
+// 认证由 X-Test-User 请求头代替 JWT
+func testRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	auth := r.Group("/api")
+	auth.Use(func(c *gin.Context) {
+		var id uint
+		fmt.Sscan(c.GetHeader("X-Test-User"), &id)
+		c.Set("user_id", id)
+	})
+	auth.POST("/workspaces", CreateWorkspace)
+	auth.POST("/workspaces/:id/members", AddWorkspaceMember)
+
+	auth.Use(WorkspaceMiddleware())
+	auth.GET("/reminders", GetReminders)
+	auth.POST("/trash/:id/restore", RestoreTodo)
+	auth.DELETE("/trash/:id", PurgeTrashedTodo)
+	auth.GET("/time-entries/daily", GetDailyTimeTotals)
+	auth.GET("/time-entries/export", ExportTimeEntries)
+	auth.GET("/todos", GetAllTodos)
+	auth.GET("/todos/search", SearchTodos)
+	auth.GET("/todos/export", ExportTodos)
+	auth.GET("/todos/:id", GetTodoByID)
+	auth.POST("/todos", CreateTodo)
+	auth.PUT("/todos/:id", UpdateTodo)
+	auth.DELETE("/todos/:id", DeleteTodo)
+	auth.POST("/todos/:id/move", MoveTodo)
+	auth.POST("/todos/:id/archive", ArchiveTodo)
+	auth.POST("/todos/:id/dependencies", AddTodoDependencies)
+	auth.POST("/todos/:id/tags", AddTodoTags)
+	auth.POST("/todos/:id/reminders", CreateTodoReminder)
+	auth.POST("/todos/:id/time-entries", CreateTimeEntry)
+	auth.POST("/projects", CreateProject)
+	auth.POST("/projects/:id/members", AddProjectMember)
+	auth.GET("/tags", GetTags)
+	auth.POST("/tags", CreateTag)
+	return r
+}
+
+// testClient 以某个用户的身份在某个工作区中发送请求，workspaceID 为 0 时不带工作区请求头
+type testClient struct {
+	t           *testing.T
+	router      *gin.Engine
+	userID      uint
+	workspaceID uint
+}
+
+func (tc testClient) in(workspaceID uint) testClient {
+	tc.workspaceID = workspaceID
+	return tc
+}
+
+// do 发送请求，out 不为 nil 时解析 JSON 响应
+func (tc testClient) do(method, path string, body interface{}, out interface{}) int {
+	tc.t.Helper()
+	var reader *bytes.Reader
+	if body != nil {
+		b, _ := json.Marshal(body)
+		reader = bytes.NewReader(b)
+	} else {
+		reader = bytes.NewReader(nil)
+	}
+	req := httptest.NewRequest(method, path, reader)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Test-User", fmt.Sprint(tc.userID))
+	if tc.workspaceID != 0 {
+		req.Header.Set(WorkspaceHeader, fmt.Sprint(tc.workspaceID))
+	}
+	w := httptest.NewRecorder()
+	tc.router.ServeHTTP(w, req)
+	if out != nil && w.Code < 300 {
+		if err := json.Unmarshal(w.Body.Bytes(), out); err != nil {
+			tc.t.Fatalf("%s %s: 解析响应失败: %v\n%s", method, path, err, w.Body.String())
+		}
+	}
+	return w.Code
+}
+
+// mustDo 发送请求并要求返回指定的状态码
+func (tc testClient) mustDo(want int, method, path string, body interface{}, out interface{}) {
+	tc.t.Helper()
+	if code := tc.do(method, path, body, out); code != want {
+		tc.t.Fatalf("%s %s (工作区 %d) = %d, want %d", method, path, tc.workspaceID, code, want)
+	}
+}
+
+// createTestUser 创建用户及其个人工作区，用户名带上时间戳以便在同一个数据库中重复运行
+func createTestUser(t *testing.T, name string) (uint, uint) {
+	t.Helper()
+	user := models.User{Username: fmt.Sprintf("%s-%d", name, time.Now().UnixNano()), Password: "x"}
+	if err := models.DB.Create(&user).Error; err != nil {
+		t.Fatalf("创建用户失败: %v", err)
+	}
+	if err := models.EnsurePersonalWorkspaces(); err != nil {
+		t.Fatalf("创建个人工作区失败: %v", err)
+	}
+	wsID, err := models.PersonalWorkspaceID(models.DB, user.ID)
+	if err != nil {
+		t.Fatalf("获取个人工作区失败: %v", err)
+	}
+	return user.ID, wsID
+}
+
+// containsTodo 判断待办事项列表中是否有指定ID
+func containsTodo(todos []models.Todo, id uint) bool {
+	for _, t := range todos {
+		if t.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// TestWorkspaceIsolation 个人工作区中的数据在团队工作区中不可见，反之亦然
+func TestWorkspaceIsolation(t *testing.T) {
+	requireTestDB(t)
+	router := testRouter()
+
+	aliceID, personal := createTestUser(t, "alice")
+	bobID, _ := createTestUser(t, "bob")
+	carolID, _ := createTestUser(t, "carol")
+	var bob, carol models.User
+	models.DB.First(&bob, bobID)
+	models.DB.First(&carol, carolID)
+
+	alice := testClient{t: t, router: router, userID: aliceID}
+	var team models.Workspace
+	alice.mustDo(http.StatusCreated, "POST", "/api/workspaces", gin.H{"name": "团队"}, &team)
+	alice.mustDo(http.StatusCreated, "POST", fmt.Sprintf("/api/workspaces/%d/members", team.ID), gin.H{"username": bob.Username, "role": models.WorkspaceRoleMember}, nil)
+
+	// 个人工作区中的待办事项，带提醒、时间记录和标签；另一条放入回收站
+	var secret, trashed, teamTodo models.Todo
+	alice.in(personal).mustDo(http.StatusCreated, "POST", "/api/todos", gin.H{"todo": gin.H{"title": "个人计划 zebra"}}, &secret)
+	alice.in(personal).mustDo(http.StatusCreated, "POST", "/api/todos", gin.H{"todo": gin.H{"title": "已删除的个人计划"}}, &trashed)
+	alice.in(personal).mustDo(http.StatusNoContent, "DELETE", fmt.Sprintf("/api/todos/%d", trashed.ID), nil, nil)
+	alice.in(personal).mustDo(http.StatusCreated, "POST", fmt.Sprintf("/api/todos/%d/reminders", secret.ID), gin.H{"remind_at": time.Now().Add(24 * time.Hour).UTC().Format(time.RFC3339)}, nil)
+	alice.in(personal).mustDo(http.StatusCreated, "POST", fmt.Sprintf("/api/todos/%d/time-entries", secret.ID), gin.H{"started_at": time.Now().Add(-2 * time.Hour).UTC().Format(time.RFC3339), "duration_minutes": 30}, nil)
+	var personalTag models.Tag
+	alice.in(personal).mustDo(http.StatusCreated, "POST", "/api/tags", gin.H{"name": "私人"}, &personalTag)
+
+	alice.in(team.ID).mustDo(http.StatusCreated, "POST", "/api/todos", gin.H{"todo": gin.H{"title": "团队任务"}}, &teamTodo)
+	if teamTodo.WorkspaceID != team.ID || secret.WorkspaceID != personal {
+		t.Fatalf("待办事项的工作区不对: team=%d personal=%d", teamTodo.WorkspaceID, secret.WorkspaceID)
+	}
+	inTeam := alice.in(team.ID)
+
+	t.Run("列表", func(t *testing.T) {
+		var page todoPage
+		inTeam.mustDo(http.StatusOK, "GET", "/api/todos", nil, &page)
+		if containsTodo(page.Todos, secret.ID) || !containsTodo(page.Todos, teamTodo.ID) {
+			t.Errorf("团队工作区的列表应只包含团队中的待办事项: %+v", page.Todos)
+		}
+		alice.in(personal).mustDo(http.StatusOK, "GET", "/api/todos", nil, &page)
+		if !containsTodo(page.Todos, secret.ID) || containsTodo(page.Todos, teamTodo.ID) {
+			t.Errorf("个人工作区的列表应只包含个人的待办事项: %+v", page.Todos)
+		}
+	})
+
+	t.Run("详情", func(t *testing.T) {
+		path := fmt.Sprintf("/api/todos/%d", secret.ID)
+		models.Rdb.Del(models.Ctx, getTodoKey(secret.ID))
+		inTeam.mustDo(http.StatusNotFound, "GET", path, nil, nil)
+		// 在个人工作区中读取一次写入缓存，再从团队工作区读取应命中缓存并返回 404
+		alice.in(personal).mustDo(http.StatusOK, "GET", path, nil, nil)
+		if n, _ := models.Rdb.Exists(models.Ctx, getTodoKey(secret.ID)).Result(); n != 1 {
+			t.Fatal("详情应写入缓存")
+		}
+		inTeam.mustDo(http.StatusNotFound, "GET", path, nil, nil)
+		inTeam.mustDo(http.StatusNotFound, "GET", path+"?include=children", nil, nil)
+	})
+
+	t.Run("修改", func(t *testing.T) {
+		inTeam.mustDo(http.StatusNotFound, "PUT", fmt.Sprintf("/api/todos/%d", secret.ID), gin.H{"title": "被改掉了"}, nil)
+		var got models.Todo
+		models.DB.First(&got, secret.ID)
+		if got.Title != secret.Title {
+			t.Errorf("标题被修改为 %q", got.Title)
+		}
+	})
+
+	t.Run("搜索", func(t *testing.T) {
+		var resp struct {
+			Results []searchResult `json:"results"`
+		}
+		inTeam.mustDo(http.StatusOK, "GET", "/api/todos/search?q=zebra", nil, &resp)
+		for _, r := range resp.Results {
+			if r.Todo.ID == secret.ID {
+				t.Error("团队工作区中搜到了个人的待办事项")
+			}
+		}
+		alice.in(personal).mustDo(http.StatusOK, "GET", "/api/todos/search?q=zebra", nil, &resp)
+		if len(resp.Results) == 0 || resp.Results[0].Todo.ID != secret.ID {
+			t.Errorf("个人工作区中应能搜到: %+v", resp.Results)
+		}
+	})
+
+	t.Run("导出", func(t *testing.T) {
+		var resp struct {
+			Todos []models.Todo `json:"todos"`
+		}
+		inTeam.mustDo(http.StatusOK, "GET", "/api/todos/export?format=json", nil, &resp)
+		if containsTodo(resp.Todos, secret.ID) || !containsTodo(resp.Todos, teamTodo.ID) {
+			t.Errorf("团队工作区的导出应只包含团队中的待办事项: %+v", resp.Todos)
+		}
+	})
+
+	t.Run("回收站", func(t *testing.T) {
+		path := fmt.Sprintf("/api/trash/%d", trashed.ID)
+		inTeam.mustDo(http.StatusNotFound, "POST", path+"/restore", nil, nil)
+		inTeam.mustDo(http.StatusNotFound, "DELETE", path, nil, nil)
+		var count int64
+		models.DB.Unscoped().Model(&models.Todo{}).Where("id = ?", trashed.ID).Count(&count)
+		if count != 1 {
+			t.Fatal("回收站中的待办事项被彻底删除")
+		}
+		if code := alice.in(personal).do("POST", path+"/restore", nil, nil); code != http.StatusOK {
+			t.Errorf("个人工作区中恢复 = %d, want 200", code)
+		}
+	})
+
+	t.Run("提醒", func(t *testing.T) {
+		var reminders []models.Reminder
+		inTeam.mustDo(http.StatusOK, "GET", "/api/reminders", nil, &reminders)
+		for _, r := range reminders {
+			if r.TodoID == secret.ID {
+				t.Error("团队工作区中列出了个人待办事项的提醒")
+			}
+		}
+		alice.in(personal).mustDo(http.StatusOK, "GET", "/api/reminders", nil, &reminders)
+		if len(reminders) != 1 || reminders[0].TodoID != secret.ID {
+			t.Errorf("个人工作区中的提醒 = %+v", reminders)
+		}
+	})
+
+	t.Run("时间报表", func(t *testing.T) {
+		var daily struct {
+			TotalSeconds int64 `json:"total_seconds"`
+		}
+		inTeam.mustDo(http.StatusOK, "GET", "/api/time-entries/daily", nil, &daily)
+		if daily.TotalSeconds != 0 {
+			t.Errorf("团队工作区的时长 = %d, want 0", daily.TotalSeconds)
+		}
+		alice.in(personal).mustDo(http.StatusOK, "GET", "/api/time-entries/daily", nil, &daily)
+		if daily.TotalSeconds != 30*60 {
+			t.Errorf("个人工作区的时长 = %d, want %d", daily.TotalSeconds, 30*60)
+		}
+		var export struct {
+			Entries []models.TimeEntry `json:"entries"`
+		}
+		inTeam.mustDo(http.StatusOK, "GET", "/api/time-entries/export?format=json", nil, &export)
+		if len(export.Entries) != 0 {
+			t.Errorf("团队工作区导出了时间记录: %+v", export.Entries)
+		}
+	})
+
+	t.Run("标签", func(t *testing.T) {
+		var tags []models.Tag
+		inTeam.mustDo(http.StatusOK, "GET", "/api/tags", nil, &tags)
+		for _, tag := range tags {
+			if tag.ID == personalTag.ID {
+				t.Error("团队工作区中列出了个人工作区的标签")
+			}
+		}
+		inTeam.mustDo(http.StatusBadRequest, "POST", fmt.Sprintf("/api/todos/%d/tags", teamTodo.ID), gin.H{"tag_ids": []uint{personalTag.ID}}, nil)
+		// 同名标签可以在团队工作区中再创建一个
+		inTeam.mustDo(http.StatusCreated, "POST", "/api/tags", gin.H{"name": personalTag.Name}, nil)
+	})
+
+	t.Run("非成员访问工作区", func(t *testing.T) {
+		carolClient := testClient{t: t, router: router, userID: carolID}
+		carolClient.in(team.ID).mustDo(http.StatusNotFound, "GET", "/api/todos", nil, nil)
+		bobClient := testClient{t: t, router: router, userID: bobID}
+		bobClient.in(personal).mustDo(http.StatusNotFound, "GET", fmt.Sprintf("/api/todos/%d", secret.ID), nil, nil)
+	})
+
+	t.Run("同一工作区中别人的待办事项", func(t *testing.T) {
+		// 团队成员看不到不在共享项目中的待办事项，命中缓存时同样返回 404 而不是 403
+		path := fmt.Sprintf("/api/todos/%d", teamTodo.ID)
+		bobClient := testClient{t: t, router: router, userID: bobID, workspaceID: team.ID}
+		models.Rdb.Del(models.Ctx, getTodoKey(teamTodo.ID))
+		bobClient.mustDo(http.StatusNotFound, "GET", path, nil, nil)
+		inTeam.mustDo(http.StatusOK, "GET", path, nil, nil)
+		bobClient.mustDo(http.StatusNotFound, "GET", path, nil, nil)
+	})
+
+	t.Run("项目成员必须是工作区成员", func(t *testing.T) {
+		var project models.Project
+		inTeam.mustDo(http.StatusCreated, "POST", "/api/projects", gin.H{"name": "团队项目"}, &project)
+		path := fmt.Sprintf("/api/projects/%d/members", project.ID)
+		inTeam.mustDo(http.StatusBadRequest, "POST", path, gin.H{"username": carol.Username, "role": models.RoleViewer}, nil)
+		inTeam.mustDo(http.StatusCreated, "POST", path, gin.H{"username": bob.Username, "role": models.RoleViewer}, nil)
+
+		// 个人工作区不能添加成员，因此个人项目也不能共享
+		var own models.Project
+		alice.in(personal).mustDo(http.StatusCreated, "POST", "/api/projects", gin.H{"name": "个人项目"}, &own)
+		code := alice.in(personal).do("POST", fmt.Sprintf("/api/projects/%d/members", own.ID), gin.H{"username": bob.Username, "role": models.RoleViewer}, nil)
+		if code != http.StatusBadRequest {
+			t.Errorf("个人项目添加成员 = %d, want 400", code)
+		}
+	})
+}
@@ -0,0 +1,444 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"todolist/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// 工作区名最大长度 (按字符计)
+const maxWorkspaceNameRunes = 128
+
+// WorkspaceHeader 指定当前工作区的请求头，未传时使用用户的个人工作区
+const WorkspaceHeader = "X-Workspace-ID"
+
+// errLastAdmin 工作区至少要保留一个 admin
+var errLastAdmin = errors.New("工作区至少需要保留一个管理员")
+
+// WorkspaceRequest 创建/修改工作区的请求结构
+type WorkspaceRequest struct {
+	Name string `json:"name"`
+}
+
+// WorkspaceMemberRequest 添加/修改工作区成员的请求结构，修改时只需要 role
+type WorkspaceMemberRequest struct {
+	Username string `json:"username"`
+	Role     string `json:"role"`
+}
+
+// workspaceMemberView 工作区成员的响应结构
+type workspaceMemberView struct {
+	UserID    uint      `json:"user_id"`
+	Username  string    `json:"username"`
+	Role      string    `json:"role"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// WorkspaceMiddleware 确定请求的当前工作区，需在 AuthMiddleware 之后使用
+// 请求头 X-Workspace-ID 指定工作区，未指定时为用户的个人工作区；用户不是该工作区的成员时返回 404，
+// 不区分工作区不存在和无权访问，避免泄露其他工作区的存在
+func WorkspaceMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.GetUint("user_id")
+		raw := c.GetHeader(WorkspaceHeader)
+		if raw == "" {
+			id, err := models.PersonalWorkspaceID(models.DB, userID)
+			if err != nil {
+				fmt.Printf("Load personal workspace error for user %d: %v\n", userID, err)
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "获取个人工作区失败"})
+				c.Abort()
+				return
+			}
+			c.Set("workspace_id", id)
+			c.Next()
+			return
+		}
+
+		id, err := strconv.ParseUint(raw, 10, 64)
+		if err != nil || id == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "无效的 " + WorkspaceHeader})
+			c.Abort()
+			return
+		}
+		role, err := models.WorkspaceRole(models.DB, userID, uint(id))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "检查权限失败"})
+			c.Abort()
+			return
+		}
+		if role == "" {
+			c.JSON(http.StatusNotFound, gin.H{"error": "工作区未找到或无权访问"})
+			c.Abort()
+			return
+		}
+		c.Set("workspace_id", uint(id))
+		c.Next()
+	}
+}
+
+// loadMemberWorkspace 查找路径中当前用户所属的工作区，返回他在工作区中的角色
+// 不是成员时写入 404 响应，adminOnly 为 true 且不是 admin 时写入 403 响应
+func loadMemberWorkspace(c *gin.Context, userID uint, adminOnly bool) (*models.Workspace, string, bool) {
+	var ws models.Workspace
+	if err := models.DB.Where("id = ?", c.Param("id")).First(&ws).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "工作区未找到或无权访问"})
+		return nil, "", false
+	}
+	role, err := models.WorkspaceRole(models.DB, userID, ws.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "检查权限失败"})
+		return nil, "", false
+	}
+	if role == "" {
+		c.JSON(http.StatusNotFound, gin.H{"error": "工作区未找到或无权访问"})
+		return nil, "", false
+	}
+	if adminOnly && role != models.WorkspaceRoleAdmin {
+		c.JSON(http.StatusForbidden, gin.H{"error": "当前角色无权执行该操作"})
+		return nil, "", false
+	}
+	ws.Role = role
+	return &ws, role, true
+}
+
+// validWorkspaceName 校验并规范化工作区名
+func validWorkspaceName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", errors.New("工作区名不能为空")
+	}
+	if len([]rune(name)) > maxWorkspaceNameRunes {
+		return "", fmt.Errorf("工作区名不能超过 %d 个字符", maxWorkspaceNameRunes)
+	}
+	return name, nil
+}
+
+// checkKeepsAdmin 移除或降级成员前检查工作区是否还有其他 admin
+func checkKeepsAdmin(tx *gorm.DB, member *models.WorkspaceMember) error {
+	if member.Role != models.WorkspaceRoleAdmin {
+		return nil
+	}
+	var admins int64
+	err := tx.Model(&models.WorkspaceMember{}).
+		Where("workspace_id = ? AND role = ? AND user_id <> ?", member.WorkspaceID, models.WorkspaceRoleAdmin, member.UserID).
+		Count(&admins).Error
+	if err != nil {
+		return err
+	}
+	if admins == 0 {
+		return errLastAdmin
+	}
+	return nil
+}
+
+// GetWorkspaces 返回当前用户所属的工作区，role 为他在工作区中的角色，个人工作区在最前面
+func GetWorkspaces(c *gin.Context) {
+	// 从上下文中获取当前用户ID
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "用户未认证"})
+		return
+	}
+	currentUserID := userID.(uint)
+
+	var members []models.WorkspaceMember
+	if err := models.DB.Where("user_id = ?", currentUserID).Find(&members).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取工作区失败"})
+		return
+	}
+	roles := make(map[uint]string, len(members))
+	ids := make([]uint, 0, len(members))
+	for _, m := range members {
+		roles[m.WorkspaceID] = m.Role
+		ids = append(ids, m.WorkspaceID)
+	}
+	workspaces := []models.Workspace{}
+	if len(ids) > 0 {
+		if err := models.DB.Where("id IN ?", ids).Order("personal DESC").Order("id ASC").Find(&workspaces).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "获取工作区失败"})
+			return
+		}
+	}
+	for i := range workspaces {
+		workspaces[i].Role = roles[workspaces[i].ID]
+	}
+	c.JSON(http.StatusOK, workspaces)
+}
+
+// CreateWorkspace 创建团队工作区，创建者成为 admin
+func CreateWorkspace(c *gin.Context) {
+	// 从上下文中获取当前用户ID
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "用户未认证"})
+		return
+	}
+	currentUserID := userID.(uint)
+
+	var req WorkspaceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求数据"})
+		return
+	}
+	name, err := validWorkspaceName(req.Name)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var ws *models.Workspace
+	err = models.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		ws, err = models.CreateWorkspace(tx, name, currentUserID, false)
+		return err
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "创建工作区失败"})
+		return
+	}
+	c.JSON(http.StatusCreated, ws)
+}
+
+// UpdateWorkspace 修改工作区名，需要 admin 角色
+func UpdateWorkspace(c *gin.Context) {
+	// 从上下文中获取当前用户ID
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "用户未认证"})
+		return
+	}
+
+	ws, _, ok := loadMemberWorkspace(c, userID.(uint), true)
+	if !ok {
+		return
+	}
+	var req WorkspaceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求数据"})
+		return
+	}
+	name, err := validWorkspaceName(req.Name)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := models.DB.Model(ws).Update("name", name).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "修改工作区失败"})
+		return
+	}
+	c.JSON(http.StatusOK, ws)
+}
+
+// DeleteWorkspace 删除团队工作区，需要 admin 角色
+// 工作区中还有待办事项 (包括回收站中的) 或项目时拒绝删除，个人工作区不能删除
+func DeleteWorkspace(c *gin.Context) {
+	// 从上下文中获取当前用户ID
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "用户未认证"})
+		return
+	}
+
+	ws, _, ok := loadMemberWorkspace(c, userID.(uint), true)
+	if !ok {
+		return
+	}
+	if ws.Personal {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "个人工作区不能删除"})
+		return
+	}
+	err := models.DeleteWorkspace(ws)
+	if errors.Is(err, models.ErrWorkspaceNotEmpty) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "删除工作区失败"})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// GetWorkspaceMembers 返回工作区的成员，所有成员都可以查看
+func GetWorkspaceMembers(c *gin.Context) {
+	// 从上下文中获取当前用户ID
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "用户未认证"})
+		return
+	}
+
+	ws, _, ok := loadMemberWorkspace(c, userID.(uint), false)
+	if !ok {
+		return
+	}
+	var members []models.WorkspaceMember
+	if err := models.DB.Where("workspace_id = ?", ws.ID).Order("created_at ASC").Find(&members).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取工作区成员失败"})
+		return
+	}
+	userIDs := make([]uint, len(members))
+	for i, m := range members {
+		userIDs[i] = m.UserID
+	}
+	var users []models.User
+	if err := models.DB.Select("id", "username").Where("id IN ?", userIDs).Find(&users).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取工作区成员失败"})
+		return
+	}
+	names := make(map[uint]string, len(users))
+	for _, u := range users {
+		names[u.ID] = u.Username
+	}
+
+	views := make([]workspaceMemberView, len(members))
+	for i, m := range members {
+		views[i] = workspaceMemberView{UserID: m.UserID, Username: names[m.UserID], Role: m.Role, CreatedAt: m.CreatedAt}
+	}
+	c.JSON(http.StatusOK, views)
+}
+
+// AddWorkspaceMember 按用户名将用户加入团队工作区，需要 admin 角色；个人工作区不能添加成员
+func AddWorkspaceMember(c *gin.Context) {
+	// 从上下文中获取当前用户ID
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "用户未认证"})
+		return
+	}
+
+	ws, _, ok := loadMemberWorkspace(c, userID.(uint), true)
+	if !ok {
+		return
+	}
+	if ws.Personal {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "个人工作区不能添加成员"})
+		return
+	}
+	var req WorkspaceMemberRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.Username == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求数据"})
+		return
+	}
+	if !models.ValidWorkspaceRole(req.Role) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "role 必须为 admin 或 member"})
+		return
+	}
+	var user models.User
+	if err := models.DB.Where("username = ?", req.Username).First(&user).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "用户不存在"})
+		return
+	}
+
+	member := models.WorkspaceMember{WorkspaceID: ws.ID, UserID: user.ID, Role: req.Role}
+	result := models.DB.Where("workspace_id = ? AND user_id = ?", ws.ID, user.ID).FirstOrCreate(&member)
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "添加工作区成员失败"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "该用户已是工作区成员"})
+		return
+	}
+	c.JSON(http.StatusCreated, workspaceMemberView{UserID: user.ID, Username: user.Username, Role: member.Role, CreatedAt: member.CreatedAt})
+}
+
+// UpdateWorkspaceMember 修改成员的角色，需要 admin 角色；不能降级最后一个 admin
+func UpdateWorkspaceMember(c *gin.Context) {
+	// 从上下文中获取当前用户ID
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "用户未认证"})
+		return
+	}
+
+	ws, _, ok := loadMemberWorkspace(c, userID.(uint), true)
+	if !ok {
+		return
+	}
+	var req WorkspaceMemberRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求数据"})
+		return
+	}
+	if !models.ValidWorkspaceRole(req.Role) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "role 必须为 admin 或 member"})
+		return
+	}
+
+	var member models.WorkspaceMember
+	err := models.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("workspace_id = ? AND user_id = ?", ws.ID, c.Param("user_id")).First(&member).Error; err != nil {
+			return err
+		}
+		if req.Role != models.WorkspaceRoleAdmin {
+			if err := checkKeepsAdmin(tx, &member); err != nil {
+				return err
+			}
+		}
+		return tx.Model(&member).Update("role", req.Role).Error
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "工作区成员未找到"})
+		return
+	} else if errors.Is(err, errLastAdmin) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "修改工作区成员失败"})
+		return
+	}
+	c.JSON(http.StatusOK, member)
+}
+
+// RemoveWorkspaceMember 将成员移出工作区 (带缓存清除)
+// admin 可以移除任何成员，其他成员只能移除自己 (退出工作区)；不能移除最后一个 admin。
+// 被移除的成员同时失去该工作区中所有共享项目的访问权限，他创建的待办事项和项目仍保留在工作区中
+func RemoveWorkspaceMember(c *gin.Context) {
+	// 从上下文中获取当前用户ID
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "用户未认证"})
+		return
+	}
+	currentUserID := userID.(uint)
+
+	ws, role, ok := loadMemberWorkspace(c, currentUserID, false)
+	if !ok {
+		return
+	}
+	var member models.WorkspaceMember
+	if err := models.DB.Where("workspace_id = ? AND user_id = ?", ws.ID, c.Param("user_id")).First(&member).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "工作区成员未找到"})
+		return
+	}
+	if member.UserID != currentUserID && role != models.WorkspaceRoleAdmin {
+		c.JSON(http.StatusForbidden, gin.H{"error": "当前角色无权执行该操作"})
+		return
+	}
+
+	err := models.DB.Transaction(func(tx *gorm.DB) error {
+		if err := checkKeepsAdmin(tx, &member); err != nil {
+			return err
+		}
+		return models.RemoveWorkspaceMember(tx, ws.ID, member.UserID)
+	})
+	if errors.Is(err, errLastAdmin) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "移除工作区成员失败"})
+		return
+	}
+
+	// --- 清除相关缓存 ---
+	clearUserCache(member.UserID) // 该工作区共享项目中的待办事项从他的列表中消失
+
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,37 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"time"
+	"todolist/models"
+)
+
+const (
+	// 自动归档的间隔
+	autoArchiveInterval = time.Hour
+	// 自动归档任务的 Redis 锁，保证多个实例中同一时间只有一个在归档
+	autoArchiveLockKey = "jobs:auto-archive:lock"
+)
+
+// StartAutoArchiver 定期按用户的 auto_archive_days 设置归档已完成的待办事项
+// 归档后递增用户的列表缓存版本号并删除单条缓存，缓存中不会残留已归档的待办事项
+func StartAutoArchiver(ctx context.Context) {
+	Every(ctx, "auto-archive", autoArchiveInterval, func(now time.Time) error {
+		ok, err := models.Rdb.SetNX(models.Ctx, autoArchiveLockKey, 1, autoArchiveInterval/2).Result()
+		if err != nil || !ok {
+			return err
+		}
+		archived, err := models.AutoArchive(now)
+		// 出错前已归档的部分同样需要清除缓存
+		for userID, ids := range archived {
+			models.Rdb.Incr(models.Ctx, models.UserTodosVersionKey(userID))
+			for _, id := range ids {
+				models.Rdb.Del(models.Ctx, models.TodoCacheKey(id))
+			}
+			clearMemberCaches(ids)
+			fmt.Printf("已为用户 %d 自动归档 %d 个待办事项\n", userID, len(ids))
+		}
+		return err
+	})
+}
@@ -0,0 +1,33 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"time"
+	"todolist/models"
+)
+
+const (
+	// 清理孤立附件内容的间隔
+	blobCleanupInterval = time.Hour
+	// 只清理登记超过该时间的内容，给正在进行的上传留出时间
+	blobCleanupGrace = time.Hour
+	// 清理任务的 Redis 锁
+	blobCleanupLockKey = "jobs:blob-cleanup:lock"
+)
+
+// StartBlobCleaner 定期删除不再被任何附件引用的内容
+// 附件被删除或所属待办事项从回收站永久删除后，内容在下一轮清理中删除
+func StartBlobCleaner(ctx context.Context) {
+	Every(ctx, "blob-cleanup", blobCleanupInterval, func(now time.Time) error {
+		ok, err := models.Rdb.SetNX(models.Ctx, blobCleanupLockKey, 1, blobCleanupInterval/2).Result()
+		if err != nil || !ok {
+			return err
+		}
+		n, err := models.CleanupOrphanBlobs(now.Add(-blobCleanupGrace))
+		if n > 0 {
+			fmt.Printf("已清理 %d 个不再被引用的附件内容\n", n)
+		}
+		return err
+	})
+}
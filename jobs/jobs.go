@@ -0,0 +1,40 @@
+// Package jobs 运行在 API 进程内的后台任务，如提醒调度。
+// 任务可能在多个实例上同时运行，需要自行保证不重复处理 (如借助 Redis 原子操作)。
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"time"
+	"todolist/models"
+)
+
+// Every 在后台每隔 interval 执行一次 fn，直到 ctx 取消；出错时记录日志后继续
+func Every(ctx context.Context, name string, interval time.Duration, fn func(now time.Time) error) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-ticker.C:
+				if err := fn(now); err != nil {
+					fmt.Printf("后台任务 %s 执行失败: %v\n", name, err)
+				}
+			}
+		}
+	}()
+}
+
+// clearMemberCaches 递增能通过共享项目看到这些待办事项的成员的列表缓存版本号
+func clearMemberCaches(todoIDs []uint) {
+	memberIDs, err := models.TodoMemberIDs(models.DB, todoIDs)
+	if err != nil {
+		fmt.Printf("Load project members error: %v\n", err)
+		return
+	}
+	for _, id := range memberIDs {
+		models.Rdb.Incr(models.Ctx, models.UserTodosVersionKey(id))
+	}
+}
@@ -0,0 +1,45 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"time"
+	"todolist/models"
+)
+
+const (
+	// 重新分配排序键的间隔
+	rebalanceInterval = time.Hour
+	// 重新分配任务的 Redis 锁，保证多个实例中同一时间只有一个在执行
+	rebalanceLockKey = "jobs:rebalance-positions:lock"
+)
+
+// StartPositionRebalancer 定期为排序键过长或缺失的清单重新分配等间距的键
+// 启动时立即执行一次，为升级前创建、还没有排序键的待办事项按创建顺序补齐
+func StartPositionRebalancer(ctx context.Context) {
+	run := func(now time.Time) error {
+		ok, err := models.Rdb.SetNX(models.Ctx, rebalanceLockKey, 1, rebalanceInterval/2).Result()
+		if err != nil || !ok {
+			return err
+		}
+		changed, err := models.RebalancePositions()
+		// 排序键会出现在列表和单条缓存中，出错前已修改的部分同样需要清除
+		for userID, ids := range changed {
+			models.Rdb.Incr(models.Ctx, models.UserTodosVersionKey(userID))
+			for _, id := range ids {
+				models.Rdb.Del(models.Ctx, models.TodoCacheKey(id))
+			}
+			clearMemberCaches(ids)
+		}
+		if len(changed) > 0 {
+			fmt.Printf("已为 %d 个用户重新分配排序键\n", len(changed))
+		}
+		return err
+	}
+	go func() {
+		if err := run(time.Now()); err != nil {
+			fmt.Printf("后台任务 rebalance-positions 执行失败: %v\n", err)
+		}
+	}()
+	Every(ctx, "rebalance-positions", rebalanceInterval, run)
+}
@@ -0,0 +1,125 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+	"todolist/models"
+
+	"github.com/go-redis/redis/v8"
+)
+
+const (
+	// 调度器轮询延迟队列的间隔
+	reminderPollInterval = time.Second
+	// 每次轮询最多处理的提醒数
+	reminderBatchSize = 100
+	// 将已到期却不在队列中的提醒重新入队的间隔
+	reminderSweepInterval = time.Minute
+)
+
+// ReminderNotification 提醒触发时发布的通知内容
+type ReminderNotification struct {
+	ReminderID uint       `json:"reminder_id"`
+	TodoID     uint       `json:"todo_id"`
+	Title      string     `json:"title"`
+	DueAt      *time.Time `json:"due_at"`
+	FiredAt    time.Time  `json:"fired_at"`
+}
+
+// Notifier 提醒的投递方式
+type Notifier interface {
+	Notify(userID uint, n ReminderNotification) error
+}
+
+// redisNotifier 将通知发布到用户的 Redis 频道，由推送服务 (WebSocket 等) 订阅后转发
+type redisNotifier struct{}
+
+func (redisNotifier) Notify(userID uint, n ReminderNotification) error {
+	data, err := json.Marshal(n)
+	if err != nil {
+		return err
+	}
+	return models.Rdb.Publish(models.Ctx, models.ReminderChannel(userID), data).Err()
+}
+
+// StartReminderScheduler 恢复数据库中待触发的提醒，并启动调度器
+//
+// 待触发的提醒保存在 Redis 有序集合中，分数为触发时间。每个实例轮询到期的成员，
+// 先用 ZREM 认领 (只有一个实例能删除成功)，再以带条件的 UPDATE 标记为已触发，
+// 因此多个实例同时运行也不会重复提醒。入队失败或进程在认领后崩溃时，提醒在数据库中仍为待触发，
+// 由定期的检查 (以及重启时的恢复) 重新入队，错过的提醒随即触发。
+func StartReminderScheduler(ctx context.Context) error {
+	n, err := models.RecoverReminders()
+	if err != nil {
+		return fmt.Errorf("恢复提醒失败: %w", err)
+	}
+	fmt.Printf("已恢复 %d 个待触发的提醒\n", n)
+
+	notifier := redisNotifier{}
+	Every(ctx, "reminders", reminderPollInterval, func(now time.Time) error {
+		return fireDueReminders(now, notifier)
+	})
+	Every(ctx, "reminder-sweep", reminderSweepInterval, func(now time.Time) error {
+		n, err := models.RequeueDueReminders(now)
+		if n > 0 {
+			fmt.Printf("重新入队 %d 个到期的提醒\n", n)
+		}
+		return err
+	})
+	return nil
+}
+
+// fireDueReminders 触发所有到期的提醒
+func fireDueReminders(now time.Time, notifier Notifier) error {
+	members, err := models.Rdb.ZRangeByScore(models.Ctx, models.ReminderQueueKey, &redis.ZRangeBy{
+		Min:   "-inf",
+		Max:   strconv.FormatInt(now.Unix(), 10),
+		Count: reminderBatchSize,
+	}).Result()
+	if err != nil {
+		return err
+	}
+
+	for _, member := range members {
+		// 认领：只有删除成功的实例负责触发
+		removed, err := models.Rdb.ZRem(models.Ctx, models.ReminderQueueKey, member).Result()
+		if err != nil {
+			return err
+		}
+		if removed == 0 {
+			continue
+		}
+		id, err := strconv.ParseUint(member, 10, 64)
+		if err != nil {
+			continue
+		}
+		if err := fireReminder(uint(id), now, notifier); err != nil {
+			fmt.Printf("触发提醒 %d 失败: %v\n", id, err)
+		}
+	}
+	return nil
+}
+
+func fireReminder(id uint, now time.Time, notifier Notifier) error {
+	reminder, todo, err := models.ClaimReminder(id, now)
+	if errors.Is(err, models.ErrReminderNotDue) {
+		return nil
+	}
+	if err != nil {
+		// 数据库出错时放回队列，下次轮询重试
+		models.Rdb.ZAdd(models.Ctx, models.ReminderQueueKey, &redis.Z{Score: float64(now.Unix()), Member: strconv.FormatUint(uint64(id), 10)})
+		return err
+	}
+	fmt.Printf("提醒 %d 已触发 (待办 %d)\n", reminder.ID, todo.ID)
+	return notifier.Notify(reminder.UserID, ReminderNotification{
+		ReminderID: reminder.ID,
+		TodoID:     todo.ID,
+		Title:      todo.Title,
+		DueAt:      todo.DueAt,
+		FiredAt:    now,
+	})
+}
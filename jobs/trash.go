@@ -0,0 +1,33 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"time"
+	"todolist/models"
+)
+
+const (
+	// 清理回收站的间隔
+	trashPurgeInterval = time.Hour
+	// 清理任务的 Redis 锁，保证多个实例中同一时间只有一个在清理
+	trashPurgeLockKey = "jobs:trash-purge:lock"
+)
+
+// StartTrashPurger 定期永久删除在回收站中超过保留天数 (TRASH_RETENTION_DAYS) 的待办事项
+func StartTrashPurger(ctx context.Context) {
+	retention := time.Duration(models.TrashRetentionDays()) * 24 * time.Hour
+	fmt.Printf("回收站保留 %d 天\n", models.TrashRetentionDays())
+	Every(ctx, "trash-purge", trashPurgeInterval, func(now time.Time) error {
+		// 锁在下一次执行前过期，持锁实例崩溃也不影响后续清理
+		ok, err := models.Rdb.SetNX(models.Ctx, trashPurgeLockKey, 1, trashPurgeInterval/2).Result()
+		if err != nil || !ok {
+			return err
+		}
+		n, err := models.PurgeExpiredTrash(now.Add(-retention))
+		if n > 0 {
+			fmt.Printf("已永久删除 %d 个回收站中过期的待办事项\n", n)
+		}
+		return err
+	})
+}
@@ -0,0 +1,166 @@
+// Package lunar 提供农历与公历的相互转换，基于内置的 1900-2100 年农历数据表，不依赖网络。
+package lunar
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	// MinYear 和 MaxYear 为支持的农历年份范围
+	MinYear = 1900
+	MaxYear = MinYear + len(lunarInfo) - 1
+)
+
+// 农历 1900 年正月初一对应的公历日期
+var baseDate = time.Date(1900, time.January, 31, 0, 0, 0, 0, time.UTC)
+
+// ErrOutOfRange 日期超出内置数据表的范围
+var ErrOutOfRange = fmt.Errorf("仅支持农历 %d-%d 年", MinYear, MaxYear)
+
+// Date 农历日期
+type Date struct {
+	Year  int
+	Month int  // 1-12
+	Day   int  // 1-30
+	Leap  bool // 是否为闰月
+}
+
+func (d Date) String() string {
+	leap := ""
+	if d.Leap {
+		leap = "闰"
+	}
+	return fmt.Sprintf("%d年%s%d月%d日", d.Year, leap, d.Month, d.Day)
+}
+
+// LeapMonth 返回农历某年的闰月月份，没有闰月时返回 0
+func LeapMonth(year int) int {
+	if year < MinYear || year > MaxYear {
+		return 0
+	}
+	return int(lunarInfo[year-MinYear] & 0xf)
+}
+
+// DaysInMonth 返回农历某年某月的天数，月份不存在时返回 0
+func DaysInMonth(year, month int, leap bool) int {
+	if year < MinYear || year > MaxYear || month < 1 || month > 12 {
+		return 0
+	}
+	info := lunarInfo[year-MinYear]
+	if leap {
+		if LeapMonth(year) != month {
+			return 0
+		}
+		if info&0x10000 != 0 {
+			return 30
+		}
+		return 29
+	}
+	if info&(0x10000>>uint(month)) != 0 {
+		return 30
+	}
+	return 29
+}
+
+// daysInYear 返回农历某年的总天数
+func daysInYear(year int) int {
+	days := 0
+	for m := 1; m <= 12; m++ {
+		days += DaysInMonth(year, m, false)
+	}
+	if lm := LeapMonth(year); lm != 0 {
+		days += DaysInMonth(year, lm, true)
+	}
+	return days
+}
+
+// months 按顺序返回农历某年的所有月份 (闰月排在同号月份之后)
+func months(year int) []Date {
+	var out []Date
+	lm := LeapMonth(year)
+	for m := 1; m <= 12; m++ {
+		out = append(out, Date{Year: year, Month: m, Day: 1})
+		if m == lm {
+			out = append(out, Date{Year: year, Month: m, Day: 1, Leap: true})
+		}
+	}
+	return out
+}
+
+// FromSolar 将公历日期 (只看年月日) 转换为农历日期
+func FromSolar(t time.Time) (Date, error) {
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	offset := int(day.Sub(baseDate).Hours() / 24)
+	if offset < 0 {
+		return Date{}, ErrOutOfRange
+	}
+	for year := MinYear; year <= MaxYear; year++ {
+		n := daysInYear(year)
+		if offset >= n {
+			offset -= n
+			continue
+		}
+		for _, m := range months(year) {
+			n := DaysInMonth(year, m.Month, m.Leap)
+			if offset < n {
+				m.Day = offset + 1
+				return m, nil
+			}
+			offset -= n
+		}
+	}
+	return Date{}, ErrOutOfRange
+}
+
+// ToSolar 将农历日期转换为公历日期 (UTC 零点)
+func ToSolar(d Date) (time.Time, error) {
+	if d.Year < MinYear || d.Year > MaxYear {
+		return time.Time{}, ErrOutOfRange
+	}
+	n := DaysInMonth(d.Year, d.Month, d.Leap)
+	if n == 0 || d.Day < 1 || d.Day > n {
+		return time.Time{}, errors.New("农历日期不存在: " + d.String())
+	}
+	offset := 0
+	for year := MinYear; year < d.Year; year++ {
+		offset += daysInYear(year)
+	}
+	for _, m := range months(d.Year) {
+		if m.Month == d.Month && m.Leap == d.Leap {
+			break
+		}
+		offset += DaysInMonth(d.Year, m.Month, m.Leap)
+	}
+	return baseDate.AddDate(0, 0, offset+d.Day-1), nil
+}
+
+// MonthIndex 返回农历月份自 1900 年正月起的序号 (闰月单独计数)，用于按月推算
+func MonthIndex(year, month int, leap bool) int {
+	index := 0
+	for y := MinYear; y < year; y++ {
+		index += len(months(y))
+	}
+	for i, m := range months(year) {
+		if m.Month == month && m.Leap == leap {
+			return index + i
+		}
+	}
+	return -1
+}
+
+// MonthAt 返回序号对应的农历月份 (Day 为 1)，超出范围时第二个返回值为 false
+func MonthAt(index int) (Date, bool) {
+	if index < 0 {
+		return Date{}, false
+	}
+	for y := MinYear; y <= MaxYear; y++ {
+		ms := months(y)
+		if index < len(ms) {
+			return ms[index], true
+		}
+		index -= len(ms)
+	}
+	return Date{}, false
+}
@@ -0,0 +1,163 @@
+package lunar
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// goldenDates 公历与农历的对照，取自公开的万年历：春节、中秋、闰月初一以及年末的除夕
+var goldenDates = []struct {
+	solar string
+	lunar Date
+}{
+	// 数据表的起点
+	{"1900-01-31", Date{Year: 1900, Month: 1, Day: 1}},
+	// 春节
+	{"1949-01-29", Date{Year: 1949, Month: 1, Day: 1}},
+	{"2000-02-05", Date{Year: 2000, Month: 1, Day: 1}},
+	{"2001-01-24", Date{Year: 2001, Month: 1, Day: 1}},
+	{"2020-01-25", Date{Year: 2020, Month: 1, Day: 1}},
+	{"2024-02-10", Date{Year: 2024, Month: 1, Day: 1}},
+	{"2025-01-29", Date{Year: 2025, Month: 1, Day: 1}},
+	{"2026-02-17", Date{Year: 2026, Month: 1, Day: 1}},
+	// 除夕：腊月三十和腊月廿九 (2025-2029 年没有年三十)
+	{"2024-02-09", Date{Year: 2023, Month: 12, Day: 30}},
+	{"2025-01-28", Date{Year: 2024, Month: 12, Day: 29}},
+	{"2026-02-16", Date{Year: 2025, Month: 12, Day: 29}},
+	{"2030-02-02", Date{Year: 2029, Month: 12, Day: 30}},
+	// 中秋
+	{"2020-10-01", Date{Year: 2020, Month: 8, Day: 15}},
+	{"2024-09-17", Date{Year: 2024, Month: 8, Day: 15}},
+	{"2025-10-06", Date{Year: 2025, Month: 8, Day: 15}},
+	// 闰月
+	{"2001-05-23", Date{Year: 2001, Month: 4, Day: 1, Leap: true}},
+	{"2004-03-21", Date{Year: 2004, Month: 2, Day: 1, Leap: true}},
+	{"2014-10-24", Date{Year: 2014, Month: 9, Day: 1, Leap: true}},
+	{"2017-07-23", Date{Year: 2017, Month: 6, Day: 1, Leap: true}},
+	{"2020-05-23", Date{Year: 2020, Month: 4, Day: 1, Leap: true}},
+	{"2023-03-22", Date{Year: 2023, Month: 2, Day: 1, Leap: true}},
+	{"2023-04-19", Date{Year: 2023, Month: 2, Day: 29, Leap: true}},
+	{"2023-04-20", Date{Year: 2023, Month: 3, Day: 1}},
+	{"2025-07-25", Date{Year: 2025, Month: 6, Day: 1, Leap: true}},
+	// 数据表的终点
+	{"2100-12-31", Date{Year: 2100, Month: 12, Day: 1}},
+}
+
+func TestFromSolar(t *testing.T) {
+	for _, tt := range goldenDates {
+		solar, _ := time.Parse("2006-01-02", tt.solar)
+		got, err := FromSolar(solar)
+		if err != nil {
+			t.Errorf("FromSolar(%s) error: %v", tt.solar, err)
+			continue
+		}
+		if got != tt.lunar {
+			t.Errorf("FromSolar(%s) = %s, want %s", tt.solar, got, tt.lunar)
+		}
+	}
+}
+
+func TestFromSolarIgnoresClockAndZone(t *testing.T) {
+	// 北京时间 2024-02-10 00:30 在 UTC 中仍是 2 月 9 日，按给定时区的年月日转换
+	loc := time.FixedZone("CST", 8*3600)
+	got, err := FromSolar(time.Date(2024, time.February, 10, 0, 30, 0, 0, loc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (Date{Year: 2024, Month: 1, Day: 1}); got != want {
+		t.Errorf("FromSolar = %s, want %s", got, want)
+	}
+}
+
+func TestToSolar(t *testing.T) {
+	for _, tt := range goldenDates {
+		got, err := ToSolar(tt.lunar)
+		if err != nil {
+			t.Errorf("ToSolar(%s) error: %v", tt.lunar, err)
+			continue
+		}
+		if s := got.Format("2006-01-02"); s != tt.solar {
+			t.Errorf("ToSolar(%s) = %s, want %s", tt.lunar, s, tt.solar)
+		}
+	}
+}
+
+func TestToSolarInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		date Date
+	}{
+		{"当年没有该闰月", Date{Year: 2024, Month: 2, Day: 1, Leap: true}},
+		{"闰月的月份不对", Date{Year: 2023, Month: 3, Day: 1, Leap: true}},
+		{"小月没有三十", Date{Year: 2024, Month: 12, Day: 30}},
+		{"日期为零", Date{Year: 2024, Month: 1, Day: 0}},
+		{"月份超出范围", Date{Year: 2024, Month: 13, Day: 1}},
+	}
+	for _, tt := range tests {
+		if _, err := ToSolar(tt.date); err == nil {
+			t.Errorf("%s: ToSolar(%s) should fail", tt.name, tt.date)
+		}
+	}
+}
+
+func TestOutOfRange(t *testing.T) {
+	if _, err := FromSolar(time.Date(1900, time.January, 30, 0, 0, 0, 0, time.UTC)); !errors.Is(err, ErrOutOfRange) {
+		t.Errorf("FromSolar(1900-01-30) error = %v, want ErrOutOfRange", err)
+	}
+	if _, err := FromSolar(time.Date(2101, time.February, 1, 0, 0, 0, 0, time.UTC)); !errors.Is(err, ErrOutOfRange) {
+		t.Errorf("FromSolar(2101-02-01) error = %v, want ErrOutOfRange", err)
+	}
+	if _, err := ToSolar(Date{Year: MaxYear + 1, Month: 1, Day: 1}); !errors.Is(err, ErrOutOfRange) {
+		t.Errorf("ToSolar(%d-1-1) error = %v, want ErrOutOfRange", MaxYear+1, err)
+	}
+}
+
+func TestLeapMonthAndDays(t *testing.T) {
+	tests := []struct {
+		year, month int
+		leap        bool
+		leapMonth   int
+		days        int
+	}{
+		{2023, 2, true, 2, 29},
+		{2023, 2, false, 2, 30},
+		{2023, 12, false, 2, 30},
+		{2024, 12, false, 0, 29},
+		{2024, 6, true, 0, 0},
+		{2025, 6, true, 6, 29},
+		{2020, 4, true, 4, 29},
+	}
+	for _, tt := range tests {
+		if got := LeapMonth(tt.year); got != tt.leapMonth {
+			t.Errorf("LeapMonth(%d) = %d, want %d", tt.year, got, tt.leapMonth)
+		}
+		if got := DaysInMonth(tt.year, tt.month, tt.leap); got != tt.days {
+			t.Errorf("DaysInMonth(%d, %d, %v) = %d, want %d", tt.year, tt.month, tt.leap, got, tt.days)
+		}
+	}
+}
+
+func TestMonthIndexAcrossLeapAndYear(t *testing.T) {
+	// 2023 年二月之后是闰二月，再之后是三月；2023 年腊月之后是 2024 年正月
+	tests := []struct {
+		from  Date
+		steps int
+		want  Date
+	}{
+		{Date{Year: 2023, Month: 2, Day: 1}, 1, Date{Year: 2023, Month: 2, Day: 1, Leap: true}},
+		{Date{Year: 2023, Month: 2, Day: 1}, 2, Date{Year: 2023, Month: 3, Day: 1}},
+		{Date{Year: 2023, Month: 12, Day: 1}, 1, Date{Year: 2024, Month: 1, Day: 1}},
+		{Date{Year: 2023, Month: 1, Day: 1}, 13, Date{Year: 2024, Month: 1, Day: 1}},
+		{Date{Year: 2024, Month: 1, Day: 1}, 12, Date{Year: 2025, Month: 1, Day: 1}},
+	}
+	for _, tt := range tests {
+		got, ok := MonthAt(MonthIndex(tt.from.Year, tt.from.Month, tt.from.Leap) + tt.steps)
+		if !ok || got != tt.want {
+			t.Errorf("%s + %d months = %s, want %s", tt.from, tt.steps, got, tt.want)
+		}
+	}
+	if _, ok := MonthAt(-1); ok {
+		t.Error("MonthAt(-1) should be out of range")
+	}
+}
@@ -0,0 +1,125 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// MaxAutoArchiveDays 自动归档天数的上限
+const MaxAutoArchiveDays = 365
+
+// 自动归档每次查询的候选数量
+const autoArchiveBatchSize = 500
+
+var (
+	// ErrAlreadyArchived 待办事项已经归档
+	ErrAlreadyArchived = errors.New("待办事项已归档")
+	// ErrNotArchived 待办事项没有归档
+	ErrNotArchived = errors.New("待办事项未归档")
+)
+
+// ArchiveTodo 归档待办事项及其尚未归档的子孙任务并写入修订记录，应在事务中调用
+// 同一次归档的待办事项归档时间相同，取消归档时据此一并取消；返回被归档的待办事项ID
+func ArchiveTodo(tx *gorm.DB, actorID uint, todo *Todo, now time.Time) ([]uint, error) {
+	if todo.ArchivedAt != nil {
+		return nil, ErrAlreadyArchived
+	}
+	descendants, err := LoadDescendants(tx.Where("archived_at IS NULL"), todo.UserID, todo.ID)
+	if err != nil {
+		return nil, err
+	}
+	ids := append([]uint{todo.ID}, TodoIDs(descendants)...)
+	before, err := SnapshotTodos(tx, ids)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Model(&Todo{}).Where("id IN ?", ids).Update("archived_at", now).Error; err != nil {
+		return nil, err
+	}
+	todo.ArchivedAt = &now
+	return ids, RecordRevisions(tx, actorID, RevisionArchive, before)
+}
+
+// UnarchiveTodo 取消归档，与它同一次归档的子孙任务一并取消，应在事务中调用
+// 返回取消归档的待办事项ID
+func UnarchiveTodo(tx *gorm.DB, actorID uint, todo *Todo) ([]uint, error) {
+	if todo.ArchivedAt == nil {
+		return nil, ErrNotArchived
+	}
+	descendants, err := LoadDescendants(tx.Where("archived_at = ?", *todo.ArchivedAt), todo.UserID, todo.ID)
+	if err != nil {
+		return nil, err
+	}
+	ids := append([]uint{todo.ID}, TodoIDs(descendants)...)
+	before, err := SnapshotTodos(tx, ids)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Model(&Todo{}).Where("id IN ?", ids).Update("archived_at", nil).Error; err != nil {
+		return nil, err
+	}
+	todo.ArchivedAt = nil
+	return ids, RecordRevisions(tx, actorID, RevisionUnarchive, before)
+}
+
+// AutoArchive 按用户的自动归档设置，归档完成超过设定天数的顶层待办事项 (连同子任务)
+// 还有未完成子任务的不归档；旧数据没有完成时间时按最后修改时间计算
+// 返回每个用户被归档的待办事项ID，调用方据此清除缓存
+func AutoArchive(now time.Time) (map[uint][]uint, error) {
+	var users []User
+	if err := DB.Select("id", "auto_archive_days").Where("auto_archive_days > 0").Find(&users).Error; err != nil {
+		return nil, err
+	}
+	archived := map[uint][]uint{}
+	for i := range users {
+		ids, err := autoArchiveUser(&users[i], now)
+		if len(ids) > 0 {
+			archived[users[i].ID] = ids
+		}
+		if err != nil {
+			return archived, err
+		}
+	}
+	return archived, nil
+}
+
+// autoArchiveUser 归档单个用户满足条件的待办事项
+func autoArchiveUser(user *User, now time.Time) ([]uint, error) {
+	cutoff := now.AddDate(0, 0, -user.AutoArchiveDays)
+	var archived []uint
+	var lastID uint
+	for {
+		var roots []Todo
+		err := DB.Where("user_id = ? AND parent_id IS NULL AND completed = ? AND archived_at IS NULL", user.ID, true).
+			Where("COALESCE(completed_at, updated_at) < ? AND id > ?", cutoff, lastID).
+			Order("id ASC").Limit(autoArchiveBatchSize).Find(&roots).Error
+		if err != nil {
+			return archived, err
+		}
+		for i := range roots {
+			err := DB.Transaction(func(tx *gorm.DB) error {
+				descendants, err := LoadDescendants(tx.Where("archived_at IS NULL"), user.ID, roots[i].ID)
+				if err != nil {
+					return err
+				}
+				for _, d := range descendants {
+					if !d.Completed {
+						return nil
+					}
+				}
+				ids, err := ArchiveTodo(tx, SystemActor, &roots[i], now)
+				archived = append(archived, ids...)
+				return err
+			})
+			if err != nil {
+				return archived, err
+			}
+		}
+		if len(roots) < autoArchiveBatchSize {
+			return archived, nil
+		}
+		lastID = roots[len(roots)-1].ID
+	}
+}
@@ -0,0 +1,136 @@
+package models
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+	"todolist/storage"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// DefaultAttachmentMaxMB 未配置 ATTACHMENT_MAX_MB 时单个附件的大小上限 (MB)
+const DefaultAttachmentMaxMB = 10
+
+// 清理孤立内容时每批处理的数量
+const blobCleanupBatchSize = 500
+
+// AttachmentTypes 允许上传的附件类型，按文件内容识别，不信任客户端声明的类型
+var AttachmentTypes = map[string]bool{
+	"image/png":       true,
+	"image/jpeg":      true,
+	"image/gif":       true,
+	"image/webp":      true,
+	"application/pdf": true,
+	"text/plain":      true,
+}
+
+// Blobs 全局附件内容存储
+var Blobs storage.BlobStore
+
+// Blob 登记已保存的附件内容，按 SHA-256 摘要去重，多个附件可以引用同一内容
+// 没有附件引用的内容由后台任务清理
+type Blob struct {
+	Hash        string    `json:"sha256" gorm:"type:char(64);primaryKey"`
+	Size        int64     `json:"size" gorm:"not null"`
+	ContentType string    `json:"content_type" gorm:"type:varchar(127);not null"`
+	CreatedAt   time.Time `json:"created_at"`
+	// 最近一次上传该内容的时间，清理时跳过最近上传的，避免与正在进行的上传冲突
+	UpdatedAt time.Time `json:"updated_at" gorm:"index"`
+}
+
+// Attachment 待办事项的附件，内容保存在 Blobs 中
+// 待办事项移入回收站时附件保留，永久删除时附件记录一并删除，内容随后被清理
+type Attachment struct {
+	ID          uint      `json:"id" gorm:"primaryKey"`
+	TodoID      uint      `json:"todo_id" gorm:"not null;index"`
+	UserID      uint      `json:"user_id" gorm:"not null"` // 上传者
+	Filename    string    `json:"filename" gorm:"type:varchar(255);not null"`
+	ContentType string    `json:"content_type" gorm:"type:varchar(127);not null"`
+	Size        int64     `json:"size" gorm:"not null"`
+	Hash        string    `json:"sha256" gorm:"type:char(64);not null;index"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// InitStorage 按环境变量初始化附件存储
+func InitStorage() error {
+	var err error
+	if Blobs, err = storage.Open(); err != nil {
+		return fmt.Errorf("附件存储初始化失败: %w", err)
+	}
+	return nil
+}
+
+// AttachmentMaxBytes 单个附件的大小上限，由 ATTACHMENT_MAX_MB 配置
+func AttachmentMaxBytes() int64 {
+	raw := getEnvOrDefault("ATTACHMENT_MAX_MB", strconv.Itoa(DefaultAttachmentMaxMB))
+	mb, err := strconv.Atoi(raw)
+	if err != nil || mb <= 0 {
+		fmt.Printf("ATTACHMENT_MAX_MB 无效: %q，使用默认值 %d\n", raw, DefaultAttachmentMaxMB)
+		mb = DefaultAttachmentMaxMB
+	}
+	return int64(mb) << 20
+}
+
+// TouchBlob 登记内容并刷新其上传时间，应在写入存储之前调用，使清理任务跳过这份内容
+// 登记需要该行的锁：清理任务正在删除这份内容时会等待删除完成，之后重新登记，调用方会发现内容不存在并重新写入
+func TouchBlob(hash string, size int64, contentType string) error {
+	blob := Blob{Hash: hash, Size: size, ContentType: contentType}
+	return DB.Clauses(clause.OnConflict{DoUpdates: clause.AssignmentColumns([]string{"updated_at"})}).Create(&blob).Error
+}
+
+// orphanBlob 没有附件引用、且在给定时间之前上传的内容
+const orphanBlob = "updated_at < ? AND NOT EXISTS (SELECT 1 FROM attachments WHERE attachments.hash = blobs.hash)"
+
+// CleanupOrphanBlobs 删除没有附件引用、且在 before 之前上传的内容，返回删除的数量
+func CleanupOrphanBlobs(before time.Time) (int, error) {
+	total := 0
+	lastHash := ""
+	for {
+		var hashes []string
+		err := DB.Model(&Blob{}).Where(orphanBlob, before).Where("hash > ?", lastHash).
+			Order("hash ASC").Limit(blobCleanupBatchSize).Pluck("hash", &hashes).Error
+		if err != nil {
+			return total, err
+		}
+		for _, hash := range hashes {
+			deleted, err := deleteOrphanBlob(hash, before)
+			if err != nil {
+				return total, err
+			}
+			if deleted {
+				total++
+			}
+		}
+		if len(hashes) < blobCleanupBatchSize {
+			return total, nil
+		}
+		lastHash = hashes[len(hashes)-1]
+	}
+}
+
+// deleteOrphanBlob 锁定登记并再次检查后删除内容及其登记，期间被重新上传或引用的内容保留
+// 存储中的内容在持有行锁时删除，并发的上传 (TouchBlob) 会等待事务结束，不会在内容被删除前认为它已存在
+func deleteOrphanBlob(hash string, before time.Time) (bool, error) {
+	deleted := false
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		var blobs []Blob
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("hash = ?", hash).Where(orphanBlob, before).Find(&blobs).Error; err != nil {
+			return err
+		}
+		if len(blobs) == 0 {
+			return nil
+		}
+		if err := tx.Where("hash = ?", hash).Delete(&Blob{}).Error; err != nil {
+			return err
+		}
+		// 删除失败时回滚，登记保留，下一轮清理重试
+		if err := Blobs.Delete(Ctx, hash); err != nil {
+			return err
+		}
+		deleted = true
+		return nil
+	})
+	return deleted, err
+}
@@ -0,0 +1,61 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"todolist/calendar"
+
+	"gorm.io/gorm"
+)
+
+// HolidayCalendar 管理员上传的某一年节假日安排，优先于内置数据
+type HolidayCalendar struct {
+	Year      int       `json:"year" gorm:"primaryKey;autoIncrement:false"`
+	Data      string    `json:"-" gorm:"type:mediumtext;not null"` // calendar.Year 的 JSON
+	UpdatedBy string    `json:"updated_by" gorm:"type:varchar(255)"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// LoadHolidayCalendars 将数据库中保存的节假日安排加载到全局工作日历
+func LoadHolidayCalendars() error {
+	var rows []HolidayCalendar
+	if err := DB.Order("year ASC").Find(&rows).Error; err != nil {
+		return err
+	}
+	for _, row := range rows {
+		y, err := calendar.ParseJSON([]byte(row.Data))
+		if err != nil {
+			// 单年数据损坏不影响其他年份，继续使用内置数据
+			fmt.Printf("节假日数据 %d 年无效，已忽略: %v\n", row.Year, err)
+			continue
+		}
+		calendar.Default().Load(y)
+	}
+	return nil
+}
+
+// SaveHolidayCalendars 在事务中保存多年的节假日安排 (已有的年份整年覆盖)，成功后加载到全局工作日历
+func SaveHolidayCalendars(years []*calendar.Year, username string) error {
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		for _, y := range years {
+			data, err := json.Marshal(y)
+			if err != nil {
+				return err
+			}
+			row := HolidayCalendar{Year: y.Year, Data: string(data), UpdatedBy: username}
+			if err := tx.Save(&row).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, y := range years {
+		calendar.Default().Load(y)
+	}
+	return nil
+}
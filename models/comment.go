@@ -0,0 +1,26 @@
+package models
+
+import (
+	"time"
+)
+
+// Comment 待办事项下的一条评论
+// 待办事项移入回收站时评论保留，永久删除时一并删除
+type Comment struct {
+	ID     uint   `json:"id" gorm:"primaryKey"`
+	TodoID uint   `json:"todo_id" gorm:"not null;index"`
+	UserID uint   `json:"user_id" gorm:"not null;index"` // 评论作者
+	Body   string `json:"body" gorm:"type:text;not null"`
+	// 最后一次编辑的时间和编辑次数，从未编辑过时为空和 0
+	EditedAt  *time.Time `json:"edited_at"`
+	EditCount int        `json:"edit_count" gorm:"not null;default:0"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+// CountComments 返回待办事项的评论数
+func CountComments(todoID uint) (int64, error) {
+	var count int64
+	err := DB.Model(&Comment{}).Where("todo_id = ?", todoID).Count(&count).Error
+	return count, err
+}
@@ -0,0 +1,372 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 自定义字段类型
+const (
+	FieldTypeText        = "text"
+	FieldTypeNumber      = "number"
+	FieldTypeDate        = "date"
+	FieldTypeSelect      = "select"
+	FieldTypeMultiSelect = "multi_select"
+)
+
+const (
+	// 每个用户在每个工作区中最多定义的自定义字段数
+	MaxCustomFields = 50
+	// 单选/多选字段最多的选项数
+	MaxCustomFieldOptions = 100
+	// 字段名、选项和文本值的最大字符数
+	maxCustomFieldNameRunes = 64
+	maxCustomTextRunes      = 255
+)
+
+// CustomField 用户在某个工作区中定义的待办事项字段，新增字段只增加一行记录，不需要修改表结构
+type CustomField struct {
+	ID     uint   `json:"id" gorm:"primaryKey"`
+	UserID uint   `json:"-" gorm:"not null;uniqueIndex:idx_custom_fields_owner_name,priority:1"`
+	Name   string `json:"name" gorm:"type:varchar(64);not null;uniqueIndex:idx_custom_fields_owner_name,priority:3"`
+	// 类型创建后不能修改，见 FieldType* 常量
+	Type string `json:"type" gorm:"type:varchar(16);not null"`
+	// 单选/多选字段的可选值，按显示顺序
+	Options   []string  `json:"options,omitempty" gorm:"type:json;serializer:json"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	// 所属工作区，字段只能用于同一工作区中的待办事项
+	WorkspaceID uint `json:"workspace_id" gorm:"not null;default:0;uniqueIndex:idx_custom_fields_owner_name,priority:2"`
+}
+
+// CustomFieldValue 待办事项上一个自定义字段的值，按字段类型只使用其中一列
+// 文本和单选使用 TextValue，多选使用 MultiValue；各类型分列存储以便在数据库中筛选和排序
+type CustomFieldValue struct {
+	TodoID      uint       `gorm:"primaryKey"`
+	FieldID     uint       `gorm:"primaryKey;index:idx_custom_values_text,priority:1;index:idx_custom_values_number,priority:1;index:idx_custom_values_date,priority:1"`
+	TextValue   *string    `gorm:"type:varchar(255);index:idx_custom_values_text,priority:2"`
+	NumberValue *float64   `gorm:"index:idx_custom_values_number,priority:2"`
+	DateValue   *time.Time `gorm:"type:date;index:idx_custom_values_date,priority:2"`
+	MultiValue  []string   `gorm:"type:json;serializer:json"`
+}
+
+// ValidCustomFieldType 是否为支持的字段类型
+func ValidCustomFieldType(t string) bool {
+	switch t {
+	case FieldTypeText, FieldTypeNumber, FieldTypeDate, FieldTypeSelect, FieldTypeMultiSelect:
+		return true
+	}
+	return false
+}
+
+// HasOptions 字段是否为单选或多选
+func (f *CustomField) HasOptions() bool {
+	return f.Type == FieldTypeSelect || f.Type == FieldTypeMultiSelect
+}
+
+// Normalize 整理并校验字段名和选项，类型必须已设置
+func (f *CustomField) Normalize() error {
+	f.Name = strings.TrimSpace(f.Name)
+	if f.Name == "" || utf8.RuneCountInString(f.Name) > maxCustomFieldNameRunes {
+		return fmt.Errorf("字段名不能为空且不能超过 %d 个字符", maxCustomFieldNameRunes)
+	}
+	if !ValidCustomFieldType(f.Type) {
+		return errors.New("type 必须为 text、number、date、select 或 multi_select")
+	}
+	if !f.HasOptions() {
+		if len(f.Options) > 0 {
+			return errors.New("只有单选和多选字段可以设置选项")
+		}
+		f.Options = nil
+		return nil
+	}
+	if len(f.Options) == 0 || len(f.Options) > MaxCustomFieldOptions {
+		return fmt.Errorf("选项数必须为 1 到 %d 个", MaxCustomFieldOptions)
+	}
+	seen := make(map[string]bool, len(f.Options))
+	for i, o := range f.Options {
+		o = strings.TrimSpace(o)
+		if o == "" || utf8.RuneCountInString(o) > maxCustomTextRunes {
+			return fmt.Errorf("选项不能为空且不能超过 %d 个字符", maxCustomTextRunes)
+		}
+		if seen[o] {
+			return fmt.Errorf("重复的选项: %s", o)
+		}
+		seen[o] = true
+		f.Options[i] = o
+	}
+	return nil
+}
+
+// hasOption 选项是否存在
+func (f *CustomField) hasOption(o string) bool {
+	for _, opt := range f.Options {
+		if opt == o {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseValue 按字段定义解析并校验 JSON 值，null 或空值返回 nil (表示清除)
+func (f *CustomField) ParseValue(raw json.RawMessage) (*CustomFieldValue, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil, nil
+	}
+	v := &CustomFieldValue{FieldID: f.ID}
+	invalid := func(format string) error { return fmt.Errorf("自定义字段 %s 必须为%s", f.Name, format) }
+	switch f.Type {
+	case FieldTypeNumber:
+		var n float64
+		if err := json.Unmarshal(raw, &n); err != nil || math.IsInf(n, 0) || math.IsNaN(n) {
+			return nil, invalid("数字")
+		}
+		v.NumberValue = &n
+	case FieldTypeMultiSelect:
+		var values []string
+		if err := json.Unmarshal(raw, &values); err != nil {
+			return nil, invalid("字符串数组")
+		}
+		selected := make(map[string]bool, len(values))
+		for _, s := range values {
+			if !f.hasOption(s) {
+				return nil, fmt.Errorf("自定义字段 %s 没有选项: %s", f.Name, s)
+			}
+			selected[s] = true
+		}
+		if len(selected) == 0 {
+			return nil, nil
+		}
+		// 按选项顺序保存，去掉重复
+		for _, o := range f.Options {
+			if selected[o] {
+				v.MultiValue = append(v.MultiValue, o)
+			}
+		}
+	default:
+		var s string
+		if err := json.Unmarshal(raw, &s); err != nil {
+			return nil, invalid("字符串")
+		}
+		if s == "" {
+			return nil, nil
+		}
+		switch f.Type {
+		case FieldTypeText:
+			if utf8.RuneCountInString(s) > maxCustomTextRunes {
+				return nil, fmt.Errorf("自定义字段 %s 不能超过 %d 个字符", f.Name, maxCustomTextRunes)
+			}
+		case FieldTypeSelect:
+			if !f.hasOption(s) {
+				return nil, fmt.Errorf("自定义字段 %s 没有选项: %s", f.Name, s)
+			}
+		case FieldTypeDate:
+			d, err := time.ParseInLocation("2006-01-02", s, time.Local)
+			if err != nil {
+				return nil, invalid(" YYYY-MM-DD 日期")
+			}
+			v.DateValue = &d
+			return v, nil
+		}
+		v.TextValue = &s
+	}
+	return v, nil
+}
+
+// ParseQueryValue 解析查询参数中的值，多选字段为其中一个选项
+func (f *CustomField) ParseQueryValue(s string) (*CustomFieldValue, error) {
+	var raw []byte
+	switch f.Type {
+	case FieldTypeNumber:
+		if _, err := strconv.ParseFloat(s, 64); err != nil {
+			return nil, fmt.Errorf("自定义字段 %s 必须为数字", f.Name)
+		}
+		raw = []byte(s)
+	case FieldTypeMultiSelect:
+		raw, _ = json.Marshal([]string{s})
+	default:
+		raw, _ = json.Marshal(s)
+	}
+	v, err := f.ParseValue(raw)
+	if err == nil && v == nil {
+		err = fmt.Errorf("自定义字段 %s 的筛选值不能为空", f.Name)
+	}
+	return v, err
+}
+
+// Value 返回值的 JSON 表示：文本/单选为字符串，数字为数值，日期为 YYYY-MM-DD，多选为字符串数组
+func (v *CustomFieldValue) Value() interface{} {
+	switch {
+	case v.NumberValue != nil:
+		return *v.NumberValue
+	case v.DateValue != nil:
+		return v.DateValue.Format("2006-01-02")
+	case v.MultiValue != nil:
+		return v.MultiValue
+	case v.TextValue != nil:
+		return *v.TextValue
+	}
+	return nil
+}
+
+// String 值的文本表示，见 FormatCustomValue
+func (v *CustomFieldValue) String() string {
+	return FormatCustomValue(v.Value())
+}
+
+// FormatCustomValue 将 Value 返回的值 (或其 JSON 反序列化结果) 转为文本，用于导出，多选的选项以 "; " 分隔
+func FormatCustomValue(v interface{}) string {
+	switch val := v.(type) {
+	case float64:
+		return strconv.FormatFloat(val, 'f', -1, 64)
+	case []string:
+		return strings.Join(val, "; ")
+	case []interface{}:
+		parts := make([]string, len(val))
+		for i, p := range val {
+			parts[i], _ = p.(string)
+		}
+		return strings.Join(parts, "; ")
+	case string:
+		return val
+	}
+	return ""
+}
+
+// LoadCustomFields 按创建顺序加载用户在工作区中的自定义字段
+func LoadCustomFields(db *gorm.DB, userID, workspaceID uint) ([]CustomField, error) {
+	var fields []CustomField
+	err := db.Where("user_id = ? AND workspace_id = ?", userID, workspaceID).Order("id ASC").Find(&fields).Error
+	return fields, err
+}
+
+// ParseCustomValues 解析请求中的自定义字段值，键为字段ID
+// 返回值中为 nil 的表示清除该字段；字段必须属于该用户且在同一工作区
+func ParseCustomValues(db *gorm.DB, userID, workspaceID uint, raw map[string]json.RawMessage) (map[uint]*CustomFieldValue, error) {
+	if len(raw) == 0 {
+		return nil, nil
+	}
+	fields, err := LoadCustomFields(db, userID, workspaceID)
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[uint]*CustomField, len(fields))
+	for i := range fields {
+		byID[fields[i].ID] = &fields[i]
+	}
+	values := make(map[uint]*CustomFieldValue, len(raw))
+	for key, r := range raw {
+		id, err := strconv.ParseUint(key, 10, 64)
+		f := byID[uint(id)]
+		if err != nil || f == nil {
+			return nil, fmt.Errorf("自定义字段不存在: %s", key)
+		}
+		if values[f.ID], err = f.ParseValue(r); err != nil {
+			return nil, err
+		}
+	}
+	return values, nil
+}
+
+// SetCustomValues 写入待办事项的自定义字段值，nil 表示清除，未出现的字段不变
+func SetCustomValues(tx *gorm.DB, todoID uint, values map[uint]*CustomFieldValue) error {
+	var cleared []uint
+	var rows []CustomFieldValue
+	for fieldID, v := range values {
+		if v == nil {
+			cleared = append(cleared, fieldID)
+			continue
+		}
+		row := *v
+		row.TodoID, row.FieldID = todoID, fieldID
+		rows = append(rows, row)
+	}
+	if len(cleared) > 0 {
+		if err := tx.Where("todo_id = ? AND field_id IN ?", todoID, cleared).Delete(&CustomFieldValue{}).Error; err != nil {
+			return err
+		}
+	}
+	if len(rows) == 0 {
+		return nil
+	}
+	return tx.Clauses(clause.OnConflict{UpdateAll: true}).Create(&rows).Error
+}
+
+// CopyCustomValues 将一个待办事项的自定义字段值复制到另一个，用于重复待办生成下一个实例
+func CopyCustomValues(tx *gorm.DB, fromID, toID uint) error {
+	var values []CustomFieldValue
+	if err := tx.Where("todo_id = ?", fromID).Find(&values).Error; err != nil || len(values) == 0 {
+		return err
+	}
+	for i := range values {
+		values[i].TodoID = toID
+	}
+	return tx.Create(&values).Error
+}
+
+// FillCustomFields 为一批待办事项填充 CustomFields (键为字段ID)
+func FillCustomFields(db *gorm.DB, todos []Todo) error {
+	if len(todos) == 0 {
+		return nil
+	}
+	var values []CustomFieldValue
+	if err := db.Where("todo_id IN ?", TodoIDs(todos)).Find(&values).Error; err != nil {
+		return err
+	}
+	byTodo := make(map[uint]map[string]interface{})
+	for i := range values {
+		v := &values[i]
+		if byTodo[v.TodoID] == nil {
+			byTodo[v.TodoID] = map[string]interface{}{}
+		}
+		byTodo[v.TodoID][strconv.FormatUint(uint64(v.FieldID), 10)] = v.Value()
+	}
+	for i := range todos {
+		todos[i].CustomFields = byTodo[todos[i].ID]
+	}
+	return nil
+}
+
+// CustomOptionsInUse 返回从字段中移除后仍被待办事项使用的选项
+func CustomOptionsInUse(db *gorm.DB, field *CustomField, removed []string) ([]string, error) {
+	var inUse []string
+	for _, o := range removed {
+		q := db.Model(&CustomFieldValue{}).Where("field_id = ?", field.ID)
+		if field.Type == FieldTypeMultiSelect {
+			q = q.Where("JSON_CONTAINS(multi_value, JSON_QUOTE(?))", o)
+		} else {
+			q = q.Where("text_value = ?", o)
+		}
+		var count int64
+		if err := q.Count(&count).Error; err != nil {
+			return nil, err
+		}
+		if count > 0 {
+			inUse = append(inUse, o)
+		}
+	}
+	sort.Strings(inUse)
+	return inUse, nil
+}
+
+// DeleteCustomField 删除自定义字段及其所有值，返回有值的待办事项ID以便清除缓存
+func DeleteCustomField(tx *gorm.DB, field *CustomField) ([]uint, error) {
+	var todoIDs []uint
+	if err := tx.Model(&CustomFieldValue{}).Where("field_id = ?", field.ID).Pluck("todo_id", &todoIDs).Error; err != nil {
+		return nil, err
+	}
+	if err := tx.Where("field_id = ?", field.ID).Delete(&CustomFieldValue{}).Error; err != nil {
+		return nil, err
+	}
+	return todoIDs, tx.Delete(field).Error
+}
@@ -0,0 +1,130 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrDependencyCycle 添加依赖后会形成循环 (包括依赖自身)
+var ErrDependencyCycle = errors.New("不能形成循环依赖")
+
+// TodoDependency 待办事项之间的依赖：TodoID 被 BlockerID 阻塞，前置任务完成后才能开始
+// 回收站中的前置任务不再阻塞；永久删除时依赖一并删除
+type TodoDependency struct {
+	TodoID    uint      `json:"todo_id" gorm:"primaryKey"`
+	BlockerID uint      `json:"blocker_id" gorm:"primaryKey;index"`
+	UserID    uint      `json:"-" gorm:"not null;index"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// openBlockerJoin 连接依赖表和前置任务，只保留还未完成且不在回收站中的前置任务
+const openBlockerJoin = "JOIN todos blockers ON blockers.id = todo_dependencies.blocker_id AND blockers.completed = false AND blockers.deleted_at IS NULL"
+
+// AddDependencies 为待办事项添加前置任务，已存在的依赖忽略，应在事务中调用
+// 调用方需保证前置任务属于同一用户；任一依赖会形成循环时返回 ErrDependencyCycle
+func AddDependencies(tx *gorm.DB, userID, todoID uint, blockerIDs []uint) error {
+	// 同一用户的依赖修改串行执行，并发添加不会绕过循环检测
+	if err := lockUser(tx, userID); err != nil {
+		return err
+	}
+	deps := make([]TodoDependency, 0, len(blockerIDs))
+	for _, blockerID := range blockerIDs {
+		cycle, err := blockedBy(tx, blockerID, todoID)
+		if err != nil {
+			return err
+		}
+		if cycle {
+			return ErrDependencyCycle
+		}
+		deps = append(deps, TodoDependency{TodoID: todoID, BlockerID: blockerID, UserID: userID})
+	}
+	if len(deps) == 0 {
+		return nil
+	}
+	return tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&deps).Error
+}
+
+// blockedBy 判断 id 是否直接或间接被 target 阻塞 (id 等于 target 时也视为是)
+// 回收站中的待办事项也参与检查，避免恢复后出现循环
+func blockedBy(db *gorm.DB, id, target uint) (bool, error) {
+	visited := map[uint]bool{id: true}
+	frontier := []uint{id}
+	for len(frontier) > 0 {
+		if visited[target] {
+			return true, nil
+		}
+		var next []uint
+		if err := db.Model(&TodoDependency{}).Where("todo_id IN ?", frontier).Pluck("blocker_id", &next).Error; err != nil {
+			return false, err
+		}
+		frontier = frontier[:0]
+		for _, b := range next {
+			if !visited[b] {
+				visited[b] = true
+				frontier = append(frontier, b)
+			}
+		}
+	}
+	return visited[target], nil
+}
+
+// OpenBlockers 返回阻塞 ids 中任一待办事项、且还未完成的前置任务ID
+// ids 内部的依赖不算 (如与父任务一并完成的子任务)
+func OpenBlockers(db *gorm.DB, ids []uint) ([]uint, error) {
+	var blockers []uint
+	if len(ids) == 0 {
+		return blockers, nil
+	}
+	err := db.Model(&TodoDependency{}).Joins(openBlockerJoin).
+		Where("todo_dependencies.todo_id IN ? AND todo_dependencies.blocker_id NOT IN ?", ids, ids).
+		Distinct().Order("todo_dependencies.blocker_id ASC").Pluck("todo_dependencies.blocker_id", &blockers).Error
+	return blockers, err
+}
+
+// DependentIDs 返回被 blockerIDs 中任一待办事项直接阻塞的待办事项ID
+func DependentIDs(db *gorm.DB, blockerIDs []uint) ([]uint, error) {
+	var ids []uint
+	if len(blockerIDs) == 0 {
+		return ids, nil
+	}
+	err := db.Model(&TodoDependency{}).Where("blocker_id IN ?", blockerIDs).
+		Distinct().Pluck("todo_id", &ids).Error
+	return ids, err
+}
+
+// FillDependencyFlags 为待办事项填充 Blocked 和 Actionable
+func FillDependencyFlags(db *gorm.DB, todos []Todo) error {
+	if len(todos) == 0 {
+		return nil
+	}
+	var blockedIDs []uint
+	err := db.Model(&TodoDependency{}).Joins(openBlockerJoin).
+		Where("todo_dependencies.todo_id IN ?", TodoIDs(todos)).
+		Distinct().Pluck("todo_dependencies.todo_id", &blockedIDs).Error
+	if err != nil {
+		return err
+	}
+	blocked := make(map[uint]bool, len(blockedIDs))
+	for _, id := range blockedIDs {
+		blocked[id] = true
+	}
+	for i := range todos {
+		b := blocked[todos[i].ID]
+		a := !todos[i].Completed && !b
+		todos[i].Blocked, todos[i].Actionable = &b, &a
+	}
+	return nil
+}
+
+// ActionableScope 筛选可以开始 (未完成且没有未完成的前置任务) 或不可以开始的待办事项
+func ActionableScope(db *gorm.DB, actionable bool) *gorm.DB {
+	blocked := DB.Model(&TodoDependency{}).Select("1").Joins(openBlockerJoin).
+		Where("todo_dependencies.todo_id = todos.id")
+	if actionable {
+		return db.Where("todos.completed = ? AND NOT EXISTS (?)", false, blocked)
+	}
+	return db.Where("(todos.completed = ? OR EXISTS (?))", true, blocked)
+}
@@ -0,0 +1,100 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 预估报表的分组方式
+const (
+	EstimateByProject = "project"
+	EstimateByTag     = "tag"
+	EstimateByWeek    = "week"
+)
+
+// EstimateReportQuery 预估报表的查询条件
+type EstimateReportQuery struct {
+	UserID  uint
+	GroupBy string
+	// 只统计该工作区中的待办事项
+	WorkspaceID uint
+	// 只统计在 [From, To) 内完成的待办事项，都为空时统计全部 (包括未完成的)
+	From, To *time.Time
+	// 按周分组时每周的开始时间 (用户时区的周一零点)，升序，最后一周到 To 结束
+	Weeks []time.Time
+	// 正在运行的计时统计到 Now
+	Now time.Time
+}
+
+// EstimateGroup 预估报表中的一组
+type EstimateGroup struct {
+	// 分组键：项目ID / 标签ID (为 0 表示没有项目 / 没有标签)，或按周分组时周的序号
+	GroupKey uint `json:"-"`
+	// 待办事项数，其中有预估的数量
+	TodoCount      int64 `json:"todo_count"`
+	EstimatedCount int64 `json:"estimated_count"`
+	// 预估总分钟数
+	EstimateMinutes int64 `json:"estimate_minutes"`
+	// 记录的总时长 (秒)，以及其中有预估的待办事项记录的时长，用于与预估对比
+	LoggedSeconds          int64 `json:"logged_seconds"`
+	EstimatedLoggedSeconds int64 `json:"estimated_logged_seconds"`
+}
+
+// EstimateReport 按项目、标签或周汇总预估耗时和记录的时长，全部在数据库中聚合
+// 按标签分组时有多个标签的待办事项计入每个标签；按周分组时按完成时间所在的周统计
+func EstimateReport(db *gorm.DB, q EstimateReportQuery) ([]EstimateGroup, error) {
+	// 每个待办事项记录的时长
+	logged := db.Model(&TimeEntry{}).
+		Select("todo_id, SUM(TIMESTAMPDIFF(SECOND, started_at, COALESCE(ended_at, ?))) AS seconds", q.Now).
+		Where("todo_id IN (?)", db.Model(&Todo{}).Select("id").Where("user_id = ? AND workspace_id = ?", q.UserID, q.WorkspaceID)).
+		Group("todo_id")
+
+	query := db.Table("todos AS t").
+		Joins("LEFT JOIN (?) AS l ON l.todo_id = t.id", logged).
+		Where("t.user_id = ? AND t.workspace_id = ? AND t.deleted_at IS NULL", q.UserID, q.WorkspaceID)
+	if q.From != nil {
+		query = query.Where("t.completed_at >= ?", *q.From)
+	}
+	if q.To != nil {
+		query = query.Where("t.completed_at < ?", *q.To)
+	}
+
+	var key string
+	var keyArgs []interface{}
+	switch q.GroupBy {
+	case EstimateByProject:
+		key = "COALESCE(t.project_id, 0)"
+	case EstimateByTag:
+		query = query.Joins("LEFT JOIN todo_tags AS tt ON tt.todo_id = t.id")
+		key = "COALESCE(tt.tag_id, 0)"
+	case EstimateByWeek:
+		if len(q.Weeks) == 0 {
+			return nil, errors.New("按周分组时必须指定周")
+		}
+		// 完成时间落在第 i 周时分组键为 i，范围外的已由 From/To 排除
+		var b strings.Builder
+		b.WriteString("CASE")
+		for i := len(q.Weeks) - 1; i >= 0; i-- {
+			fmt.Fprintf(&b, " WHEN t.completed_at >= ? THEN %d", i)
+			keyArgs = append(keyArgs, q.Weeks[i])
+		}
+		b.WriteString(" ELSE 0 END")
+		key = b.String()
+	default:
+		return nil, fmt.Errorf("不支持的分组方式: %s", q.GroupBy)
+	}
+
+	var groups []EstimateGroup
+	err := query.Select(key+` AS group_key,
+		COUNT(*) AS todo_count,
+		COUNT(t.estimate_minutes) AS estimated_count,
+		COALESCE(SUM(t.estimate_minutes), 0) AS estimate_minutes,
+		COALESCE(SUM(l.seconds), 0) AS logged_seconds,
+		COALESCE(SUM(CASE WHEN t.estimate_minutes IS NOT NULL THEN l.seconds END), 0) AS estimated_logged_seconds`, keyArgs...).
+		Group("group_key").Order("group_key").Scan(&groups).Error
+	return groups, err
+}
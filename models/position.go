@@ -0,0 +1,241 @@
+package models
+
+import (
+	"todolist/position"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// RebalanceKeyLength 排序键超过该长度时，后台任务会重新分配所在清单的全部排序键
+	RebalanceKeyLength = 16
+	// maxKeyLength 移动后新键超过该长度时立即重新分配，保证不超出列宽
+	maxKeyLength = 64
+)
+
+// TodoList 手动排序的范围：同一用户、同一工作区下 project_id 和 parent_id 都相同的待办事项属于同一清单
+type TodoList struct {
+	UserID      uint
+	WorkspaceID uint
+	ProjectID   *uint
+	ParentID    *uint
+}
+
+// ListOf 返回待办事项所在的清单
+func ListOf(t *Todo) TodoList {
+	return TodoList{UserID: t.UserID, WorkspaceID: t.WorkspaceID, ProjectID: t.ProjectID, ParentID: t.ParentID}
+}
+
+// Scope 将查询限定在清单内
+func (l TodoList) Scope(db *gorm.DB) *gorm.DB {
+	db = db.Where("user_id = ? AND workspace_id = ?", l.UserID, l.WorkspaceID)
+	if l.ProjectID == nil {
+		db = db.Where("project_id IS NULL")
+	} else {
+		db = db.Where("project_id = ?", *l.ProjectID)
+	}
+	if l.ParentID == nil {
+		return db.Where("parent_id IS NULL")
+	}
+	return db.Where("parent_id = ?", *l.ParentID)
+}
+
+// key 清单的可比较表示，用于在 map 中分组
+func (l TodoList) key() [4]uint {
+	k := [4]uint{l.UserID, l.WorkspaceID}
+	if l.ProjectID != nil {
+		k[2] = *l.ProjectID
+	}
+	if l.ParentID != nil {
+		k[3] = *l.ParentID
+	}
+	return k
+}
+
+// lastPosition 返回清单中最大的排序键，清单为空时返回空字符串
+func lastPosition(tx *gorm.DB, list TodoList) (string, error) {
+	var last []string
+	err := list.Scope(tx.Model(&Todo{})).Where("position <> ''").
+		Order("position DESC").Limit(1).Pluck("position", &last).Error
+	if err != nil || len(last) == 0 {
+		return "", err
+	}
+	return last[0], nil
+}
+
+// NextPosition 返回排在清单末尾的新排序键
+func NextPosition(tx *gorm.DB, list TodoList) (string, error) {
+	last, err := lastPosition(tx, list)
+	if err != nil {
+		return "", err
+	}
+	return position.Between(last, "")
+}
+
+// AssignPositions 为还没有排序键的新待办事项分配键，依次追加到各自清单的末尾，应在创建前调用
+func AssignPositions(tx *gorm.DB, todos []Todo) error {
+	last := map[[4]uint]string{}
+	for i := range todos {
+		t := &todos[i]
+		if t.Position != "" {
+			continue
+		}
+		list := ListOf(t)
+		prev, ok := last[list.key()]
+		if !ok {
+			var err error
+			if prev, err = lastPosition(tx, list); err != nil {
+				return err
+			}
+		}
+		key, err := position.Between(prev, "")
+		if err != nil {
+			return err
+		}
+		t.Position = key
+		last[list.key()] = key
+	}
+	return nil
+}
+
+// PositionBetween 在清单中 after 与 before 两个待办事项之间为 todo 生成排序键，应在事务中调用
+// after 为空表示放到 before 之前 (紧邻其前一项之后)，before 为空表示放到 after 之后；
+// 两者都为空时放到清单末尾。锚点的键缺失、重复或新键过长时先重新分配整个清单的键，
+// 返回新键以及被重新分配了键的其他待办事项ID
+func PositionBetween(tx *gorm.DB, list TodoList, todo, after, before *Todo) (string, []uint, error) {
+	key, ok, err := positionBetween(tx, list, todo, after, before)
+	if err != nil || ok {
+		return key, nil, err
+	}
+	changed, err := RebalanceList(tx, list)
+	if err != nil {
+		return "", nil, err
+	}
+	for _, t := range []*Todo{after, before} {
+		if t != nil {
+			if err := tx.Model(&Todo{}).Where("id = ?", t.ID).Pluck("position", &t.Position).Error; err != nil {
+				return "", nil, err
+			}
+		}
+	}
+	key, ok, err = positionBetween(tx, list, todo, after, before)
+	if err == nil && !ok {
+		// 重新分配后锚点仍不相邻，说明 after 排在 before 之后
+		err = position.ErrOutOfOrder
+	}
+	return key, changed, err
+}
+
+// positionBetween 尝试直接生成新键，需要先重新分配时返回 ok=false
+func positionBetween(tx *gorm.DB, list TodoList, todo, after, before *Todo) (string, bool, error) {
+	// 同一清单中除自身外的相邻键
+	neighbor := func(anchor *Todo, desc bool) (string, error) {
+		q := list.Scope(tx.Model(&Todo{})).Where("id <> ?", todo.ID)
+		if desc {
+			q = q.Where("position < ?", anchor.Position).Order("position DESC")
+		} else {
+			q = q.Where("position > ?", anchor.Position).Order("position ASC")
+		}
+		var keys []string
+		err := q.Limit(1).Pluck("position", &keys).Error
+		if err != nil || len(keys) == 0 {
+			return "", err
+		}
+		return keys[0], nil
+	}
+
+	var lo, hi string
+	var err error
+	for _, anchor := range []*Todo{after, before} {
+		if anchor == nil {
+			continue
+		}
+		if anchor.Position == "" {
+			return "", false, nil
+		}
+		// 有其他待办事项与锚点的键相同时无法确定先后
+		var ties int64
+		if err := list.Scope(tx.Model(&Todo{})).Where("position = ? AND id NOT IN ?", anchor.Position, []uint{anchor.ID, todo.ID}).
+			Count(&ties).Error; err != nil {
+			return "", false, err
+		}
+		if ties > 0 {
+			return "", false, nil
+		}
+	}
+	switch {
+	case after != nil && before != nil:
+		lo, hi = after.Position, before.Position
+		if lo >= hi {
+			return "", false, nil
+		}
+	case after != nil:
+		lo = after.Position
+		if hi, err = neighbor(after, false); err != nil {
+			return "", false, err
+		}
+	case before != nil:
+		hi = before.Position
+		if lo, err = neighbor(before, true); err != nil {
+			return "", false, err
+		}
+	default:
+		if lo, err = lastPosition(tx.Where("id <> ?", todo.ID), list); err != nil {
+			return "", false, err
+		}
+	}
+	key, err := position.Between(lo, hi)
+	if err != nil {
+		return "", false, err
+	}
+	return key, len(key) <= maxKeyLength, nil
+}
+
+// RebalanceList 按当前顺序为清单中的待办事项重新分配等间距的排序键，应在事务中调用
+// 没有键的 (如存量数据) 按创建顺序排在最后；返回键发生变化的待办事项ID
+func RebalanceList(tx *gorm.DB, list TodoList) ([]uint, error) {
+	var todos []Todo
+	err := list.Scope(tx.Select("id", "position")).
+		Order("position = '' ASC").Order("position ASC").Order("id ASC").Find(&todos).Error
+	if err != nil {
+		return nil, err
+	}
+	var changed []uint
+	for i, key := range position.Spread(len(todos)) {
+		if todos[i].Position == key {
+			continue
+		}
+		// 只改排序键，不更新 updated_at
+		if err := tx.Model(&Todo{}).Where("id = ?", todos[i].ID).UpdateColumn("position", key).Error; err != nil {
+			return nil, err
+		}
+		changed = append(changed, todos[i].ID)
+	}
+	return changed, nil
+}
+
+// RebalancePositions 重新分配存在缺失键或过长键的所有清单，返回每个用户键发生变化的待办事项ID
+func RebalancePositions() (map[uint][]uint, error) {
+	var lists []TodoList
+	err := DB.Model(&Todo{}).Distinct("user_id", "workspace_id", "project_id", "parent_id").
+		Where("position = '' OR LENGTH(position) > ?", RebalanceKeyLength).Find(&lists).Error
+	if err != nil {
+		return nil, err
+	}
+	changed := map[uint][]uint{}
+	for _, list := range lists {
+		var ids []uint
+		err := DB.Transaction(func(tx *gorm.DB) error {
+			var err error
+			ids, err = RebalanceList(tx, list)
+			return err
+		})
+		if err != nil {
+			return changed, err
+		}
+		if len(ids) > 0 {
+			changed[list.UserID] = append(changed[list.UserID], ids...)
+		}
+	}
+	return changed, nil
+}
@@ -0,0 +1,94 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Priority 待办事项优先级，数据库中存储为整数，JSON 中使用名称
+type Priority int8
+
+const (
+	PriorityNone Priority = iota
+	PriorityLow
+	PriorityMedium
+	PriorityHigh
+	PriorityUrgent
+)
+
+// priorityNames 优先级名称，下标与取值对应
+var priorityNames = []string{"none", "low", "medium", "high", "urgent"}
+
+// ParsePriority 根据名称解析优先级
+func ParsePriority(name string) (Priority, error) {
+	for i, n := range priorityNames {
+		if n == name {
+			return Priority(i), nil
+		}
+	}
+	return PriorityNone, fmt.Errorf("无效的优先级: %s", name)
+}
+
+// String 返回优先级名称
+func (p Priority) String() string {
+	if p < 0 || int(p) >= len(priorityNames) {
+		return priorityNames[PriorityNone]
+	}
+	return priorityNames[p]
+}
+
+// MarshalJSON 输出优先级名称
+func (p Priority) MarshalJSON() ([]byte, error) {
+	return json.Marshal(p.String())
+}
+
+// UnmarshalJSON 解析优先级名称
+func (p *Priority) UnmarshalJSON(b []byte) error {
+	var name string
+	if err := json.Unmarshal(b, &name); err != nil {
+		return fmt.Errorf("priority 必须为字符串")
+	}
+	parsed, err := ParsePriority(name)
+	if err != nil {
+		return err
+	}
+	*p = parsed
+	return nil
+}
+
+// ---- 智能排序分数 ----
+
+// 各优先级相当于把截止时间提前的时长
+var priorityBoosts = map[Priority]time.Duration{
+	PriorityNone:   0,
+	PriorityLow:    24 * time.Hour,
+	PriorityMedium: 3 * 24 * time.Hour,
+	PriorityHigh:   7 * 24 * time.Hour,
+	PriorityUrgent: 30 * 24 * time.Hour,
+}
+
+// noDueGrace 没有截止时间的待办事项视为在创建后这么久到期，越早创建的越靠前
+const noDueGrace = 14 * 24 * time.Hour
+
+// SmartScore 计算智能排序分数，分数越高越应该先处理
+//
+// 分数 = 优先级提前量 - 有效截止时间 (秒)
+// 有效截止时间为截止时间，没有截止时间时为创建时间加上 noDueGrace。
+// 分数只与待办事项自身的字段有关，不随当前时间变化，因此可以持久化并建立索引，
+// 排序结果等价于按 "有效截止时间 - 优先级提前量" 升序。
+func (t *Todo) SmartScore() int64 {
+	effective := t.CreatedAt.Add(noDueGrace)
+	if t.DueAt != nil {
+		effective = *t.DueAt
+	}
+	return int64(priorityBoosts[t.Priority].Seconds()) - effective.Unix()
+}
+
+// RefreshSmartScore 重新计算并保存智能排序分数，不修改 updated_at
+func RefreshSmartScore(tx *gorm.DB, todo *Todo) error {
+	todo.Score = todo.SmartScore()
+	return tx.Model(todo).UpdateColumn("score", todo.Score).Error
+}
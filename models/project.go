@@ -0,0 +1,88 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Project 表示用户的项目 (清单)，用于对待办事项分组
+type Project struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	UserID    uint      `json:"user_id" gorm:"not null;index"`
+	Name      string    `json:"name" gorm:"type:varchar(128);not null"`
+	Color     string    `json:"color" gorm:"type:varchar(16);not null;default:'#9e9e9e'"`
+	Archived  bool      `json:"archived" gorm:"not null;default:false"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	// 所属工作区，项目中的待办事项与项目属于同一工作区
+	WorkspaceID uint `json:"workspace_id" gorm:"not null;default:0;index"`
+	// Role 当前用户在项目中的角色，只在响应中返回
+	Role string `json:"role,omitempty" gorm:"-"`
+}
+
+// DefaultProjectColor 未指定颜色时使用的项目颜色
+const DefaultProjectColor = "#9e9e9e"
+
+// 删除项目时对其中待办事项的处理方式
+const (
+	ProjectDeleteCascade = "cascade" // 一并删除项目中的待办事项
+	ProjectDeleteOrphan  = "orphan"  // 保留待办事项，移出项目
+)
+
+// DeleteProject 在事务中删除项目，并按 mode 处理其中的待办事项，actorID 为操作者
+// 返回受影响的待办事项ID，用于清除缓存
+func DeleteProject(project *Project, mode string, actorID uint) ([]uint, error) {
+	var todoIDs []uint
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&Todo{}).Where("project_id = ?", project.ID).Pluck("id", &todoIDs).Error; err != nil {
+			return err
+		}
+		if len(todoIDs) > 0 {
+			switch mode {
+			case ProjectDeleteCascade:
+				if err := DeleteTodos(tx, actorID, todoIDs); err != nil {
+					return err
+				}
+			default:
+				before, err := SnapshotTodos(tx, todoIDs)
+				if err != nil {
+					return err
+				}
+				if err := tx.Model(&Todo{}).Where("id IN ?", todoIDs).Update("project_id", nil).Error; err != nil {
+					return err
+				}
+				if err := RecordRevisions(tx, actorID, RevisionUpdate, before); err != nil {
+					return err
+				}
+			}
+		}
+		if err := tx.Where("project_id = ?", project.ID).Delete(&ProjectMember{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(project).Error
+	})
+	return todoIDs, err
+}
+
+// DeleteTodos 将一批待办事项移入回收站 (软删除) 并写入修订记录，应在事务中调用
+// 同一批移入的待办事项删除时间相同，恢复时据此将一并删除的子任务一起恢复；
+// 检索索引随之删除，恢复时重建。提醒保留，在回收站期间不会触发
+func DeleteTodos(tx *gorm.DB, actorID uint, ids []uint) error {
+	before, err := SnapshotTodos(tx, ids)
+	if err != nil {
+		return err
+	}
+	if err := tx.Where("todo_id IN ?", ids).Delete(&TodoSearchTerm{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("id IN ?", ids).Delete(&Todo{}).Error; err != nil {
+		return err
+	}
+	// 正在运行的计时器随之停止，已记录的时间保留
+	if err := StopTodoTimers(tx, ids, time.Now()); err != nil {
+		return err
+	}
+	return RecordRevisions(tx, actorID, RevisionDelete, before)
+}
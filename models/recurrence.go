@@ -0,0 +1,239 @@
+package models
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	"todolist/calendar"
+	"todolist/recurrence"
+
+	"gorm.io/gorm"
+)
+
+// RecurringSeries 重复待办的系列，保存重复规则和生成新实例时使用的模板
+// 系列中每个发生时间对应一条 Todo 记录，完成当前实例时才生成下一个实例
+type RecurringSeries struct {
+	ID     uint   `json:"id" gorm:"primaryKey"`
+	UserID uint   `json:"user_id" gorm:"not null;index"`
+	RRule  string `json:"rrule" gorm:"column:rrule;type:varchar(512);not null"`
+	// 第一个发生时间，COUNT 从这里开始计数；全天系列存储为 UTC 零点
+	StartAt time.Time `json:"start_at" gorm:"not null"`
+	AllDay  bool      `json:"all_day" gorm:"not null;default:false"`
+	// 展开定时系列使用的时区，创建系列时取自用户设置，保证夏令时等情况下时刻不变
+	Timezone string `json:"timezone" gorm:"type:varchar(64);not null"`
+	// 跳过的日期 (YYYY-MM-DD，按系列时区)，逗号分隔
+	ExDates string `json:"-" gorm:"column:exdates;type:text"`
+	// 新实例的模板，只有按系列修改 (scope=series) 时才会变化
+	Title       string    `json:"title" gorm:"not null"`
+	Description string    `json:"description"`
+	Priority    Priority  `json:"priority" gorm:"type:tinyint;not null;default:0"`
+	ProjectID   *uint     `json:"project_id"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// ErrSeriesEnded 重复规则已经没有后续的发生时间
+var ErrSeriesEnded = errors.New("重复已结束，没有后续的发生时间")
+
+// NewSeries 以待办事项为第一个实例创建系列，待办事项必须有截止时间
+func NewSeries(todo *Todo, rule *recurrence.Rule, exdates []string, loc *time.Location) (*RecurringSeries, error) {
+	if todo.DueAt == nil {
+		return nil, errors.New("重复待办必须设置截止时间")
+	}
+	s := &RecurringSeries{
+		UserID:      todo.UserID,
+		RRule:       rule.String(),
+		StartAt:     *todo.DueAt,
+		AllDay:      todo.AllDay,
+		Timezone:    loc.String(),
+		Title:       todo.Title,
+		Description: todo.Description,
+		Priority:    todo.Priority,
+		ProjectID:   todo.ProjectID,
+	}
+	s.SetExDates(exdates)
+	return s, nil
+}
+
+// Rule 解析系列的重复规则，X-WORKDAY 使用全局工作日历
+// 全局日历在管理员上传节假日安排后会更新，之后展开的结果以新的安排为准
+func (s *RecurringSeries) Rule() (*recurrence.Rule, error) {
+	rule, err := recurrence.Parse(s.RRule)
+	if err != nil {
+		return nil, err
+	}
+	rule.Calendar = calendar.Default()
+	return rule, nil
+}
+
+// Location 返回展开规则使用的时区，全天系列按日期展开，使用 UTC
+func (s *RecurringSeries) Location() *time.Location {
+	if s.AllDay {
+		return time.UTC
+	}
+	if loc, err := time.LoadLocation(s.Timezone); err == nil {
+		return loc
+	}
+	return (&User{}).Location()
+}
+
+// ExDateList 返回跳过的日期列表
+func (s *RecurringSeries) ExDateList() []string {
+	if s.ExDates == "" {
+		return []string{}
+	}
+	return strings.Split(s.ExDates, ",")
+}
+
+// SetExDates 设置跳过的日期，去重并排序
+func (s *RecurringSeries) SetExDates(dates []string) {
+	set := map[string]bool{}
+	for _, d := range dates {
+		set[d] = true
+	}
+	list := make([]string, 0, len(set))
+	for d := range set {
+		list = append(list, d)
+	}
+	sort.Strings(list)
+	s.ExDates = strings.Join(list, ",")
+}
+
+func (s *RecurringSeries) exdateSet() map[string]bool {
+	set := map[string]bool{}
+	for _, d := range s.ExDateList() {
+		set[d] = true
+	}
+	return set
+}
+
+// NextOccurrence 返回晚于 after 的下一个发生时间
+func (s *RecurringSeries) NextOccurrence(after time.Time) (time.Time, error) {
+	rule, err := s.Rule()
+	if err != nil {
+		return time.Time{}, err
+	}
+	loc := s.Location()
+	next, _, ok := rule.Next(s.StartAt.In(loc), after.In(loc), s.exdateSet())
+	if !ok {
+		return time.Time{}, ErrSeriesEnded
+	}
+	return next.UTC(), nil
+}
+
+// Upcoming 返回晚于 after 的最多 n 个发生时间
+func (s *RecurringSeries) Upcoming(after time.Time, n int) ([]time.Time, error) {
+	rule, err := s.Rule()
+	if err != nil {
+		return nil, err
+	}
+	loc := s.Location()
+	// Between 的下界是包含的，这里要求严格晚于 after
+	from := after.In(loc).Add(time.Nanosecond)
+	occurrences := rule.Between(s.StartAt.In(loc), from, time.Date(9999, 1, 1, 0, 0, 0, 0, loc), s.exdateSet(), n)
+	for i := range occurrences {
+		occurrences[i] = occurrences[i].UTC()
+	}
+	return occurrences, nil
+}
+
+// Occurrence 返回实例原定的发生时间，没有记录时依次使用截止时间和创建时间
+func (t *Todo) Occurrence() time.Time {
+	if t.OccurrenceAt != nil {
+		return *t.OccurrenceAt
+	}
+	if t.DueAt != nil {
+		return *t.DueAt
+	}
+	return t.CreatedAt
+}
+
+// CreateNextInstance 在事务中为系列生成 prev 之后的下一个实例
+// 新实例使用系列模板的标题、描述、优先级和项目，沿用上一个实例的父任务、标签和相对提醒；有父任务时改用父任务所在的项目
+// 系列已结束或下一个实例已存在时返回 nil
+// 为新实例腾出位置时清单可能被重新分配，第二个返回值为位置被改写的待办事项ID，提交后由调用方清除其缓存
+func CreateNextInstance(tx *gorm.DB, series *RecurringSeries, prev *Todo) (*Todo, []uint, error) {
+	at, err := series.NextOccurrence(prev.Occurrence())
+	if errors.Is(err, ErrSeriesEnded) {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	// 实例被取消完成后再次完成时，下一个实例已经存在，不重复生成
+	var existing int64
+	// 包括回收站中的实例，否则删除下一个实例后再完成上一个实例会把它重新生成出来
+	if err := tx.Unscoped().Model(&Todo{}).Where("series_id = ? AND occurrence_at = ?", series.ID, at).Count(&existing).Error; err != nil {
+		return nil, nil, err
+	}
+	if existing > 0 {
+		return nil, nil, nil
+	}
+	next := &Todo{
+		UserID:       prev.UserID,
+		WorkspaceID:  prev.WorkspaceID,
+		Title:        series.Title,
+		Description:  series.Description,
+		DueAt:        &at,
+		AllDay:       series.AllDay,
+		Priority:     series.Priority,
+		ProjectID:    series.ProjectID,
+		ParentID:     prev.ParentID,
+		SeriesID:     &series.ID,
+		OccurrenceAt: &at,
+		Tags:         prev.Tags,
+		// 每次的工作量通常相同，沿用上一个实例的预估
+		EstimateMinutes: prev.EstimateMinutes,
+	}
+	// 子任务必须与父任务在同一项目中：父任务仍存在时跟随父任务所在的项目，否则不再挂在父任务下
+	if next.ParentID != nil {
+		var parent Todo
+		err := tx.Unscoped().Select("id", "project_id").First(&parent, *next.ParentID).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			next.ParentID = nil
+		} else if err != nil {
+			return nil, nil, err
+		} else {
+			next.ProjectID = parent.ProjectID
+		}
+	}
+	// 仍在同一清单时紧跟在上一个实例之后，保持用户调整过的位置
+	list := ListOf(next)
+	var rebalanced []uint
+	if list.key() == ListOf(prev).key() {
+		next.Position, rebalanced, err = PositionBetween(tx, list, next, prev, nil)
+	} else {
+		next.Position, err = NextPosition(tx, list)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := tx.Create(next).Error; err != nil {
+		return nil, nil, err
+	}
+	if err := CopyCustomValues(tx, prev.ID, next.ID); err != nil {
+		return nil, nil, err
+	}
+	if err := IndexTodo(tx, next); err != nil {
+		return nil, nil, err
+	}
+	if err := RecordCreated(tx, SystemActor, next.ID); err != nil {
+		return nil, nil, err
+	}
+	// 相对提醒随实例延续，提交后由调用方调用 RescheduleTodoReminders 入队
+	if _, err := CopyRelativeReminders(tx, prev, next); err != nil {
+		return nil, nil, err
+	}
+	return next, rebalanced, nil
+}
+
+// DeleteSeries 删除系列，已生成的实例 (包括回收站中的) 保留为普通待办事项
+func DeleteSeries(tx *gorm.DB, seriesID uint) error {
+	if err := tx.Unscoped().Model(&Todo{}).Where("series_id = ?", seriesID).
+		Updates(map[string]interface{}{"series_id": nil, "occurrence_at": nil}).Error; err != nil {
+		return err
+	}
+	return tx.Delete(&RecurringSeries{}, seriesID).Error
+}
@@ -0,0 +1,223 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"gorm.io/gorm"
+)
+
+// 提醒状态
+const (
+	ReminderPending   = "pending"   // 等待触发
+	ReminderFired     = "fired"     // 已触发
+	ReminderDismissed = "dismissed" // 用户已关闭
+	ReminderCancelled = "cancelled" // 触发时待办事项已完成或已删除，不再提醒
+)
+
+// ReminderQueueKey 待触发提醒的 Redis 有序集合，成员为提醒ID，分数为触发时间 (Unix 秒)
+const ReminderQueueKey = "reminders:pending"
+
+// 全天待办的相对提醒以当天 9:00 (用户时区) 为基准
+const allDayReminderHour = 9
+
+// Reminder 待办事项的提醒，可以是绝对时间，也可以是相对截止时间提前若干分钟
+type Reminder struct {
+	ID     uint `json:"id" gorm:"primaryKey"`
+	UserID uint `json:"user_id" gorm:"not null;index"`
+	TodoID uint `json:"todo_id" gorm:"not null;index"`
+	// 绝对提醒时间，与 OffsetMinutes 二选一
+	RemindAt *time.Time `json:"remind_at"`
+	// 相对提醒：截止时间前多少分钟，截止时间变化后自动重新计算
+	OffsetMinutes *int `json:"offset_minutes"`
+	// 下一次触发时间，稍后提醒时会被推迟；为空表示无法触发 (如相对提醒但待办没有截止时间)
+	FireAt    *time.Time `json:"fire_at" gorm:"index:idx_reminders_status_fire,priority:2"`
+	Status    string     `json:"status" gorm:"type:varchar(16);not null;default:'pending';index:idx_reminders_status_fire,priority:1"`
+	FiredAt   *time.Time `json:"fired_at"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+// ComputeFireAt 根据待办事项的截止时间计算提醒的触发时间
+func (r *Reminder) ComputeFireAt(todo *Todo, loc *time.Location) *time.Time {
+	if r.RemindAt != nil {
+		t := *r.RemindAt
+		return &t
+	}
+	if r.OffsetMinutes == nil || todo.DueAt == nil {
+		return nil
+	}
+	anchor := *todo.DueAt
+	if todo.AllDay {
+		y, m, d := todo.DueAt.UTC().Date()
+		anchor = time.Date(y, m, d, allDayReminderHour, 0, 0, 0, loc)
+	}
+	t := anchor.Add(-time.Duration(*r.OffsetMinutes) * time.Minute).UTC()
+	return &t
+}
+
+// EnqueueReminder 将提醒加入 Redis 延迟队列，不是待触发状态或没有触发时间的从队列中移除
+// 应在数据库事务提交之后调用
+func EnqueueReminder(r *Reminder) error {
+	member := strconv.FormatUint(uint64(r.ID), 10)
+	if r.Status != ReminderPending || r.FireAt == nil {
+		return Rdb.ZRem(Ctx, ReminderQueueKey, member).Err()
+	}
+	return Rdb.ZAdd(Ctx, ReminderQueueKey, &redis.Z{Score: float64(r.FireAt.Unix()), Member: member}).Err()
+}
+
+// userLocation 返回用户所在时区，查询失败时使用默认时区
+func userLocation(db *gorm.DB, userID uint) *time.Location {
+	var user User
+	db.Select("id", "timezone").First(&user, userID)
+	return user.Location()
+}
+
+// RescheduleTodoReminders 待办事项的截止时间变化后重新计算提醒的时间并更新队列
+// 已触发的相对提醒如果新的触发时间还没到 (如截止时间被推迟)，会重新变为待触发
+func RescheduleTodoReminders(todo *Todo) error {
+	var reminders []Reminder
+	err := DB.Where("todo_id = ? AND (status = ? OR (status = ? AND offset_minutes IS NOT NULL))",
+		todo.ID, ReminderPending, ReminderFired).Find(&reminders).Error
+	if err != nil {
+		return err
+	}
+	if len(reminders) == 0 {
+		return nil
+	}
+	loc := userLocation(DB, todo.UserID)
+	now := time.Now()
+	for i := range reminders {
+		r := &reminders[i]
+		r.FireAt = r.ComputeFireAt(todo, loc)
+		if r.Status == ReminderFired {
+			if r.FireAt == nil || !r.FireAt.After(now) {
+				continue
+			}
+			r.Status = ReminderPending
+		}
+		if err := DB.Model(r).Updates(map[string]interface{}{"fire_at": r.FireAt, "status": r.Status}).Error; err != nil {
+			return err
+		}
+		if err := EnqueueReminder(r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CopyRelativeReminders 为重复待办新生成的实例复制上一个实例的相对提醒，应在事务中调用
+func CopyRelativeReminders(tx *gorm.DB, from, to *Todo) ([]Reminder, error) {
+	var src []Reminder
+	if err := tx.Where("todo_id = ? AND offset_minutes IS NOT NULL", from.ID).Find(&src).Error; err != nil {
+		return nil, err
+	}
+	if len(src) == 0 {
+		return nil, nil
+	}
+	loc := userLocation(tx, to.UserID)
+	copies := make([]Reminder, 0, len(src))
+	seen := map[int]bool{}
+	for _, r := range src {
+		if seen[*r.OffsetMinutes] {
+			continue
+		}
+		seen[*r.OffsetMinutes] = true
+		c := Reminder{UserID: to.UserID, TodoID: to.ID, OffsetMinutes: r.OffsetMinutes, Status: ReminderPending}
+		c.FireAt = c.ComputeFireAt(to, loc)
+		copies = append(copies, c)
+	}
+	if err := tx.Create(&copies).Error; err != nil {
+		return nil, err
+	}
+	return copies, nil
+}
+
+// RecoverReminders 启动时将数据库中所有待触发的提醒重新加入队列
+// 已错过的提醒分数早于当前时间，调度器会立即触发
+func RecoverReminders() (int, error) {
+	return enqueuePendingReminders(DB)
+}
+
+// RequeueDueReminders 将已到期但仍为待触发的提醒重新加入队列，由调度器定期调用
+// 队列只保存在 Redis 中：提交后入队失败，或被认领 (ZREM) 后进程在标记为已触发之前退出的提醒
+// 不在队列中，由这里找回。仍在队列中的只是更新分数，ClaimReminder 的条件 UPDATE 保证不会重复触发
+func RequeueDueReminders(now time.Time) (int, error) {
+	return enqueuePendingReminders(DB.Where("fire_at <= ?", now))
+}
+
+// enqueuePendingReminders 将查询条件 db 下所有待触发的提醒加入队列，返回入队的数量
+func enqueuePendingReminders(db *gorm.DB) (int, error) {
+	var reminders []Reminder
+	// 回收站中待办事项的提醒暂不入队，恢复时由 RescheduleTodoReminders 重新入队
+	err := db.Where("status = ? AND fire_at IS NOT NULL", ReminderPending).
+		Where("todo_id IN (SELECT id FROM todos WHERE deleted_at IS NULL)").
+		Find(&reminders).Error
+	if err != nil {
+		return 0, err
+	}
+	for i := range reminders {
+		if err := EnqueueReminder(&reminders[i]); err != nil {
+			return 0, err
+		}
+	}
+	return len(reminders), nil
+}
+
+// ErrReminderNotDue 提醒已被处理或尚未到触发时间
+var ErrReminderNotDue = errors.New("提醒已被处理或尚未到触发时间")
+
+// ClaimReminder 将到期的提醒标记为已触发，返回提醒及其待办事项
+// 通过带条件的 UPDATE 保证同一次触发只有一个实例能标记成功；待办事项已完成或已永久删除时取消提醒
+func ClaimReminder(id uint, now time.Time) (*Reminder, *Todo, error) {
+	var r Reminder
+	if err := DB.First(&r, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, ErrReminderNotDue
+		}
+		return nil, nil, err
+	}
+	if r.Status != ReminderPending || r.FireAt == nil {
+		return nil, nil, ErrReminderNotDue
+	}
+	if r.FireAt.After(now) {
+		// 队列中的分数已过期 (如被推迟)，按最新时间重新入队
+		if err := EnqueueReminder(&r); err != nil {
+			return nil, nil, err
+		}
+		return nil, nil, ErrReminderNotDue
+	}
+
+	status := ReminderFired
+	var todo Todo
+	err := DB.Unscoped().First(&todo, r.TodoID).Error
+	if err == nil && todo.DeletedAt.Valid {
+		// 待办事项在回收站中：提醒保持待触发，恢复后重新入队
+		return nil, nil, ErrReminderNotDue
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) || (err == nil && todo.Completed) {
+		status = ReminderCancelled
+	} else if err != nil {
+		return nil, nil, err
+	}
+
+	res := DB.Model(&Reminder{}).
+		Where("id = ? AND status = ? AND fire_at = ?", r.ID, ReminderPending, *r.FireAt).
+		Updates(map[string]interface{}{"status": status, "fired_at": now})
+	if res.Error != nil {
+		return nil, nil, res.Error
+	}
+	if res.RowsAffected == 0 || status == ReminderCancelled {
+		return nil, nil, ErrReminderNotDue
+	}
+	r.Status, r.FiredAt = status, &now
+	return &r, &todo, nil
+}
+
+// ReminderChannel 提醒触发后发布通知的 Redis 频道
+func ReminderChannel(userID uint) string {
+	return fmt.Sprintf("user:%d:reminders", userID)
+}
@@ -0,0 +1,279 @@
+package models
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 修订记录的操作类型
+const (
+	RevisionCreate    = "create"
+	RevisionUpdate    = "update"
+	RevisionDelete    = "delete"
+	RevisionRestore   = "restore"
+	RevisionArchive   = "archive"
+	RevisionUnarchive = "unarchive"
+	RevisionMove      = "move"
+	RevisionSkip      = "skip"
+	RevisionRevert    = "revert"
+)
+
+// SystemActor 后台任务和自动生成 (如重复待办的下一个实例) 的修订记录使用的操作者ID
+const SystemActor uint = 0
+
+// ErrRevisionNotFound 指定的修订不存在
+var ErrRevisionNotFound = errors.New("修订记录不存在")
+
+// revisionFields 记录修订的字段；position、score、拼音列等派生字段不记录
+// custom_fields 为所有自定义字段值 (键为字段ID)，任一字段变化都记为该项的变化
+var revisionFields = []string{
+	"title", "description", "completed", "due_at", "all_day", "priority", "estimate_minutes",
+	"project_id", "parent_id", "tag_ids", "custom_fields", "archived_at", "deleted_at",
+}
+
+// revertibleFields 回滚时恢复的字段，只包含内容，不包含完成状态和所在的清单
+// (完成会触发子任务级联和重复待办生成下一个实例，移动应使用移动接口)；
+// 自定义字段的定义和选项可能已经变化，旧值不一定仍然有效，也不回滚
+var revertibleFields = []string{"title", "description", "due_at", "all_day", "priority", "estimate_minutes"}
+
+// FieldChange 一个字段的旧值和新值 (JSON)，新建时旧值为 null
+type FieldChange struct {
+	Old json.RawMessage `json:"old"`
+	New json.RawMessage `json:"new"`
+}
+
+// TodoRevision 待办事项的一条修订记录，与修改在同一事务中写入
+// Rev 是每个待办事项内从 1 开始递增的版本号
+type TodoRevision struct {
+	ID       uint                   `json:"id" gorm:"primaryKey"`
+	TodoID   uint                   `json:"todo_id" gorm:"not null;uniqueIndex:idx_revisions_todo_rev,priority:1"`
+	Rev      int                    `json:"rev" gorm:"not null;uniqueIndex:idx_revisions_todo_rev,priority:2"`
+	UserID   uint                   `json:"-" gorm:"not null;index"` // 待办事项所属的用户
+	ActorID  uint                   `json:"actor_id" gorm:"not null"`
+	Action   string                 `json:"action" gorm:"type:varchar(16);not null"`
+	Changes  map[string]FieldChange `json:"changes" gorm:"type:json;serializer:json"`
+	RevertTo *int                   `json:"revert_to,omitempty"` // 回滚操作回滚到的版本号
+	// 修订时间
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// snapshot 待办事项中需要记录的字段值，Tags 和 CustomFields 需要已加载
+func snapshot(t *Todo) map[string]json.RawMessage {
+	tagIDs := make([]uint, len(t.Tags))
+	for i, tag := range t.Tags {
+		tagIDs[i] = tag.ID
+	}
+	sort.Slice(tagIDs, func(i, j int) bool { return tagIDs[i] < tagIDs[j] })
+	var deletedAt *time.Time
+	if t.DeletedAt.Valid {
+		deletedAt = &t.DeletedAt.Time
+	}
+	values := map[string]interface{}{
+		"title":            t.Title,
+		"description":      t.Description,
+		"completed":        t.Completed,
+		"due_at":           t.DueAt,
+		"all_day":          t.AllDay,
+		"priority":         t.Priority,
+		"estimate_minutes": t.EstimateMinutes,
+		"project_id":       t.ProjectID,
+		"parent_id":        t.ParentID,
+		"tag_ids":          tagIDs,
+		"custom_fields":    t.CustomFields,
+		"archived_at":      t.ArchivedAt,
+		"deleted_at":       deletedAt,
+	}
+	out := make(map[string]json.RawMessage, len(values))
+	for k, v := range values {
+		out[k], _ = json.Marshal(v)
+	}
+	return out
+}
+
+// SnapshotTodos 加载待办事项 (包括回收站中的) 的当前状态，修改前调用，修改后传给 RecordRevisions
+// 这些行被加上排他锁直到事务结束，同一待办事项的并发修改在此排队，读到的总是最新提交的状态
+func SnapshotTodos(tx *gorm.DB, ids []uint) ([]Todo, error) {
+	var todos []Todo
+	if len(ids) == 0 {
+		return todos, nil
+	}
+	if err := tx.Unscoped().Clauses(clause.Locking{Strength: "UPDATE"}).Preload("Tags").Where("id IN ?", ids).Find(&todos).Error; err != nil {
+		return nil, err
+	}
+	return todos, FillCustomFields(tx, todos)
+}
+
+// RecordCreated 为新建的待办事项写入修订记录，所有字段的旧值为 null
+func RecordCreated(tx *gorm.DB, actorID uint, ids ...uint) error {
+	return recordRevisions(tx, actorID, RevisionCreate, ids, nil, nil)
+}
+
+// RecordRevisions 对比修改前的状态与事务中的最新状态，为有变化的待办事项写入修订记录
+func RecordRevisions(tx *gorm.DB, actorID uint, action string, before []Todo) error {
+	ids := TodoIDs(before)
+	old := make(map[uint]map[string]json.RawMessage, len(before))
+	for i := range before {
+		old[before[i].ID] = snapshot(&before[i])
+	}
+	return recordRevisions(tx, actorID, action, ids, old, nil)
+}
+
+// recordRevisions 加载 ids 的最新状态并与 old 对比写入修订记录，old 中没有的视为新建
+func recordRevisions(tx *gorm.DB, actorID uint, action string, ids []uint, old map[uint]map[string]json.RawMessage, revertTo *int) error {
+	after, err := SnapshotTodos(tx, ids)
+	if err != nil || len(after) == 0 {
+		return err
+	}
+
+	// 每个待办事项当前的最大版本号；上面的 SnapshotTodos 已锁定这些行 (修改前的快照同样加锁)，
+	// 同一待办事项的修订记录在事务之间串行写入，版本号不会并发冲突
+	var maxRevs []struct {
+		TodoID uint
+		Rev    int
+	}
+	if err := tx.Model(&TodoRevision{}).Select("todo_id, MAX(rev) AS rev").
+		Where("todo_id IN ?", ids).Group("todo_id").Scan(&maxRevs).Error; err != nil {
+		return err
+	}
+	nextRev := make(map[uint]int, len(maxRevs))
+	for _, m := range maxRevs {
+		nextRev[m.TodoID] = m.Rev
+	}
+
+	var revisions []TodoRevision
+	for i := range after {
+		t := &after[i]
+		now := snapshot(t)
+		prev, existed := old[t.ID]
+		changes := map[string]FieldChange{}
+		for _, f := range revisionFields {
+			if !existed {
+				changes[f] = FieldChange{Old: json.RawMessage("null"), New: now[f]}
+			} else if !bytes.Equal(prev[f], now[f]) {
+				changes[f] = FieldChange{Old: prev[f], New: now[f]}
+			}
+		}
+		if len(changes) == 0 {
+			continue
+		}
+		nextRev[t.ID]++
+		revisions = append(revisions, TodoRevision{
+			TodoID:   t.ID,
+			Rev:      nextRev[t.ID],
+			UserID:   t.UserID,
+			ActorID:  actorID,
+			Action:   action,
+			Changes:  changes,
+			RevertTo: revertTo,
+		})
+	}
+	if len(revisions) == 0 {
+		return nil
+	}
+	return tx.CreateInBatches(revisions, 500).Error
+}
+
+// stateAt 计算待办事项在第 rev 次修订之后各字段的值
+// 字段在 rev 及之前修改过时取最后一次的新值；只在 rev 之后修改过时取之后第一次修改的旧值
+// (启用修订记录之前创建的待办事项没有 create 记录)；从未修改过的取当前值
+func stateAt(revisions []TodoRevision, current map[string]json.RawMessage, rev int) map[string]json.RawMessage {
+	state := make(map[string]json.RawMessage, len(current))
+	settled := map[string]bool{}
+	for i := len(revisions) - 1; i >= 0; i-- {
+		r := revisions[i]
+		for f, ch := range r.Changes {
+			if settled[f] {
+				continue
+			}
+			if r.Rev <= rev {
+				state[f] = ch.New
+				settled[f] = true
+			} else {
+				state[f] = ch.Old
+			}
+		}
+	}
+	for f, v := range current {
+		if _, ok := state[f]; !ok {
+			state[f] = v
+		}
+	}
+	return state
+}
+
+// RevertTodo 将待办事项的内容 (标题、描述、截止时间、优先级) 恢复到第 rev 次修订之后的状态，应在事务中调用
+// 回滚本身也写入一条修订记录；内容没有变化时返回 false
+func RevertTodo(tx *gorm.DB, actorID uint, todo *Todo, rev int) (bool, error) {
+	// 先锁定待办事项，保证读到的修订历史与当前状态一致
+	before, err := SnapshotTodos(tx, []uint{todo.ID})
+	if err != nil || len(before) == 0 {
+		return false, err
+	}
+	var revisions []TodoRevision
+	if err := tx.Where("todo_id = ?", todo.ID).Order("rev ASC").Find(&revisions).Error; err != nil {
+		return false, err
+	}
+	found := false
+	for _, r := range revisions {
+		found = found || r.Rev == rev
+	}
+	if !found {
+		return false, ErrRevisionNotFound
+	}
+	current := snapshot(&before[0])
+	state := stateAt(revisions, current, rev)
+
+	var target struct {
+		Title           string     `json:"title"`
+		Description     string     `json:"description"`
+		DueAt           *time.Time `json:"due_at"`
+		AllDay          bool       `json:"all_day"`
+		Priority        Priority   `json:"priority"`
+		EstimateMinutes *int       `json:"estimate_minutes"`
+	}
+	fields := map[string]json.RawMessage{}
+	changed := false
+	for _, f := range revertibleFields {
+		fields[f] = state[f]
+		changed = changed || !bytes.Equal(state[f], current[f])
+	}
+	if !changed {
+		return false, nil
+	}
+	data, _ := json.Marshal(fields)
+	if err := json.Unmarshal(data, &target); err != nil {
+		return false, err
+	}
+
+	updates := map[string]interface{}{
+		"title":            target.Title,
+		"description":      target.Description,
+		"due_at":           target.DueAt,
+		"all_day":          target.AllDay,
+		"priority":         target.Priority,
+		"estimate_minutes": target.EstimateMinutes,
+	}
+	for k, v := range TitlePinyinColumns(target.Title) {
+		updates[k] = v
+	}
+	if err := tx.Model(todo).Updates(updates).Error; err != nil {
+		return false, err
+	}
+	if err := tx.Preload("Tags").First(todo, todo.ID).Error; err != nil {
+		return false, err
+	}
+	if err := RefreshSmartScore(tx, todo); err != nil {
+		return false, err
+	}
+	if err := IndexTodo(tx, todo); err != nil {
+		return false, err
+	}
+	old := map[uint]map[string]json.RawMessage{todo.ID: current}
+	return true, recordRevisions(tx, actorID, RevisionRevert, []uint{todo.ID}, old, &rev)
+}
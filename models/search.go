@@ -0,0 +1,84 @@
+package models
+
+import (
+	"unicode/utf8"
+
+	"todolist/search"
+
+	"gorm.io/gorm"
+)
+
+// TodoSearchTerm 待办事项全文检索的倒排索引记录
+// 每条记录表示某个词元出现在某个待办事项的某个字段中，按用户隔离
+type TodoSearchTerm struct {
+	ID     uint         `gorm:"primaryKey"`
+	UserID uint         `gorm:"not null;index:idx_search_user_term,priority:1"`
+	Term   string       `gorm:"type:varchar(128);not null;index:idx_search_user_term,priority:2"`
+	TodoID uint         `gorm:"not null;index"`
+	Field  search.Field `gorm:"not null"`
+	Freq   int          `gorm:"not null"`
+}
+
+// IndexTodo 重建单个待办事项的检索索引，应与待办事项的写入在同一事务中调用
+func IndexTodo(tx *gorm.DB, todo *Todo) error {
+	if err := RemoveTodoIndex(tx, todo.ID); err != nil {
+		return err
+	}
+
+	var terms []TodoSearchTerm
+	fields := []struct {
+		field search.Field
+		text  string
+	}{
+		{search.FieldTitle, todo.Title},
+		{search.FieldDescription, todo.Description},
+	}
+	for _, f := range fields {
+		for _, tok := range search.Tokenize(f.text) {
+			terms = append(terms, TodoSearchTerm{
+				UserID: todo.UserID,
+				Term:   tok.Term,
+				TodoID: todo.ID,
+				Field:  f.field,
+				Freq:   tok.Freq,
+			})
+		}
+	}
+	if len(terms) == 0 {
+		return nil
+	}
+	return tx.CreateInBatches(terms, 500).Error
+}
+
+// RemoveTodoIndex 删除单个待办事项的检索索引
+func RemoveTodoIndex(tx *gorm.DB, todoID uint) error {
+	return tx.Where("todo_id = ?", todoID).Delete(&TodoSearchTerm{}).Error
+}
+
+// 拼音列的最大长度 (按字符计)，与列定义保持一致
+const (
+	maxTitlePinyinLen   = 1024
+	maxTitleInitialsLen = 255
+)
+
+// TitlePinyinColumns 根据标题计算拼音列的值，用于更新操作
+func TitlePinyinColumns(title string) map[string]interface{} {
+	full, initials := search.Pinyin(title)
+	return map[string]interface{}{
+		"title_pinyin":   truncateRunes(full, maxTitlePinyinLen),
+		"title_initials": truncateRunes(initials, maxTitleInitialsLen),
+	}
+}
+
+// truncateRunes 按字符截断字符串，避免截出不完整的 UTF-8 序列
+func truncateRunes(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return string([]rune(s)[:n])
+}
+
+// FillTodoPinyin 为已有的待办事项补全拼音列，不修改 updated_at
+func FillTodoPinyin(tx *gorm.DB, todo *Todo) error {
+	return tx.Model(todo).UpdateColumns(TitlePinyinColumns(todo.Title)).Error
+}
@@ -0,0 +1,23 @@
+package models
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestTitlePinyinColumnsTruncatesByRune(t *testing.T) {
+	title := strings.Repeat("молоко ", 400)
+	cols := TitlePinyinColumns(title)
+	full := cols["title_pinyin"].(string)
+	initials := cols["title_initials"].(string)
+	if !utf8.ValidString(full) || !utf8.ValidString(initials) {
+		t.Fatalf("truncated columns are not valid UTF-8: %q, %q", full, initials)
+	}
+	if n := utf8.RuneCountInString(full); n != maxTitlePinyinLen {
+		t.Errorf("title_pinyin has %d runes, want %d", n, maxTitlePinyinLen)
+	}
+	if n := utf8.RuneCountInString(initials); n != maxTitleInitialsLen {
+		t.Errorf("title_initials has %d runes, want %d", n, maxTitleInitialsLen)
+	}
+}
@@ -0,0 +1,123 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 协作角色，权限依次递增：viewer 只读，editor 可以修改项目中的待办事项，owner 还可以管理项目和成员
+const (
+	RoleViewer = "viewer"
+	RoleEditor = "editor"
+	RoleOwner  = "owner"
+)
+
+var roleRank = map[string]int{RoleViewer: 1, RoleEditor: 2, RoleOwner: 3}
+
+// ValidRole 是否为支持的角色
+func ValidRole(role string) bool {
+	return roleRank[role] > 0
+}
+
+// RoleAtLeast 角色是否具有 min 的权限，空角色 (无权访问) 总是返回 false
+func RoleAtLeast(role, min string) bool {
+	return role != "" && roleRank[role] >= roleRank[min]
+}
+
+// ProjectMember 项目的协作成员
+// 项目的创建者 (Project.UserID) 始终是所有者，不在此表中；共享项目中的待办事项同样属于创建者 (Todo.UserID)
+type ProjectMember struct {
+	ProjectID uint      `json:"project_id" gorm:"primaryKey"`
+	UserID    uint      `json:"user_id" gorm:"primaryKey;index"`
+	Role      string    `json:"role" gorm:"type:varchar(16);not null"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// ProjectRole 用户在项目中的角色，创建者为 owner，不是成员时返回空字符串
+func ProjectRole(db *gorm.DB, userID uint, project *Project) (string, error) {
+	if project.UserID == userID {
+		return RoleOwner, nil
+	}
+	var members []ProjectMember
+	if err := db.Where("project_id = ? AND user_id = ?", project.ID, userID).Limit(1).Find(&members).Error; err != nil {
+		return "", err
+	}
+	if len(members) == 0 {
+		return "", nil
+	}
+	return members[0].Role, nil
+}
+
+// TodoRole 用户对待办事项的角色：自己的待办事项为 owner，共享项目中的待办事项为在该项目中的角色
+func TodoRole(db *gorm.DB, userID uint, todo *Todo) (string, error) {
+	if todo.UserID == userID {
+		return RoleOwner, nil
+	}
+	if todo.ProjectID == nil {
+		return "", nil
+	}
+	var members []ProjectMember
+	if err := db.Where("project_id = ? AND user_id = ?", *todo.ProjectID, userID).Limit(1).Find(&members).Error; err != nil {
+		return "", err
+	}
+	if len(members) == 0 {
+		return "", nil
+	}
+	return members[0].Role, nil
+}
+
+// sharedProjectIDs 共享给用户的项目ID子查询
+func sharedProjectIDs(userID uint) *gorm.DB {
+	return DB.Model(&ProjectMember{}).Select("project_id").Where("user_id = ?", userID)
+}
+
+// VisibleTodos 限定为用户在工作区中可以看到的待办事项：自己的，以及共享给他的项目中的
+// 其他工作区的待办事项即使属于自己也看不到
+func VisibleTodos(db *gorm.DB, userID, workspaceID uint) *gorm.DB {
+	return db.Where("todos.workspace_id = ? AND (todos.user_id = ? OR todos.project_id IN (?))", workspaceID, userID, sharedProjectIDs(userID))
+}
+
+// VisibleProjects 限定为用户在工作区中可以看到的项目：自己创建的，以及共享给他的
+func VisibleProjects(db *gorm.DB, userID, workspaceID uint) *gorm.DB {
+	return db.Where("projects.workspace_id = ? AND (projects.user_id = ? OR projects.id IN (?))", workspaceID, userID, sharedProjectIDs(userID))
+}
+
+// FillProjectRoles 填充用户在各项目中的角色
+func FillProjectRoles(db *gorm.DB, userID uint, projects []Project) error {
+	var members []ProjectMember
+	if err := db.Where("user_id = ?", userID).Find(&members).Error; err != nil {
+		return err
+	}
+	roles := make(map[uint]string, len(members))
+	for _, m := range members {
+		roles[m.ProjectID] = m.Role
+	}
+	for i := range projects {
+		if projects[i].UserID == userID {
+			projects[i].Role = RoleOwner
+		} else {
+			projects[i].Role = roles[projects[i].ID]
+		}
+	}
+	return nil
+}
+
+// MemberIDs 项目的协作成员ID (不含创建者)
+func MemberIDs(db *gorm.DB, projectID uint) ([]uint, error) {
+	var ids []uint
+	err := db.Model(&ProjectMember{}).Where("project_id = ?", projectID).Pluck("user_id", &ids).Error
+	return ids, err
+}
+
+// TodoMemberIDs 通过共享项目能看到这些待办事项的成员ID (不含所有者)，用于把缓存清除扩散到每个成员
+func TodoMemberIDs(db *gorm.DB, todoIDs []uint) ([]uint, error) {
+	var ids []uint
+	if len(todoIDs) == 0 {
+		return ids, nil
+	}
+	projectIDs := db.Model(&Todo{}).Unscoped().Select("project_id").Where("id IN ? AND project_id IS NOT NULL", todoIDs)
+	err := db.Model(&ProjectMember{}).Distinct("user_id").Where("project_id IN (?)", projectIDs).Pluck("user_id", &ids).Error
+	return ids, err
+}
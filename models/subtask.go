@@ -0,0 +1,130 @@
+package models
+
+import (
+	"errors"
+
+	"gorm.io/gorm"
+)
+
+// 子任务树的最大深度，防止异常数据导致无限遍历
+const maxSubtaskDepth = 256
+
+// 父任务完成或删除时对子任务的处理策略
+const (
+	SubtaskPolicyCascade = "cascade" // 完成/删除父任务时同时完成/删除所有子任务
+	SubtaskPolicyKeep    = "keep"    // 只处理父任务；删除时子任务上移一级
+	SubtaskPolicyBlock   = "block"   // 存在未完成的子任务 (完成时) 或任何子任务 (删除时) 则拒绝
+)
+
+// ErrSubtaskCycle 移动后会形成环 (移动到自身或自己的子任务下)
+var ErrSubtaskCycle = errors.New("不能移动到自身或自己的子任务下")
+
+// LoadDescendants 按层加载某个待办事项的所有子孙节点 (不含自身)
+// 每一层都限定 user_id，保证只会返回该用户自己的待办事项
+func LoadDescendants(db *gorm.DB, userID, rootID uint) ([]Todo, error) {
+	db = db.Session(&gorm.Session{}) // 保证循环中每次查询的条件互不影响
+	var all []Todo
+	visited := map[uint]bool{rootID: true}
+	frontier := []uint{rootID}
+	for depth := 0; len(frontier) > 0; depth++ {
+		if depth >= maxSubtaskDepth {
+			return nil, errors.New("子任务层级过深")
+		}
+		var level []Todo
+		if err := db.Where("parent_id IN ? AND user_id = ?", frontier, userID).Order("id ASC").Find(&level).Error; err != nil {
+			return nil, err
+		}
+		frontier = frontier[:0]
+		for _, t := range level {
+			if visited[t.ID] {
+				continue
+			}
+			visited[t.ID] = true
+			all = append(all, t)
+			frontier = append(frontier, t.ID)
+		}
+	}
+	return all, nil
+}
+
+// TodoIDs 返回待办事项的ID列表
+func TodoIDs(todos []Todo) []uint {
+	ids := make([]uint, len(todos))
+	for i, t := range todos {
+		ids[i] = t.ID
+	}
+	return ids
+}
+
+// BuildSubtree 将根节点和其子孙节点组装为树，子节点放在 Children 中
+func BuildSubtree(root Todo, descendants []Todo) Todo {
+	byParent := map[uint][]Todo{}
+	for _, t := range descendants {
+		byParent[*t.ParentID] = append(byParent[*t.ParentID], t)
+	}
+	var build func(node Todo) Todo
+	build = func(node Todo) Todo {
+		children := byParent[node.ID]
+		node.Children = make([]Todo, len(children))
+		for i, child := range children {
+			node.Children[i] = build(child)
+		}
+		return node
+	}
+	return build(root)
+}
+
+// SubtaskProgress 子任务完成情况汇总
+type SubtaskProgress struct {
+	Done        int `json:"done"`         // 已完成的子孙任务数
+	Total       int `json:"total"`        // 子孙任务总数
+	DirectDone  int `json:"direct_done"`  // 已完成的直接子任务数
+	DirectTotal int `json:"direct_total"` // 直接子任务数
+	Percent     int `json:"percent"`      // 子孙任务完成百分比，没有子任务时为父任务自身的完成状态
+}
+
+// RollupProgress 根据子孙节点计算完成情况
+func RollupProgress(root Todo, descendants []Todo) SubtaskProgress {
+	var p SubtaskProgress
+	for _, t := range descendants {
+		p.Total++
+		direct := t.ParentID != nil && *t.ParentID == root.ID
+		if direct {
+			p.DirectTotal++
+		}
+		if t.Completed {
+			p.Done++
+			if direct {
+				p.DirectDone++
+			}
+		}
+	}
+	switch {
+	case p.Total > 0:
+		p.Percent = p.Done * 100 / p.Total
+	case root.Completed:
+		p.Percent = 100
+	}
+	return p
+}
+
+// CheckMoveTarget 校验把 todoID 移动到 parentID 下不会形成环
+// parentID 为 nil 表示移动为顶层任务
+func CheckMoveTarget(db *gorm.DB, userID, todoID uint, parentID *uint) error {
+	if parentID == nil {
+		return nil
+	}
+	if *parentID == todoID {
+		return ErrSubtaskCycle
+	}
+	descendants, err := LoadDescendants(db, userID, todoID)
+	if err != nil {
+		return err
+	}
+	for _, t := range descendants {
+		if t.ID == *parentID {
+			return ErrSubtaskCycle
+		}
+	}
+	return nil
+}
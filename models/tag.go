@@ -0,0 +1,27 @@
+package models
+
+import (
+	"time"
+)
+
+// Tag 表示用户在某个工作区中的标签，同一用户在同一工作区下标签名唯一
+type Tag struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	UserID    uint      `json:"user_id" gorm:"not null;uniqueIndex:idx_tags_owner_name,priority:1"`
+	Name      string    `json:"name" gorm:"type:varchar(64);not null;uniqueIndex:idx_tags_owner_name,priority:3"`
+	Color     string    `json:"color" gorm:"type:varchar(16);not null;default:'#9e9e9e'"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	// 所属工作区，标签只能用于同一工作区中的待办事项
+	WorkspaceID uint `json:"workspace_id" gorm:"not null;default:0;uniqueIndex:idx_tags_owner_name,priority:2"`
+}
+
+// DefaultTagColor 未指定颜色时使用的标签颜色
+const DefaultTagColor = "#9e9e9e"
+
+// TagTodoIDs 返回打了指定标签的待办事项ID，用于标签变化后清除缓存
+func TagTodoIDs(tagID uint) ([]uint, error) {
+	var ids []uint
+	err := DB.Table("todo_tags").Where("tag_id = ?", tagID).Pluck("todo_id", &ids).Error
+	return ids, err
+}
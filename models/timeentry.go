@@ -0,0 +1,156 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 时间记录的来源
+const (
+	TimeEntryTimer  = "timer"  // 计时器
+	TimeEntryManual = "manual" // 手动补录
+)
+
+// MaxTimeEntryDuration 手动补录的单条时间记录的最长时长
+const MaxTimeEntryDuration = 24 * time.Hour
+
+var (
+	// ErrTimerRunning 该待办事项的计时器已经在运行
+	ErrTimerRunning = errors.New("该待办事项的计时器已在运行")
+	// ErrTimerNotRunning 该待办事项没有正在运行的计时器
+	ErrTimerNotRunning = errors.New("该待办事项没有正在运行的计时器")
+)
+
+// TimeEntry 在待办事项上花费的一段时间，结束时间为空表示计时器正在运行
+// 运行状态保存在数据库中，服务重启后计时器继续有效；每个用户同一时间最多一个运行中的计时器
+type TimeEntry struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	UserID    uint      `json:"user_id" gorm:"not null;index:idx_time_entries_user_started,priority:1"`
+	TodoID    uint      `json:"todo_id" gorm:"not null;index"`
+	StartedAt time.Time `json:"started_at" gorm:"not null;index:idx_time_entries_user_started,priority:2"`
+	// 结束时间，为空表示正在计时
+	EndedAt   *time.Time `json:"ended_at" gorm:"index"`
+	Source    string     `json:"source" gorm:"type:varchar(16);not null"`
+	Note      string     `json:"note" gorm:"type:varchar(255);not null;default:''"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+// Duration 记录的时长，正在运行的计时到 now
+func (e *TimeEntry) Duration(now time.Time) time.Duration {
+	end := now
+	if e.EndedAt != nil {
+		end = *e.EndedAt
+	}
+	if end.Before(e.StartedAt) {
+		return 0
+	}
+	return end.Sub(e.StartedAt)
+}
+
+// RunningTimer 返回用户正在运行的计时器，没有时返回 nil
+func RunningTimer(db *gorm.DB, userID uint) (*TimeEntry, error) {
+	var entries []TimeEntry
+	if err := db.Where("user_id = ? AND ended_at IS NULL", userID).Limit(1).Find(&entries).Error; err != nil {
+		return nil, err
+	}
+	if len(entries) == 0 {
+		return nil, nil
+	}
+	return &entries[0], nil
+}
+
+// StartTimer 为待办事项启动计时器，应在事务中调用
+// 用户在其他待办事项上的计时器会先被停止并作为 stopped 返回；同一待办事项已在计时时返回 ErrTimerRunning
+func StartTimer(tx *gorm.DB, userID, todoID uint, now time.Time) (entry, stopped *TimeEntry, err error) {
+	if err := lockUser(tx, userID); err != nil {
+		return nil, nil, err
+	}
+	running, err := RunningTimer(tx, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if running != nil {
+		if running.TodoID == todoID {
+			return nil, nil, ErrTimerRunning
+		}
+		if err := tx.Model(running).Update("ended_at", now).Error; err != nil {
+			return nil, nil, err
+		}
+		stopped = running
+	}
+	entry = &TimeEntry{UserID: userID, TodoID: todoID, StartedAt: now, Source: TimeEntryTimer}
+	if err := tx.Create(entry).Error; err != nil {
+		return nil, nil, err
+	}
+	return entry, stopped, nil
+}
+
+// StopTimer 停止用户在待办事项上正在运行的计时器，应在事务中调用
+func StopTimer(tx *gorm.DB, userID, todoID uint, now time.Time) (*TimeEntry, error) {
+	if err := lockUser(tx, userID); err != nil {
+		return nil, err
+	}
+	running, err := RunningTimer(tx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if running == nil || running.TodoID != todoID {
+		return nil, ErrTimerNotRunning
+	}
+	if err := tx.Model(running).Update("ended_at", now).Error; err != nil {
+		return nil, err
+	}
+	return running, nil
+}
+
+// StopTodoTimers 停止一批待办事项上正在运行的计时器，用于待办事项移入回收站时
+func StopTodoTimers(tx *gorm.DB, todoIDs []uint, now time.Time) error {
+	return tx.Model(&TimeEntry{}).Where("todo_id IN ? AND ended_at IS NULL", todoIDs).Update("ended_at", now).Error
+}
+
+// TodoTimeTotal 统计待办事项的累计时长 (秒)，正在运行的计时到 now
+func TodoTimeTotal(db *gorm.DB, todoID uint, now time.Time) (int64, error) {
+	var total int64
+	err := db.Model(&TimeEntry{}).Where("todo_id = ?", todoID).
+		Select("COALESCE(SUM(TIMESTAMPDIFF(SECOND, started_at, COALESCE(ended_at, ?))), 0)", now).
+		Scan(&total).Error
+	return total, err
+}
+
+// DayTotal 一天的累计时长
+type DayTotal struct {
+	Date    string `json:"date"` // YYYY-MM-DD，按用户时区
+	Seconds int64  `json:"seconds"`
+}
+
+// DailyTotals 将时间记录按 loc 中的自然日切分并累计，返回 [from, to] 每一天的合计 (没有记录的天为 0)
+// from 和 to 为 loc 中某天的零点；跨越午夜的记录分别计入两天
+func DailyTotals(entries []TimeEntry, now time.Time, loc *time.Location, from, to time.Time) []DayTotal {
+	var days []DayTotal
+	index := map[string]int{}
+	for d := from; !d.After(to); d = d.AddDate(0, 0, 1) {
+		key := d.Format("2006-01-02")
+		index[key] = len(days)
+		days = append(days, DayTotal{Date: key})
+	}
+	for i := range entries {
+		start := entries[i].StartedAt.In(loc)
+		end := start.Add(entries[i].Duration(now))
+		for start.Before(end) {
+			y, m, d := start.Date()
+			next := time.Date(y, m, d+1, 0, 0, 0, 0, loc)
+			segEnd := end
+			if next.Before(segEnd) {
+				segEnd = next
+			}
+			if j, ok := index[start.Format("2006-01-02")]; ok {
+				days[j].Seconds += int64(segEnd.Sub(start) / time.Second)
+			}
+			start = segEnd
+		}
+	}
+	return days
+}
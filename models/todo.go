@@ -14,13 +14,56 @@ import (
 
 // Todo 表示一个待办事项
 type Todo struct {
-	ID          uint      `json:"id" gorm:"primaryKey"`
-	UserID      uint      `json:"user_id" gorm:"not null;index"`
-	Title       string    `json:"title" gorm:"not null"`
-	Description string    `json:"description"`
-	Completed   bool      `json:"completed" gorm:"default:false"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
+	ID          uint   `json:"id" gorm:"primaryKey"`
+	UserID      uint   `json:"user_id" gorm:"not null;index;index:idx_todos_user_due,priority:1;index:idx_todos_user_score,priority:1;index:idx_todos_user_position,priority:1"`
+	Title       string `json:"title" gorm:"not null"`
+	Description string `json:"description"`
+	Completed   bool   `json:"completed" gorm:"default:false"`
+	// 完成时间，未完成时为空；自动归档按它计算已完成的天数
+	CompletedAt *time.Time `json:"completed_at"`
+	// 归档时间，不为空表示已归档；默认列表不包含已归档的待办事项
+	ArchivedAt *time.Time `json:"archived_at" gorm:"index"`
+	// 截止时间，为空表示没有截止时间
+	// 全天待办只有日期有意义，统一存储为该日期的 UTC 零点，按用户时区解释
+	DueAt  *time.Time `json:"due_at" gorm:"index:idx_todos_user_due,priority:2"`
+	AllDay bool       `json:"all_day" gorm:"not null;default:false"`
+	// 优先级，JSON 中为 none/low/medium/high/urgent
+	Priority Priority `json:"priority" gorm:"type:tinyint;not null;default:0"`
+	// 智能排序分数，由优先级、截止时间和创建时间计算，见 SmartScore
+	Score int64 `json:"-" gorm:"not null;default:0;index:idx_todos_user_score,priority:2"`
+	// 所属项目，为空表示不属于任何项目 (收件箱)
+	ProjectID *uint `json:"project_id" gorm:"index"`
+	// 父任务，为空表示顶层任务；子任务可以任意层级嵌套
+	ParentID *uint `json:"parent_id" gorm:"index"`
+	// 手动排序键 (base62 分数索引，见 position 包)，在同一清单 (project_id 和 parent_id 相同) 内按字节比较
+	Position string `json:"position" gorm:"type:varchar(255) CHARACTER SET ascii COLLATE ascii_bin;not null;default:'';index:idx_todos_user_position,priority:2"`
+	// 预估耗时 (分钟)，为空表示未预估；与时间记录对比见 EstimateReport
+	EstimateMinutes *int `json:"estimate_minutes"`
+	// 所属工作区，不同工作区的待办事项相互隔离，见 Workspace
+	WorkspaceID uint `json:"workspace_id" gorm:"not null;default:0;index"`
+	// 子任务，仅在请求 include=children 或子任务树接口中填充，不对应数据库列
+	Children []Todo `json:"children,omitempty" gorm:"-"`
+	// 评论数，仅在获取单个待办事项时填充，不对应数据库列
+	CommentCount *int64 `json:"comment_count,omitempty" gorm:"-"`
+	// 依赖状态，仅在列表和详情中填充，见 FillDependencyFlags：
+	// blocked 表示还有未完成的前置任务，actionable 表示未完成且没有被阻塞
+	Blocked    *bool `json:"blocked,omitempty" gorm:"-"`
+	Actionable *bool `json:"actionable,omitempty" gorm:"-"`
+	// 自定义字段的值，键为字段ID，见 CustomField；在列表和详情中填充，不对应数据库列
+	CustomFields map[string]interface{} `json:"custom_fields,omitempty" gorm:"-"`
+	// 所属的重复系列，为空表示不重复；OccurrenceAt 是该实例原定的发生时间，
+	// 单独修改本次的截止时间不影响后续实例的计算
+	SeriesID     *uint      `json:"series_id" gorm:"index"`
+	OccurrenceAt *time.Time `json:"occurrence_at"`
+	// 标签，通过 todo_tags 关联表多对多关联
+	Tags []Tag `json:"tags" gorm:"many2many:todo_tags;constraint:OnDelete:CASCADE"`
+	// 标题的全拼和首字母，用于拼音搜索，不返回给前端
+	TitlePinyin   string    `json:"-" gorm:"type:varchar(1024);not null;default:''"`
+	TitleInitials string    `json:"-" gorm:"type:varchar(255);not null;default:''"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+	// 移入回收站的时间，不为空表示已删除；默认查询会自动排除回收站中的待办事项
+	DeletedAt gorm.DeletedAt `json:"deleted_at" gorm:"index"`
 }
 
 // DB 全局数据库连接
@@ -32,6 +75,17 @@ var Rdb *redis.Client
 // Ctx Redis操作的上下文
 var Ctx = context.Background()
 
+// UserTodosVersionKey 用户列表缓存版本号的Key
+// 列表按页缓存，递增版本号即可使该用户所有分页缓存失效，后台任务修改数据后也通过它失效缓存
+func UserTodosVersionKey(userID uint) string {
+	return fmt.Sprintf("user:%d:todos:ver", userID)
+}
+
+// TodoCacheKey 单个待办事项的缓存Key
+func TodoCacheKey(todoID uint) string {
+	return fmt.Sprintf("todo:%d", todoID)
+}
+
 // InitDB 初始化数据库和Redis连接
 func InitDB() error {
 	var err error
@@ -50,10 +104,13 @@ func InitDB() error {
 	}
 
 	// 自动迁移数据库表结构
-	err = DB.AutoMigrate(&Todo{}, &User{})
+	err = DB.AutoMigrate(&Todo{}, &User{}, &TodoSearchTerm{}, &Tag{}, &Project{}, &RecurringSeries{}, &HolidayCalendar{}, &Reminder{}, &TodoRevision{}, &Comment{}, &Blob{}, &Attachment{}, &TodoDependency{}, &TimeEntry{}, &CustomField{}, &CustomFieldValue{}, &ProjectMember{}, &Workspace{}, &WorkspaceMember{})
 	if err != nil {
 		return fmt.Errorf("数据库迁移失败: %w", err)
 	}
+	if err = EnsurePersonalWorkspaces(); err != nil {
+		return fmt.Errorf("初始化个人工作区失败: %w", err)
+	}
 
 	// 初始化Redis连接
 	redisAddr := getEnvOrDefault("REDIS_ADDR", "localhost:6379")
@@ -89,3 +146,21 @@ func getEnvOrDefault(key, defaultValue string) string {
 	}
 	return value
 }
+
+// BeforeCreate 创建待办事项前填充拼音列、智能排序分数和完成时间
+func (t *Todo) BeforeCreate(tx *gorm.DB) error {
+	cols := TitlePinyinColumns(t.Title)
+	t.TitlePinyin = cols["title_pinyin"].(string)
+	t.TitleInitials = cols["title_initials"].(string)
+
+	// 分数依赖创建时间，这里提前设置，GORM 不会覆盖非零的 CreatedAt
+	if t.CreatedAt.IsZero() {
+		t.CreatedAt = time.Now()
+	}
+	t.Score = t.SmartScore()
+	if t.Completed && t.CompletedAt == nil {
+		completedAt := t.CreatedAt
+		t.CompletedAt = &completedAt
+	}
+	return nil
+}
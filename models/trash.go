@@ -0,0 +1,201 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// DefaultTrashRetentionDays 回收站中的待办事项默认保留的天数，超过后被永久删除
+const DefaultTrashRetentionDays = 30
+
+// 每次永久删除的最大条数，避免单个事务过大
+const purgeBatchSize = 500
+
+// ErrNotInTrash 待办事项不存在或不在回收站中
+var ErrNotInTrash = errors.New("待办事项不在回收站中")
+
+// TrashRetentionDays 返回回收站的保留天数，通过环境变量 TRASH_RETENTION_DAYS 配置
+func TrashRetentionDays() int {
+	raw := getEnvOrDefault("TRASH_RETENTION_DAYS", strconv.Itoa(DefaultTrashRetentionDays))
+	days, err := strconv.Atoi(raw)
+	if err != nil || days <= 0 {
+		fmt.Printf("TRASH_RETENTION_DAYS 无效: %q，使用默认值 %d\n", raw, DefaultTrashRetentionDays)
+		return DefaultTrashRetentionDays
+	}
+	return days
+}
+
+// RestoreTodo 从回收站恢复待办事项，以及与它同一批删除的子孙任务
+// 原父任务已不存在 (被删除或已永久删除) 时恢复为顶层任务，原项目已删除时移回收件箱
+// 只能恢复 workspaceID 工作区中的待办事项，返回恢复的待办事项，调用方应在之后重新安排它们的提醒并清除缓存
+func RestoreTodo(userID, workspaceID, id uint) ([]Todo, error) {
+	var restored []Todo
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		var root Todo
+		err := tx.Unscoped().Where("id = ? AND user_id = ? AND workspace_id = ? AND deleted_at IS NOT NULL", id, userID, workspaceID).First(&root).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrNotInTrash
+		} else if err != nil {
+			return err
+		}
+		descendants, err := LoadDescendants(tx.Unscoped().Where("deleted_at = ?", root.DeletedAt.Time), userID, root.ID)
+		if err != nil {
+			return err
+		}
+		restored = append([]Todo{root}, descendants...)
+		ids := TodoIDs(restored)
+		before, err := SnapshotTodos(tx, ids)
+		if err != nil {
+			return err
+		}
+
+		if err := tx.Unscoped().Model(&Todo{}).Where("id IN ?", ids).Update("deleted_at", nil).Error; err != nil {
+			return err
+		}
+		if root.ParentID != nil {
+			var count int64
+			if err := tx.Model(&Todo{}).Where("id = ? AND user_id = ?", *root.ParentID, userID).Count(&count).Error; err != nil {
+				return err
+			}
+			if count == 0 {
+				if err := tx.Model(&root).Update("parent_id", nil).Error; err != nil {
+					return err
+				}
+				restored[0].ParentID = nil
+			}
+		}
+		if err := detachMissingProjects(tx, userID, restored); err != nil {
+			return err
+		}
+
+		for i := range restored {
+			restored[i].DeletedAt = gorm.DeletedAt{}
+			if err := IndexTodo(tx, &restored[i]); err != nil {
+				return err
+			}
+		}
+		return RecordRevisions(tx, userID, RevisionRestore, before)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return restored, nil
+}
+
+// detachMissingProjects 将所属项目已被删除的待办事项移回收件箱
+func detachMissingProjects(tx *gorm.DB, userID uint, todos []Todo) error {
+	var projectIDs []uint
+	for _, t := range todos {
+		if t.ProjectID != nil {
+			projectIDs = append(projectIDs, *t.ProjectID)
+		}
+	}
+	if len(projectIDs) == 0 {
+		return nil
+	}
+	var existing []uint
+	if err := tx.Model(&Project{}).Where("id IN ? AND user_id = ?", projectIDs, userID).Pluck("id", &existing).Error; err != nil {
+		return err
+	}
+	alive := make(map[uint]bool, len(existing))
+	for _, id := range existing {
+		alive[id] = true
+	}
+	for i := range todos {
+		t := &todos[i]
+		if t.ProjectID == nil || alive[*t.ProjectID] {
+			continue
+		}
+		if err := tx.Model(&Todo{}).Where("id = ?", t.ID).Update("project_id", nil).Error; err != nil {
+			return err
+		}
+		t.ProjectID = nil
+	}
+	return nil
+}
+
+// PurgeTodos 永久删除一批待办事项及其检索索引、提醒、修订记录、评论、附件、依赖、时间记录和自定义字段值，应在事务中调用
+// 重复系列的实例 (包括回收站中的) 全部删除后，系列本身也一并删除
+func PurgeTodos(tx *gorm.DB, ids []uint) error {
+	var seriesIDs []uint
+	if err := tx.Unscoped().Model(&Todo{}).Where("id IN ? AND series_id IS NOT NULL", ids).Distinct().Pluck("series_id", &seriesIDs).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("todo_id IN ?", ids).Delete(&TodoSearchTerm{}).Error; err != nil {
+		return err
+	}
+	// 队列中残留的提醒在触发时找不到记录会被忽略
+	if err := tx.Where("todo_id IN ?", ids).Delete(&Reminder{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("todo_id IN ?", ids).Delete(&TodoRevision{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("todo_id IN ?", ids).Delete(&Comment{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("todo_id IN ? OR blocker_id IN ?", ids, ids).Delete(&TodoDependency{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("todo_id IN ?", ids).Delete(&TimeEntry{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("todo_id IN ?", ids).Delete(&CustomFieldValue{}).Error; err != nil {
+		return err
+	}
+	// 附件内容可能被其他附件共用，没有引用后由后台任务清理
+	if err := tx.Where("todo_id IN ?", ids).Delete(&Attachment{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Unscoped().Where("id IN ?", ids).Delete(&Todo{}).Error; err != nil {
+		return err
+	}
+	if len(seriesIDs) == 0 {
+		return nil
+	}
+	return tx.Where("id IN ? AND NOT EXISTS (SELECT 1 FROM todos WHERE todos.series_id = recurring_series.id)", seriesIDs).
+		Delete(&RecurringSeries{}).Error
+}
+
+// PurgeTrash 分批永久删除回收站中满足 scope 条件的待办事项，返回删除的条数
+func PurgeTrash(scope func(*gorm.DB) *gorm.DB) (int, error) {
+	total := 0
+	for {
+		var ids []uint
+		err := scope(DB.Unscoped().Model(&Todo{}).Where("deleted_at IS NOT NULL")).
+			Order("id ASC").Limit(purgeBatchSize).Pluck("id", &ids).Error
+		if err != nil {
+			return total, err
+		}
+		if len(ids) == 0 {
+			return total, nil
+		}
+		if err := DB.Transaction(func(tx *gorm.DB) error {
+			return PurgeTodos(tx, ids)
+		}); err != nil {
+			return total, err
+		}
+		total += len(ids)
+		if len(ids) < purgeBatchSize {
+			return total, nil
+		}
+	}
+}
+
+// EmptyTrash 清空用户在工作区中的回收站
+func EmptyTrash(userID, workspaceID uint) (int, error) {
+	return PurgeTrash(func(db *gorm.DB) *gorm.DB {
+		return db.Where("user_id = ? AND workspace_id = ?", userID, workspaceID)
+	})
+}
+
+// PurgeExpiredTrash 永久删除在 before 之前移入回收站的待办事项
+func PurgeExpiredTrash(before time.Time) (int, error) {
+	return PurgeTrash(func(db *gorm.DB) *gorm.DB {
+		return db.Where("deleted_at < ?", before)
+	})
+}
@@ -2,15 +2,43 @@ package models
 
 import (
 	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // User 表示用户模型
 type User struct {
 	ID        uint      `json:"id" gorm:"primaryKey"`
 	Username  string    `json:"username" gorm:"type:varchar(255);uniqueIndex;not null"`
-	Password  string    `json:"-" gorm:"type:varchar(255);not null"` // 密码不返回给前端
+	Password  string    `json:"-" gorm:"type:varchar(255);not null"`                               // 密码不返回给前端
+	Timezone  string    `json:"timezone" gorm:"type:varchar(64);not null;default:'Asia/Shanghai'"` // IANA 时区，用于计算今天/逾期等视图
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
+
+	// 已完成的待办事项在完成多少天后自动归档，0 表示不自动归档
+	AutoArchiveDays int `json:"auto_archive_days" gorm:"not null;default:0"`
+}
+
+// lockUser 在事务中锁定用户行，用于串行化同一用户需要先检查再写入的操作
+func lockUser(tx *gorm.DB, userID uint) error {
+	var user User
+	return tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").First(&user, userID).Error
+}
+
+// DefaultTimezone 未设置时区的用户使用的默认时区
+const DefaultTimezone = "Asia/Shanghai"
+
+// Location 返回用户所在时区，时区无效时回退到默认时区
+func (u *User) Location() *time.Location {
+	if loc, err := time.LoadLocation(u.Timezone); err == nil && u.Timezone != "" {
+		return loc
+	}
+	loc, err := time.LoadLocation(DefaultTimezone)
+	if err != nil {
+		return time.Local
+	}
+	return loc
 }
 
 // LoginRequest 登录请求结构
@@ -0,0 +1,177 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 工作区成员的角色：admin 可以修改工作区和管理成员，member 只能使用工作区
+const (
+	WorkspaceRoleAdmin  = "admin"
+	WorkspaceRoleMember = "member"
+)
+
+// PersonalWorkspaceName 个人工作区的默认名称
+const PersonalWorkspaceName = "个人"
+
+// ErrWorkspaceNotEmpty 工作区中还有待办事项或项目，不能删除
+var ErrWorkspaceNotEmpty = errors.New("工作区中还有待办事项或项目，不能删除")
+
+// Workspace 工作区 (团队)，待办事项和项目都属于某个工作区，不同工作区之间的数据相互隔离
+// 每个用户有一个只属于自己的个人工作区，注册时自动创建，不能添加其他成员，也不能删除
+type Workspace struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	Name      string    `json:"name" gorm:"type:varchar(128);not null"`
+	Personal  bool      `json:"personal" gorm:"not null;default:false"`
+	CreatedBy uint      `json:"created_by" gorm:"not null;index"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	// Role 当前用户在工作区中的角色，只在响应中返回
+	Role string `json:"role,omitempty" gorm:"-"`
+}
+
+// WorkspaceMember 工作区成员，用户可以属于多个工作区
+type WorkspaceMember struct {
+	WorkspaceID uint      `json:"workspace_id" gorm:"primaryKey"`
+	UserID      uint      `json:"user_id" gorm:"primaryKey;index"`
+	Role        string    `json:"role" gorm:"type:varchar(16);not null"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// ValidWorkspaceRole 是否为支持的工作区角色
+func ValidWorkspaceRole(role string) bool {
+	return role == WorkspaceRoleAdmin || role == WorkspaceRoleMember
+}
+
+// CreateWorkspace 创建工作区，创建者成为 admin，应在事务中调用
+func CreateWorkspace(tx *gorm.DB, name string, creatorID uint, personal bool) (*Workspace, error) {
+	ws := Workspace{Name: name, Personal: personal, CreatedBy: creatorID}
+	if err := tx.Create(&ws).Error; err != nil {
+		return nil, err
+	}
+	member := WorkspaceMember{WorkspaceID: ws.ID, UserID: creatorID, Role: WorkspaceRoleAdmin}
+	if err := tx.Create(&member).Error; err != nil {
+		return nil, err
+	}
+	ws.Role = WorkspaceRoleAdmin
+	return &ws, nil
+}
+
+// PersonalWorkspaceID 用户的个人工作区ID，请求没有指定工作区时使用
+func PersonalWorkspaceID(db *gorm.DB, userID uint) (uint, error) {
+	var ws Workspace
+	err := db.Select("id").Where("created_by = ? AND personal = ?", userID, true).Order("id ASC").First(&ws).Error
+	return ws.ID, err
+}
+
+// WorkspaceRole 用户在工作区中的角色，不是成员时返回空字符串
+func WorkspaceRole(db *gorm.DB, userID, workspaceID uint) (string, error) {
+	var members []WorkspaceMember
+	if err := db.Where("workspace_id = ? AND user_id = ?", workspaceID, userID).Limit(1).Find(&members).Error; err != nil {
+		return "", err
+	}
+	if len(members) == 0 {
+		return "", nil
+	}
+	return members[0].Role, nil
+}
+
+// WorkspaceTodoIDs 工作区中所有待办事项 (包括回收站中的) 的ID子查询，
+// 用于将提醒、时间记录等按用户存储的数据限定在工作区内
+func WorkspaceTodoIDs(workspaceID uint) *gorm.DB {
+	return DB.Unscoped().Model(&Todo{}).Select("id").Where("workspace_id = ?", workspaceID)
+}
+
+// RemoveWorkspaceMember 将用户移出工作区，他在该工作区项目中的共享成员身份一并移除，应在事务中调用
+// 他自己创建的待办事项和项目保留在工作区中
+func RemoveWorkspaceMember(tx *gorm.DB, workspaceID, userID uint) error {
+	projectIDs := tx.Model(&Project{}).Select("id").Where("workspace_id = ?", workspaceID)
+	if err := tx.Where("user_id = ? AND project_id IN (?)", userID, projectIDs).Delete(&ProjectMember{}).Error; err != nil {
+		return err
+	}
+	return tx.Where("workspace_id = ? AND user_id = ?", workspaceID, userID).Delete(&WorkspaceMember{}).Error
+}
+
+// DeleteWorkspace 删除没有数据的工作区及其成员关系，回收站中的待办事项同样算作数据
+func DeleteWorkspace(ws *Workspace) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		// 锁定工作区，避免检查之后又有数据写入
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").First(&Workspace{}, ws.ID).Error; err != nil {
+			return err
+		}
+		var todos, projects int64
+		if err := tx.Unscoped().Model(&Todo{}).Where("workspace_id = ?", ws.ID).Count(&todos).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&Project{}).Where("workspace_id = ?", ws.ID).Count(&projects).Error; err != nil {
+			return err
+		}
+		if todos > 0 || projects > 0 {
+			return ErrWorkspaceNotEmpty
+		}
+		if err := tx.Where("workspace_id = ?", ws.ID).Delete(&WorkspaceMember{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(ws).Error
+	})
+}
+
+// legacyOwnerIndexes 标签和自定义字段按工作区区分之前按用户唯一的索引，
+// 名称不变时 AutoMigrate 不会修改已有索引，因此改名并删除旧索引
+var legacyOwnerIndexes = []struct {
+	model interface{}
+	name  string
+}{
+	{&Tag{}, "idx_tags_user_name"},
+	{&CustomField{}, "idx_custom_fields_user_name"},
+}
+
+// EnsurePersonalWorkspaces 为还没有个人工作区的用户 (引入工作区之前注册的) 创建个人工作区，
+// 并将他们未归属工作区的待办事项 (包括回收站中的)、项目、标签和自定义字段移入其中，不改变更新时间；启动时调用
+func EnsurePersonalWorkspaces() error {
+	for _, idx := range legacyOwnerIndexes {
+		if DB.Migrator().HasIndex(idx.model, idx.name) {
+			if err := DB.Migrator().DropIndex(idx.model, idx.name); err != nil {
+				return fmt.Errorf("删除旧索引 %s 失败: %w", idx.name, err)
+			}
+		}
+	}
+
+	var userIDs []uint
+	personal := DB.Model(&Workspace{}).Select("created_by").Where("personal = ?", true)
+	if err := DB.Model(&User{}).Where("id NOT IN (?)", personal).Pluck("id", &userIDs).Error; err != nil {
+		return err
+	}
+	for _, userID := range userIDs {
+		err := DB.Transaction(func(tx *gorm.DB) error {
+			ws, err := CreateWorkspace(tx, PersonalWorkspaceName, userID, true)
+			if err != nil {
+				return err
+			}
+			if err := tx.Unscoped().Model(&Todo{}).Where("user_id = ? AND workspace_id = 0", userID).UpdateColumn("workspace_id", ws.ID).Error; err != nil {
+				return err
+			}
+			return tx.Model(&Project{}).Where("user_id = ? AND workspace_id = 0", userID).UpdateColumn("workspace_id", ws.ID).Error
+		})
+		if err != nil {
+			return fmt.Errorf("为用户 %d 创建个人工作区失败: %w", userID, err)
+		}
+	}
+
+	// 标签和自定义字段晚于工作区按工作区区分，已有个人工作区的用户同样需要归入
+	personalOf := func(table string) clause.Expr {
+		return gorm.Expr("(SELECT workspaces.id FROM workspaces WHERE workspaces.created_by = "+table+".user_id AND workspaces.personal = ? ORDER BY workspaces.id LIMIT 1)", true)
+	}
+	if err := DB.Model(&Tag{}).Where("workspace_id = 0").UpdateColumn("workspace_id", personalOf("tags")).Error; err != nil {
+		return fmt.Errorf("标签归入个人工作区失败: %w", err)
+	}
+	if err := DB.Model(&CustomField{}).Where("workspace_id = 0").UpdateColumn("workspace_id", personalOf("custom_fields")).Error; err != nil {
+		return fmt.Errorf("自定义字段归入个人工作区失败: %w", err)
+	}
+	return nil
+}
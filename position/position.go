@@ -0,0 +1,116 @@
+// Package position 生成用于手动排序的分数索引 (fractional indexing) 键
+//
+// 键是 base62 数字串，表示 (0, 1) 区间内的小数 0.d1d2d3...，按字节比较的顺序与数值顺序一致。
+// 任意两个键之间总能生成一个新键，因此移动一个元素只需修改它自己的键；
+// 反复在同一位置插入会使键变长，需要定期用 Spread 重新分配。
+// 生成的键不以 '0' 结尾，保证任意键之前也总有空间。
+package position
+
+import (
+	"errors"
+	"strings"
+)
+
+// digits base62 数字，按 ASCII 顺序排列
+const digits = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+const base = len(digits)
+
+// ErrInvalidKey 键包含非 base62 字符或以 '0' 结尾
+var ErrInvalidKey = errors.New("无效的排序键")
+
+// ErrOutOfOrder Between 的两个键不满足 a < b
+var ErrOutOfOrder = errors.New("排序键顺序错误")
+
+// Valid 判断 s 是否为有效的非空键
+func Valid(s string) bool {
+	if s == "" || s[len(s)-1] == '0' {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if strings.IndexByte(digits, s[i]) < 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Between 返回严格位于 a 和 b 之间的键，a 为空表示列表开头，b 为空表示列表末尾
+func Between(a, b string) (string, error) {
+	if (a != "" && !Valid(a)) || (b != "" && !Valid(b)) {
+		return "", ErrInvalidKey
+	}
+	if a != "" && b != "" && a >= b {
+		return "", ErrOutOfOrder
+	}
+	return midpoint(a, b), nil
+}
+
+// midpoint 计算 a 与 b 之间的键，b 为空表示 1
+func midpoint(a, b string) string {
+	if b != "" {
+		// 跳过公共前缀 (a 较短时用 '0' 补齐)
+		n := 0
+		for n < len(b) && digitAt(a, n) == strings.IndexByte(digits, b[n]) {
+			n++
+		}
+		if n > 0 {
+			rest := ""
+			if n < len(a) {
+				rest = a[n:]
+			}
+			return b[:n] + midpoint(rest, b[n:])
+		}
+	}
+	da := digitAt(a, 0)
+	db := base
+	if b != "" {
+		db = strings.IndexByte(digits, b[0])
+	}
+	if db-da > 1 {
+		return string(digits[(da+db)/2])
+	}
+	// 首位相邻：b 的首位本身已大于 a，只要 b 还有后续数字就可以直接取它
+	if len(b) > 1 {
+		return b[:1]
+	}
+	rest := ""
+	if len(a) > 1 {
+		rest = a[1:]
+	}
+	return string(digits[da]) + midpoint(rest, "")
+}
+
+// digitAt 返回 s 第 i 位的数值，超出长度时为 0
+func digitAt(s string, i int) int {
+	if i >= len(s) {
+		return 0
+	}
+	return strings.IndexByte(digits, s[i])
+}
+
+// Spread 生成 n 个等间距的递增键，用于重新分配一个列表的全部键
+func Spread(n int) []string {
+	if n <= 0 {
+		return nil
+	}
+	// 选择足够的位数，使相邻键之间至少相隔一个单位
+	width := 1
+	capacity := uint64(base)
+	for capacity <= uint64(n) {
+		width++
+		capacity *= uint64(base)
+	}
+	step := capacity / uint64(n+1)
+	keys := make([]string, n)
+	buf := make([]byte, width)
+	for i := range keys {
+		v := step * uint64(i+1)
+		for j := width - 1; j >= 0; j-- {
+			buf[j] = digits[v%uint64(base)]
+			v /= uint64(base)
+		}
+		keys[i] = strings.TrimRight(string(buf), "0")
+	}
+	return keys
+}
@@ -0,0 +1,174 @@
+package recurrence
+
+import (
+	"errors"
+	"sort"
+	"time"
+
+	"todolist/lunar"
+)
+
+// ScaleChinese RSCALE=CHINESE，按农历展开 BYMONTH/BYMONTHDAY
+const ScaleChinese = "CHINESE"
+
+// Skip 农历日期不存在时 (如闰月当年没有、小月没有三十) 的处理方式，见 RFC 7529
+type Skip int
+
+const (
+	SkipOmit     Skip = iota // 跳过这一次
+	SkipBackward             // 提前到之前最近的日期：闰月改为同号的普通月，三十改为月末
+	SkipForward              // 推后到之后最近的日期：闰月改为下一个月，三十改为下月初一
+)
+
+var skipNames = map[string]Skip{
+	"OMIT":     SkipOmit,
+	"BACKWARD": SkipBackward,
+	"FORWARD":  SkipForward,
+}
+
+func (s Skip) String() string {
+	for name, v := range skipNames {
+		if v == s {
+			return name
+		}
+	}
+	return ""
+}
+
+// validateScale 校验与历法相关的属性组合
+func (r *Rule) validateScale() error {
+	if r.RScale == "" {
+		if len(r.ByLeapMonth) > 0 {
+			return errors.New("闰月 (如 BYMONTH=8L) 只能用于 RSCALE=CHINESE")
+		}
+		if r.Skip != SkipOmit {
+			return errors.New("SKIP 只能与 RSCALE 一起使用")
+		}
+		return nil
+	}
+	if r.Freq != Monthly && r.Freq != Yearly {
+		return errors.New("农历重复只支持 FREQ=MONTHLY 或 YEARLY")
+	}
+	if len(r.ByDay) > 0 {
+		return errors.New("农历重复不支持 BYDAY")
+	}
+	for _, md := range r.ByMonthDay {
+		if md > 30 || md < -30 {
+			return errors.New("农历 BYMONTHDAY 必须在 -30 到 30 之间")
+		}
+	}
+	return nil
+}
+
+// expandLunarPeriod 返回农历第 n 个周期 (农历月或农历年) 内的发生时间
+func (r *Rule) expandLunarPeriod(start time.Time, n int, at func(int, time.Month, int) time.Time) []time.Time {
+	s, err := lunar.FromSolar(start)
+	if err != nil {
+		return nil
+	}
+	monthDays := r.ByMonthDay
+	if len(monthDays) == 0 {
+		monthDays = []int{s.Day}
+	}
+
+	var days []time.Time
+	add := func(year, month int, leap bool) {
+		for _, md := range monthDays {
+			if d, ok := r.resolveLunar(year, month, leap, md); ok {
+				days = append(days, at(d.Year(), d.Month(), d.Day()))
+			}
+		}
+	}
+
+	switch r.Freq {
+	case Monthly:
+		// 按农历月推算，闰月也算一个月
+		m, ok := lunar.MonthAt(lunar.MonthIndex(s.Year, s.Month, s.Leap) + n*r.Interval)
+		if ok && r.matchLunarMonth(m.Month, m.Leap) {
+			add(m.Year, m.Month, m.Leap)
+		}
+	case Yearly:
+		year := s.Year + n*r.Interval
+		if len(r.ByMonth) == 0 && len(r.ByLeapMonth) == 0 {
+			add(year, s.Month, s.Leap)
+			break
+		}
+		for _, m := range r.ByMonth {
+			add(year, m, false)
+		}
+		for _, m := range r.ByLeapMonth {
+			add(year, m, true)
+		}
+	}
+
+	sort.Slice(days, func(i, j int) bool { return days[i].Before(days[j]) })
+	return dedupeTimes(days)
+}
+
+// matchLunarMonth 判断农历月份是否符合 BYMONTH，普通月份不匹配同号闰月，反之亦然
+func (r *Rule) matchLunarMonth(month int, leap bool) bool {
+	if len(r.ByMonth) == 0 && len(r.ByLeapMonth) == 0 {
+		return true
+	}
+	list := r.ByMonth
+	if leap {
+		list = r.ByLeapMonth
+	}
+	for _, m := range list {
+		if m == month {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveLunar 将农历年月日转换为公历日期，日期不存在时按 SKIP 处理
+// monthDay 为负数时从月末倒数，-1 表示月末 (二十九或三十)
+func (r *Rule) resolveLunar(year, month int, leap bool, monthDay int) (time.Time, bool) {
+	if leap && lunar.LeapMonth(year) != month {
+		switch r.Skip {
+		case SkipBackward:
+			leap = false
+		case SkipForward:
+			leap = false
+			if month++; month > 12 {
+				year, month = year+1, 1
+			}
+		default:
+			return time.Time{}, false
+		}
+	}
+
+	n := lunar.DaysInMonth(year, month, leap)
+	if n == 0 {
+		return time.Time{}, false
+	}
+	day := monthDay
+	if day < 0 {
+		day = n + monthDay + 1
+	}
+	if day < 1 {
+		return time.Time{}, false
+	}
+	forward := false
+	if day > n {
+		switch r.Skip {
+		case SkipBackward:
+			day = n
+		case SkipForward:
+			day, forward = n, true
+		default:
+			return time.Time{}, false
+		}
+	}
+
+	t, err := lunar.ToSolar(lunar.Date{Year: year, Month: month, Day: day, Leap: leap})
+	if err != nil {
+		return time.Time{}, false
+	}
+	if forward {
+		// 下个月初一就是本月最后一天的后一天
+		t = t.AddDate(0, 0, 1)
+	}
+	return t, true
+}
@@ -0,0 +1,647 @@
+// Package recurrence 实现待办事项的重复规则，规则语法为 RFC 5545 RRULE 的一个子集。
+//
+// 支持的部分：
+//
+//	FREQ=DAILY|WEEKLY|MONTHLY|YEARLY
+//	INTERVAL, COUNT, UNTIL
+//	BYDAY (月/年频率下可带序号，如 2MO、-1FR)
+//	BYMONTHDAY (可为负数，-1 表示月末)
+//	BYMONTH, BYSETPOS, WKST
+//
+//	RSCALE=CHINESE 和 SKIP=OMIT|BACKWARD|FORWARD (RFC 7529，农历重复，见 lunar.go)
+//	X-WORKDAY=ONLY|BACKWARD|FORWARD (扩展，按工作日历调整，见 workday.go)
+//
+// 不支持按小时/分钟/秒、BYYEARDAY 和 BYWEEKNO，发生时间的时刻总是与起始时间相同。
+// 规则的展开不访问数据库，结果只取决于规则、起始时间和调用方设置的工作日历 (Rule.Calendar)。
+// 不使用 X-WORKDAY 的规则结果是确定的；使用 X-WORKDAY 时，工作日历更新 (如管理员上传节假日安排) 后
+// 同一规则的展开结果可能不同，需要固定结果的调用方应传入不会变化的日历。
+package recurrence
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Frequency 重复频率
+type Frequency int
+
+const (
+	Daily Frequency = iota
+	Weekly
+	Monthly
+	Yearly
+)
+
+var freqNames = map[string]Frequency{
+	"DAILY":   Daily,
+	"WEEKLY":  Weekly,
+	"MONTHLY": Monthly,
+	"YEARLY":  Yearly,
+}
+
+func (f Frequency) String() string {
+	for name, v := range freqNames {
+		if v == f {
+			return name
+		}
+	}
+	return ""
+}
+
+var weekdayNames = []string{"SU", "MO", "TU", "WE", "TH", "FR", "SA"}
+
+// WeekdayNum BYDAY 中的一项，N 为 0 表示每个该星期几，正数表示第 N 个，负数表示倒数第 N 个
+type WeekdayNum struct {
+	Weekday time.Weekday
+	N       int
+}
+
+func (w WeekdayNum) String() string {
+	if w.N == 0 {
+		return weekdayNames[w.Weekday]
+	}
+	return strconv.Itoa(w.N) + weekdayNames[w.Weekday]
+}
+
+// Rule 解析后的重复规则
+type Rule struct {
+	Freq       Frequency
+	Interval   int
+	Count      int        // 0 表示不限次数
+	Until      *time.Time // 为 nil 表示不限结束时间
+	UntilDate  bool       // UNTIL 只有日期部分，按起始时间的时区比较日期
+	ByDay      []WeekdayNum
+	ByMonthDay []int
+	ByMonth    []int
+	BySetPos   []int
+	WeekStart  time.Weekday
+	// 以下只用于农历重复
+	RScale      string // 空表示公历，CHINESE 表示农历
+	ByLeapMonth []int  // BYMONTH 中带 L 后缀的闰月，如 8L
+	Skip        Skip   // 日期不存在时的处理方式
+	// X-WORKDAY 扩展，按工作日历调整发生时间，见 workday.go
+	Workday WorkdayMode
+	// 判断工作日使用的日历，不来自规则字符串，由调用方设置；为 nil 时周一至周五为工作日
+	// 展开时会读取日历的当前数据，日历在两次展开之间被更新时结果可能不同
+	Calendar Workdays
+}
+
+// 展开时连续没有产生任何发生时间的周期数上限，超过则认为规则不会再产生发生时间
+const maxEmptyPeriods = 1000
+
+// Parse 解析 RRULE 字符串，可以带 "RRULE:" 前缀
+func Parse(s string) (*Rule, error) {
+	s = strings.TrimSpace(s)
+	s = strings.TrimPrefix(strings.ToUpper(s), "RRULE:")
+	if s == "" {
+		return nil, errors.New("重复规则不能为空")
+	}
+
+	r := &Rule{Interval: 1, WeekStart: time.Monday}
+	hasFreq := false
+	for _, part := range strings.Split(s, ";") {
+		kv := strings.SplitN(part, "=", 2)
+		if len(kv) != 2 || kv[1] == "" {
+			return nil, fmt.Errorf("无效的规则片段: %s", part)
+		}
+		key, value := kv[0], kv[1]
+		var err error
+		switch key {
+		case "FREQ":
+			f, ok := freqNames[value]
+			if !ok {
+				return nil, fmt.Errorf("不支持的 FREQ: %s", value)
+			}
+			r.Freq, hasFreq = f, true
+		case "INTERVAL":
+			r.Interval, err = strconv.Atoi(value)
+			if err != nil || r.Interval < 1 {
+				return nil, errors.New("INTERVAL 必须为正整数")
+			}
+		case "COUNT":
+			r.Count, err = strconv.Atoi(value)
+			if err != nil || r.Count < 1 {
+				return nil, errors.New("COUNT 必须为正整数")
+			}
+		case "UNTIL":
+			t, dateOnly, err := parseUntil(value)
+			if err != nil {
+				return nil, err
+			}
+			r.Until, r.UntilDate = &t, dateOnly
+		case "BYDAY":
+			for _, d := range strings.Split(value, ",") {
+				wd, err := parseWeekdayNum(d)
+				if err != nil {
+					return nil, err
+				}
+				r.ByDay = append(r.ByDay, wd)
+			}
+		case "BYMONTHDAY":
+			if r.ByMonthDay, err = parseIntList(value, -31, 31, "BYMONTHDAY"); err != nil {
+				return nil, err
+			}
+		case "BYMONTH":
+			// 农历中 8L 表示闰八月
+			var regular []string
+			for _, m := range strings.Split(value, ",") {
+				if strings.HasSuffix(m, "L") {
+					n, err := strconv.Atoi(strings.TrimSuffix(m, "L"))
+					if err != nil || n < 1 || n > 12 {
+						return nil, fmt.Errorf("无效的 BYMONTH: %s", m)
+					}
+					r.ByLeapMonth = append(r.ByLeapMonth, n)
+				} else {
+					regular = append(regular, m)
+				}
+			}
+			if len(regular) > 0 {
+				if r.ByMonth, err = parseIntList(strings.Join(regular, ","), 1, 12, "BYMONTH"); err != nil {
+					return nil, err
+				}
+			}
+		case "RSCALE":
+			switch value {
+			case "GREGORIAN":
+				r.RScale = ""
+			case ScaleChinese:
+				r.RScale = ScaleChinese
+			default:
+				return nil, fmt.Errorf("不支持的 RSCALE: %s", value)
+			}
+		case "X-WORKDAY":
+			mode, ok := workdayModeNames[value]
+			if !ok {
+				return nil, fmt.Errorf("无效的 X-WORKDAY: %s", value)
+			}
+			r.Workday = mode
+		case "SKIP":
+			skip, ok := skipNames[value]
+			if !ok {
+				return nil, fmt.Errorf("无效的 SKIP: %s", value)
+			}
+			r.Skip = skip
+		case "BYSETPOS":
+			if r.BySetPos, err = parseIntList(value, -366, 366, "BYSETPOS"); err != nil {
+				return nil, err
+			}
+		case "WKST":
+			wd, err := parseWeekdayNum(value)
+			if err != nil || wd.N != 0 {
+				return nil, fmt.Errorf("无效的 WKST: %s", value)
+			}
+			r.WeekStart = wd.Weekday
+		default:
+			return nil, fmt.Errorf("不支持的规则属性: %s", key)
+		}
+	}
+	if !hasFreq {
+		return nil, errors.New("重复规则必须包含 FREQ")
+	}
+	if r.Count > 0 && r.Until != nil {
+		return nil, errors.New("COUNT 和 UNTIL 不能同时使用")
+	}
+	for _, wd := range r.ByDay {
+		if wd.N != 0 && r.Freq != Monthly && r.Freq != Yearly {
+			return nil, errors.New("带序号的 BYDAY 只能用于 MONTHLY 或 YEARLY")
+		}
+	}
+	if len(r.BySetPos) > 0 && len(r.ByDay) == 0 && len(r.ByMonthDay) == 0 && len(r.ByMonth) == 0 {
+		return nil, errors.New("BYSETPOS 必须与其他 BYxxx 属性一起使用")
+	}
+	if err := r.validateScale(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// parseUntil 解析 UNTIL，支持 YYYYMMDD 和 YYYYMMDDTHHMMSSZ
+func parseUntil(value string) (time.Time, bool, error) {
+	if t, err := time.Parse("20060102T150405Z", value); err == nil {
+		return t, false, nil
+	}
+	if t, err := time.Parse("20060102", value); err == nil {
+		return t, true, nil
+	}
+	return time.Time{}, false, fmt.Errorf("无效的 UNTIL: %s", value)
+}
+
+// parseWeekdayNum 解析 BYDAY 中的一项，如 MO、2TU、-1FR
+func parseWeekdayNum(s string) (WeekdayNum, error) {
+	if len(s) < 2 {
+		return WeekdayNum{}, fmt.Errorf("无效的星期: %s", s)
+	}
+	name := s[len(s)-2:]
+	wd := -1
+	for i, n := range weekdayNames {
+		if n == name {
+			wd = i
+		}
+	}
+	if wd < 0 {
+		return WeekdayNum{}, fmt.Errorf("无效的星期: %s", s)
+	}
+	n := 0
+	if prefix := s[:len(s)-2]; prefix != "" {
+		var err error
+		n, err = strconv.Atoi(prefix)
+		if err != nil || n == 0 || n < -53 || n > 53 {
+			return WeekdayNum{}, fmt.Errorf("无效的星期序号: %s", s)
+		}
+	}
+	return WeekdayNum{Weekday: time.Weekday(wd), N: n}, nil
+}
+
+// parseIntList 解析逗号分隔的整数列表，0 和超出范围的值无效
+func parseIntList(value string, min, max int, name string) ([]int, error) {
+	var out []int
+	for _, p := range strings.Split(value, ",") {
+		n, err := strconv.Atoi(p)
+		if err != nil || n == 0 || n < min || n > max {
+			return nil, fmt.Errorf("无效的 %s: %s", name, p)
+		}
+		out = append(out, n)
+	}
+	return out, nil
+}
+
+// String 返回规范化的 RRULE 字符串 (不带 "RRULE:" 前缀)
+func (r *Rule) String() string {
+	var parts []string
+	if r.RScale != "" {
+		parts = append(parts, "RSCALE="+r.RScale)
+	}
+	parts = append(parts, "FREQ="+r.Freq.String())
+	if r.Interval > 1 {
+		parts = append(parts, "INTERVAL="+strconv.Itoa(r.Interval))
+	}
+	if r.Count > 0 {
+		parts = append(parts, "COUNT="+strconv.Itoa(r.Count))
+	}
+	if r.Until != nil && r.UntilDate {
+		parts = append(parts, "UNTIL="+r.Until.Format("20060102"))
+	} else if r.Until != nil {
+		parts = append(parts, "UNTIL="+r.Until.UTC().Format("20060102T150405Z"))
+	}
+	if len(r.ByMonth) > 0 || len(r.ByLeapMonth) > 0 {
+		months := make([]string, 0, len(r.ByMonth)+len(r.ByLeapMonth))
+		for _, m := range r.ByMonth {
+			months = append(months, strconv.Itoa(m))
+		}
+		for _, m := range r.ByLeapMonth {
+			months = append(months, strconv.Itoa(m)+"L")
+		}
+		parts = append(parts, "BYMONTH="+strings.Join(months, ","))
+	}
+	if len(r.ByMonthDay) > 0 {
+		parts = append(parts, "BYMONTHDAY="+joinInts(r.ByMonthDay))
+	}
+	if len(r.ByDay) > 0 {
+		days := make([]string, len(r.ByDay))
+		for i, d := range r.ByDay {
+			days[i] = d.String()
+		}
+		parts = append(parts, "BYDAY="+strings.Join(days, ","))
+	}
+	if len(r.BySetPos) > 0 {
+		parts = append(parts, "BYSETPOS="+joinInts(r.BySetPos))
+	}
+	if r.WeekStart != time.Monday {
+		parts = append(parts, "WKST="+weekdayNames[r.WeekStart])
+	}
+	if r.Skip != SkipOmit {
+		parts = append(parts, "SKIP="+r.Skip.String())
+	}
+	if r.Workday != WorkdayNone {
+		parts = append(parts, "X-WORKDAY="+r.Workday.String())
+	}
+	return strings.Join(parts, ";")
+}
+
+func joinInts(values []int) string {
+	s := make([]string, len(values))
+	for i, v := range values {
+		s[i] = strconv.Itoa(v)
+	}
+	return strings.Join(s, ",")
+}
+
+// ---- 展开 ----
+
+// Iterator 按时间顺序依次产生发生时间
+// 第一个发生时间总是起始时间本身，之后是规则产生的晚于起始时间的发生时间
+type Iterator struct {
+	rule    *Rule
+	start   time.Time
+	period  int         // 下一个要展开的周期序号
+	pending []time.Time // 当前周期中尚未返回的发生时间
+	emitted int         // 已返回的发生时间数 (用于 COUNT)
+	last    time.Time   // 上一个返回的发生时间
+	done    bool
+}
+
+// Iterate 从起始时间开始展开规则，时刻和时区与起始时间相同
+func (r *Rule) Iterate(start time.Time) *Iterator {
+	return &Iterator{rule: r, start: start}
+}
+
+// Next 返回下一个发生时间，没有更多发生时间时第二个返回值为 false
+func (it *Iterator) Next() (time.Time, bool) {
+	if it.done {
+		return time.Time{}, false
+	}
+	if it.rule.Count > 0 && it.emitted >= it.rule.Count {
+		it.done = true
+		return time.Time{}, false
+	}
+	if it.emitted == 0 {
+		it.emitted++
+		it.last = it.start
+		return it.start, true
+	}
+
+	empty := 0
+	for {
+		for len(it.pending) == 0 {
+			if empty >= maxEmptyPeriods {
+				it.done = true
+				return time.Time{}, false
+			}
+			for _, t := range it.rule.expandPeriod(it.start, it.period) {
+				if t.After(it.start) {
+					it.pending = append(it.pending, t)
+				}
+			}
+			it.period++
+			if len(it.pending) == 0 {
+				empty++
+			}
+		}
+
+		t := it.pending[0]
+		it.pending = it.pending[1:]
+		// X-WORKDAY 顺延后可能与之前周期的发生时间重合或交错，保证严格递增
+		if !t.After(it.last) {
+			continue
+		}
+		if it.rule.afterUntil(t) {
+			it.done = true
+			return time.Time{}, false
+		}
+		it.emitted++
+		it.last = t
+		return t, true
+	}
+}
+
+// expandPeriod 返回第 n 个周期内的所有发生时间 (已排序并应用 BYSETPOS 和 X-WORKDAY)
+func (r *Rule) expandPeriod(start time.Time, n int) []time.Time {
+	days := r.candidates(start, n)
+	if r.Workday == WorkdayOnly {
+		days = r.onlyWorkdays(days)
+	}
+	days = applySetPos(days, r.BySetPos)
+	if r.Workday == WorkdayBackward || r.Workday == WorkdayForward {
+		days = r.shiftToWorkdays(days)
+	}
+	return days
+}
+
+// candidates 返回第 n 个周期内符合 BYxxx 规则的日期，已排序去重
+func (r *Rule) candidates(start time.Time, n int) []time.Time {
+	hh, mm, ss := start.Clock()
+	at := func(y int, m time.Month, d int) time.Time {
+		return localTime(y, m, d, hh, mm, ss, start.Nanosecond(), start.Location())
+	}
+
+	if r.RScale == ScaleChinese {
+		return r.expandLunarPeriod(start, n, at)
+	}
+
+	var days []time.Time
+	switch r.Freq {
+	case Daily:
+		d := at(start.Year(), start.Month(), start.Day()+n*r.Interval)
+		if r.matchMonth(d) && r.matchMonthDay(d) && r.matchWeekday(d) {
+			days = append(days, d)
+		}
+	case Weekly:
+		// 以 WKST 为一周的开始
+		offset := (int(start.Weekday()) - int(r.WeekStart) + 7) % 7
+		weekStart := at(start.Year(), start.Month(), start.Day()-offset+7*n*r.Interval)
+		for i := 0; i < 7; i++ {
+			d := at(weekStart.Year(), weekStart.Month(), weekStart.Day()+i)
+			if len(r.ByDay) == 0 && d.Weekday() != start.Weekday() {
+				continue
+			}
+			if r.matchMonth(d) && r.matchWeekday(d) {
+				days = append(days, d)
+			}
+		}
+	case Monthly:
+		first := at(start.Year(), start.Month()+time.Month(n*r.Interval), 1)
+		if r.matchMonth(first) {
+			days = r.daysInMonth(first, start, at)
+		}
+	case Yearly:
+		year := start.Year() + n*r.Interval
+		switch {
+		case len(r.ByMonth) > 0:
+			for _, m := range r.ByMonth {
+				days = append(days, r.daysInMonth(at(year, time.Month(m), 1), start, at)...)
+			}
+		case len(r.ByMonthDay) > 0:
+			for m := time.January; m <= time.December; m++ {
+				days = append(days, r.daysInMonth(at(year, m, 1), start, at)...)
+			}
+		case len(r.ByDay) > 0:
+			// 序号相对于整年，如 20MO 表示当年第 20 个星期一
+			days = weekdaysIn(at(year, time.January, 1), at(year+1, time.January, 1), r.ByDay, at)
+		default:
+			// 与起始日期相同的月和日，不存在的日期 (如非闰年的 2 月 29 日) 跳过
+			d := at(year, start.Month(), start.Day())
+			if d.Month() == start.Month() {
+				days = append(days, d)
+			}
+		}
+	}
+
+	sort.Slice(days, func(i, j int) bool { return days[i].Before(days[j]) })
+	return dedupeTimes(days)
+}
+
+// localTime 返回 loc 时区中的本地时刻
+// 该时刻因夏令时开始而不存在时，按 RFC 5545 使用切换前的 UTC 偏移解释，如 02:30 变为 03:30
+func localTime(y int, m time.Month, d, hh, mm, ss, ns int, loc *time.Location) time.Time {
+	t := time.Date(y, m, d, hh, mm, ss, ns, loc)
+	if t.Hour() == hh && t.Minute() == mm {
+		return t
+	}
+	// time.Date 对不存在的时刻使用切换后的偏移，得到的是切换前的时刻，其偏移正是切换前的偏移
+	_, offset := t.Zone()
+	return time.Date(y, m, d, hh, mm, ss, ns, time.UTC).Add(-time.Duration(offset) * time.Second).In(loc)
+}
+
+// daysInMonth 返回 first 所在月份中符合 BYMONTHDAY/BYDAY 的日期
+func (r *Rule) daysInMonth(first, start time.Time, at func(int, time.Month, int) time.Time) []time.Time {
+	next := at(first.Year(), first.Month()+1, 1)
+	daysInMonth := next.AddDate(0, 0, -1).Day()
+
+	var days []time.Time
+	switch {
+	case len(r.ByMonthDay) > 0:
+		for _, md := range r.ByMonthDay {
+			day := md
+			if md < 0 {
+				day = daysInMonth + md + 1
+			}
+			if day < 1 || day > daysInMonth {
+				continue // 该月没有这一天
+			}
+			d := at(first.Year(), first.Month(), day)
+			// 同时指定 BYDAY 时，BYDAY 起过滤作用
+			if len(r.ByDay) == 0 || r.matchWeekday(d) {
+				days = append(days, d)
+			}
+		}
+	case len(r.ByDay) > 0:
+		days = weekdaysIn(first, next, r.ByDay, at)
+	default:
+		if start.Day() <= daysInMonth {
+			days = append(days, at(first.Year(), first.Month(), start.Day()))
+		}
+	}
+	return days
+}
+
+// weekdaysIn 返回 [from, to) 范围内符合 BYDAY 的日期，序号相对于该范围
+func weekdaysIn(from, to time.Time, byDay []WeekdayNum, at func(int, time.Month, int) time.Time) []time.Time {
+	byWeekday := map[time.Weekday][]time.Time{}
+	for d := from; d.Before(to); d = at(d.Year(), d.Month(), d.Day()+1) {
+		byWeekday[d.Weekday()] = append(byWeekday[d.Weekday()], d)
+	}
+	var days []time.Time
+	for _, wd := range byDay {
+		all := byWeekday[wd.Weekday]
+		switch {
+		case wd.N == 0:
+			days = append(days, all...)
+		case wd.N > 0 && wd.N <= len(all):
+			days = append(days, all[wd.N-1])
+		case wd.N < 0 && -wd.N <= len(all):
+			days = append(days, all[len(all)+wd.N])
+		}
+	}
+	return days
+}
+
+// afterUntil 判断发生时间是否晚于 UNTIL (UNTIL 当天/当时仍包含在内)
+func (r *Rule) afterUntil(t time.Time) bool {
+	if r.Until == nil {
+		return false
+	}
+	if r.UntilDate {
+		return t.Format("20060102") > r.Until.Format("20060102")
+	}
+	return t.After(*r.Until)
+}
+
+func (r *Rule) matchMonth(d time.Time) bool {
+	if len(r.ByMonth) == 0 {
+		return true
+	}
+	for _, m := range r.ByMonth {
+		if time.Month(m) == d.Month() {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Rule) matchMonthDay(d time.Time) bool {
+	if len(r.ByMonthDay) == 0 {
+		return true
+	}
+	daysInMonth := time.Date(d.Year(), d.Month()+1, 0, 0, 0, 0, 0, time.UTC).Day()
+	for _, md := range r.ByMonthDay {
+		if md == d.Day() || (md < 0 && daysInMonth+md+1 == d.Day()) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Rule) matchWeekday(d time.Time) bool {
+	if len(r.ByDay) == 0 {
+		return true
+	}
+	for _, wd := range r.ByDay {
+		if wd.Weekday == d.Weekday() {
+			return true
+		}
+	}
+	return false
+}
+
+func dedupeTimes(ts []time.Time) []time.Time {
+	var out []time.Time
+	for i, t := range ts {
+		if i == 0 || !t.Equal(ts[i-1]) {
+			out = append(out, t)
+		}
+	}
+	return out
+}
+
+// applySetPos 按 BYSETPOS 从周期内已排序的发生时间中挑选
+func applySetPos(days []time.Time, setPos []int) []time.Time {
+	if len(setPos) == 0 {
+		return days
+	}
+	var out []time.Time
+	for _, p := range setPos {
+		switch {
+		case p > 0 && p <= len(days):
+			out = append(out, days[p-1])
+		case p < 0 && -p <= len(days):
+			out = append(out, days[len(days)+p])
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Before(out[j]) })
+	return dedupeTimes(out)
+}
+
+// Next 返回晚于 after 且不在排除日期中的第一个发生时间及其序号 (起始时间的序号为 0)
+// exdates 为排除日期集合，键为起始时间时区下的 "2006-01-02"
+func (r *Rule) Next(start, after time.Time, exdates map[string]bool) (time.Time, int, bool) {
+	it := r.Iterate(start)
+	for i := 0; ; i++ {
+		t, ok := it.Next()
+		if !ok {
+			return time.Time{}, 0, false
+		}
+		if t.After(after) && !exdates[t.Format("2006-01-02")] {
+			return t, i, true
+		}
+	}
+}
+
+// Between 返回 [from, to) 范围内不在排除日期中的发生时间，最多 limit 个
+func (r *Rule) Between(start, from, to time.Time, exdates map[string]bool, limit int) []time.Time {
+	var out []time.Time
+	it := r.Iterate(start)
+	for len(out) < limit {
+		t, ok := it.Next()
+		if !ok || !t.Before(to) {
+			break
+		}
+		if !t.Before(from) && !exdates[t.Format("2006-01-02")] {
+			out = append(out, t)
+		}
+	}
+	return out
+}
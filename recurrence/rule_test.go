@@ -0,0 +1,423 @@
+package recurrence
+
+import (
+	"testing"
+	"time"
+	_ "time/tzdata"
+)
+
+// occurrences 展开规则的前 n 个发生时间，格式为 "2006-01-02 15:04 MST"
+func occurrences(t *testing.T, rrule string, start time.Time, n int) []string {
+	t.Helper()
+	r, err := Parse(rrule)
+	if err != nil {
+		t.Fatalf("Parse(%q): %v", rrule, err)
+	}
+	var out []string
+	it := r.Iterate(start)
+	for len(out) < n {
+		d, ok := it.Next()
+		if !ok {
+			break
+		}
+		out = append(out, d.Format("2006-01-02 15:04 MST"))
+	}
+	return out
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func utcAt(s string) time.Time {
+	t, err := time.Parse("2006-01-02 15:04", s)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func TestParseRoundTrip(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"RRULE:FREQ=DAILY", "FREQ=DAILY"},
+		{"freq=weekly;interval=2;byday=mo,we", "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,WE"},
+		{"FREQ=MONTHLY;BYDAY=-1FR;COUNT=3", "FREQ=MONTHLY;COUNT=3;BYDAY=-1FR"},
+		{"FREQ=YEARLY;UNTIL=20301231;BYMONTH=11;BYDAY=4TH", "FREQ=YEARLY;UNTIL=20301231;BYMONTH=11;BYDAY=4TH"},
+		{"FREQ=WEEKLY;WKST=SU", "FREQ=WEEKLY;WKST=SU"},
+		{"RSCALE=CHINESE;FREQ=YEARLY;BYMONTH=8L;SKIP=FORWARD", "RSCALE=CHINESE;FREQ=YEARLY;BYMONTH=8L;SKIP=FORWARD"},
+		{"FREQ=MONTHLY;BYMONTHDAY=1;X-WORKDAY=FORWARD", "FREQ=MONTHLY;BYMONTHDAY=1;X-WORKDAY=FORWARD"},
+	}
+	for _, tt := range tests {
+		r, err := Parse(tt.in)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tt.in, err)
+			continue
+		}
+		if got := r.String(); got != tt.want {
+			t.Errorf("Parse(%q).String() = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	for _, in := range []string{
+		"",
+		"INTERVAL=2",
+		"FREQ=HOURLY",
+		"FREQ=DAILY;INTERVAL=0",
+		"FREQ=DAILY;COUNT=2;UNTIL=20300101",
+		"FREQ=WEEKLY;BYDAY=2MO",
+		"FREQ=MONTHLY;BYMONTHDAY=0",
+		"FREQ=MONTHLY;BYSETPOS=1",
+		"FREQ=YEARLY;BYMONTH=8L",
+		"FREQ=YEARLY;SKIP=FORWARD",
+		"RSCALE=CHINESE;FREQ=WEEKLY",
+		"FREQ=DAILY;BYYEARDAY=1",
+	} {
+		if _, err := Parse(in); err == nil {
+			t.Errorf("Parse(%q) should fail", in)
+		}
+	}
+}
+
+func TestExpand(t *testing.T) {
+	tests := []struct {
+		name  string
+		rrule string
+		start string
+		want  []string
+	}{
+		{
+			"隔天", "FREQ=DAILY;INTERVAL=2;COUNT=3", "2024-01-01 09:00",
+			[]string{"2024-01-01 09:00 UTC", "2024-01-03 09:00 UTC", "2024-01-05 09:00 UTC"},
+		},
+		{
+			"每周一三五", "FREQ=WEEKLY;BYDAY=MO,WE,FR;COUNT=5", "2024-01-01 09:00",
+			[]string{"2024-01-01 09:00 UTC", "2024-01-03 09:00 UTC", "2024-01-05 09:00 UTC", "2024-01-08 09:00 UTC", "2024-01-10 09:00 UTC"},
+		},
+		{
+			"隔周，起始日不在 BYDAY 中", "FREQ=WEEKLY;INTERVAL=2;BYDAY=TU;COUNT=3", "2024-01-01 09:00",
+			[]string{"2024-01-01 09:00 UTC", "2024-01-02 09:00 UTC", "2024-01-16 09:00 UTC"},
+		},
+		{
+			"每月第二个星期二", "FREQ=MONTHLY;BYDAY=2TU;COUNT=3", "2024-01-09 10:00",
+			[]string{"2024-01-09 10:00 UTC", "2024-02-13 10:00 UTC", "2024-03-12 10:00 UTC"},
+		},
+		{
+			"每月最后一个工作日 (BYSETPOS)", "FREQ=MONTHLY;BYDAY=MO,TU,WE,TH,FR;BYSETPOS=-1;COUNT=3", "2024-01-31 18:00",
+			[]string{"2024-01-31 18:00 UTC", "2024-02-29 18:00 UTC", "2024-03-29 18:00 UTC"},
+		},
+		{
+			"感恩节", "FREQ=YEARLY;BYMONTH=11;BYDAY=4TH;COUNT=3", "2024-11-28 12:00",
+			[]string{"2024-11-28 12:00 UTC", "2025-11-27 12:00 UTC", "2026-11-26 12:00 UTC"},
+		},
+		{
+			"UNTIL 只有日期时当天仍包含在内", "FREQ=WEEKLY;UNTIL=20240115", "2024-01-01 23:00",
+			[]string{"2024-01-01 23:00 UTC", "2024-01-08 23:00 UTC", "2024-01-15 23:00 UTC"},
+		},
+		{
+			"UNTIL 带时刻", "FREQ=DAILY;UNTIL=20240103T080000Z", "2024-01-01 09:00",
+			[]string{"2024-01-01 09:00 UTC", "2024-01-02 09:00 UTC"},
+		},
+		{
+			"起始时间不符合规则时仍作为第一个发生时间", "FREQ=MONTHLY;BYMONTHDAY=15;COUNT=3", "2024-01-20 09:00",
+			[]string{"2024-01-20 09:00 UTC", "2024-02-15 09:00 UTC", "2024-03-15 09:00 UTC"},
+		},
+		{
+			"每个工作日 (无日历时为周一至周五)", "FREQ=DAILY;X-WORKDAY=ONLY;COUNT=4", "2024-01-05 09:00",
+			[]string{"2024-01-05 09:00 UTC", "2024-01-08 09:00 UTC", "2024-01-09 09:00 UTC", "2024-01-10 09:00 UTC"},
+		},
+		{
+			"每月 1 号遇休息日顺延", "FREQ=MONTHLY;BYMONTHDAY=1;X-WORKDAY=FORWARD;COUNT=4", "2024-05-01 09:00",
+			[]string{"2024-05-01 09:00 UTC", "2024-06-03 09:00 UTC", "2024-07-01 09:00 UTC", "2024-08-01 09:00 UTC"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := occurrences(t, tt.rrule, utcAt(tt.start), len(tt.want)+1)
+			if !equalStrings(got, tt.want) {
+				t.Errorf("%s from %s\n got %v\nwant %v", tt.rrule, tt.start, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExpandMonthEnd(t *testing.T) {
+	tests := []struct {
+		name  string
+		rrule string
+		start string
+		want  []string
+	}{
+		{
+			"31 号只在有 31 天的月份发生", "FREQ=MONTHLY;COUNT=4", "2024-01-31 09:00",
+			[]string{"2024-01-31 09:00 UTC", "2024-03-31 09:00 UTC", "2024-05-31 09:00 UTC", "2024-07-31 09:00 UTC"},
+		},
+		{
+			"每月最后一天", "FREQ=MONTHLY;BYMONTHDAY=-1;COUNT=4", "2024-01-31 09:00",
+			[]string{"2024-01-31 09:00 UTC", "2024-02-29 09:00 UTC", "2024-03-31 09:00 UTC", "2024-04-30 09:00 UTC"},
+		},
+		{
+			"平年二月没有 30 号", "FREQ=MONTHLY;BYMONTHDAY=30;COUNT=3", "2023-01-30 09:00",
+			[]string{"2023-01-30 09:00 UTC", "2023-03-30 09:00 UTC", "2023-04-30 09:00 UTC"},
+		},
+		{
+			"每月倒数第二天", "FREQ=MONTHLY;BYMONTHDAY=-2;COUNT=3", "2023-02-27 09:00",
+			[]string{"2023-02-27 09:00 UTC", "2023-03-30 09:00 UTC", "2023-04-29 09:00 UTC"},
+		},
+		{
+			"2 月 29 日只在闰年发生", "FREQ=YEARLY;COUNT=3", "2024-02-29 09:00",
+			[]string{"2024-02-29 09:00 UTC", "2028-02-29 09:00 UTC", "2032-02-29 09:00 UTC"},
+		},
+		{
+			"每年二月最后一天", "FREQ=YEARLY;BYMONTH=2;BYMONTHDAY=-1;COUNT=3", "2023-02-28 09:00",
+			[]string{"2023-02-28 09:00 UTC", "2024-02-29 09:00 UTC", "2025-02-28 09:00 UTC"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := occurrences(t, tt.rrule, utcAt(tt.start), len(tt.want)+1)
+			if !equalStrings(got, tt.want) {
+				t.Errorf("%s from %s\n got %v\nwant %v", tt.rrule, tt.start, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExpandAcrossDST(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 发生时间保持起始时间的本地时刻，UTC 偏移随夏令时变化
+	tests := []struct {
+		name  string
+		rrule string
+		start time.Time
+		want  []string
+	}{
+		{
+			"纽约夏令时开始", "FREQ=DAILY;COUNT=3", time.Date(2024, time.March, 9, 9, 0, 0, 0, newYork),
+			[]string{"2024-03-09 09:00 EST", "2024-03-10 09:00 EDT", "2024-03-11 09:00 EDT"},
+		},
+		{
+			"纽约夏令时结束", "FREQ=WEEKLY;COUNT=2", time.Date(2024, time.November, 2, 8, 30, 0, 0, newYork),
+			[]string{"2024-11-02 08:30 EDT", "2024-11-09 08:30 EST"},
+		},
+		{
+			// 02:30 在切换当天不存在，按 RFC 5545 使用切换前的偏移解释，即 03:30
+			"本地时刻在切换当天不存在", "FREQ=DAILY;COUNT=3", time.Date(2024, time.March, 9, 2, 30, 0, 0, newYork),
+			[]string{"2024-03-09 02:30 EST", "2024-03-10 03:30 EDT", "2024-03-11 02:30 EDT"},
+		},
+		{
+			// 01:30 在切换当天出现两次，取第一次
+			"本地时刻在切换当天重复", "FREQ=DAILY;COUNT=3", time.Date(2024, time.November, 2, 1, 30, 0, 0, newYork),
+			[]string{"2024-11-02 01:30 EDT", "2024-11-03 01:30 EDT", "2024-11-04 01:30 EST"},
+		},
+		{
+			"柏林每月最后一个星期日", "FREQ=MONTHLY;BYDAY=-1SU;COUNT=3", time.Date(2024, time.February, 25, 7, 0, 0, 0, berlin),
+			[]string{"2024-02-25 07:00 CET", "2024-03-31 07:00 CEST", "2024-04-28 07:00 CEST"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := occurrences(t, tt.rrule, tt.start, len(tt.want)+1)
+			if !equalStrings(got, tt.want) {
+				t.Errorf("%s from %s\n got %v\nwant %v", tt.rrule, tt.start, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExpandLunarSkip(t *testing.T) {
+	tests := []struct {
+		name  string
+		rrule string
+		start string
+		want  []string
+	}{
+		{
+			// 2024-2028 农历年的腊月都只有 29 天
+			"除夕 (腊月三十) 跳过", "RSCALE=CHINESE;FREQ=YEARLY;BYMONTH=12;BYMONTHDAY=30;COUNT=2", "2024-02-09 20:00",
+			[]string{"2024-02-09 20:00 UTC", "2030-02-02 20:00 UTC"},
+		},
+		{
+			"除夕 (腊月三十) 提前到月末", "RSCALE=CHINESE;FREQ=YEARLY;BYMONTH=12;BYMONTHDAY=30;SKIP=BACKWARD;COUNT=3", "2024-02-09 20:00",
+			[]string{"2024-02-09 20:00 UTC", "2025-01-28 20:00 UTC", "2026-02-16 20:00 UTC"},
+		},
+		{
+			"除夕 (腊月三十) 推后到下月初一", "RSCALE=CHINESE;FREQ=YEARLY;BYMONTH=12;BYMONTHDAY=30;SKIP=FORWARD;COUNT=3", "2024-02-09 20:00",
+			[]string{"2024-02-09 20:00 UTC", "2025-01-29 20:00 UTC", "2026-02-17 20:00 UTC"},
+		},
+		{
+			"闰二月初一，次年没有闰二月时改为二月", "RSCALE=CHINESE;FREQ=YEARLY;BYMONTH=2L;SKIP=BACKWARD;COUNT=2", "2023-03-22 09:00",
+			[]string{"2023-03-22 09:00 UTC", "2024-03-10 09:00 UTC"},
+		},
+		{
+			"闰二月初一，次年没有闰二月时改为三月", "RSCALE=CHINESE;FREQ=YEARLY;BYMONTH=2L;SKIP=FORWARD;COUNT=2", "2023-03-22 09:00",
+			[]string{"2023-03-22 09:00 UTC", "2024-04-09 09:00 UTC"},
+		},
+		{
+			"每个农历月初一，闰月也算一个月", "RSCALE=CHINESE;FREQ=MONTHLY;COUNT=4", "2023-02-20 09:00",
+			[]string{"2023-02-20 09:00 UTC", "2023-03-22 09:00 UTC", "2023-04-20 09:00 UTC", "2023-05-19 09:00 UTC"},
+		},
+		{
+			"中秋", "RSCALE=CHINESE;FREQ=YEARLY;COUNT=3", "2023-09-29 19:00",
+			[]string{"2023-09-29 19:00 UTC", "2024-09-17 19:00 UTC", "2025-10-06 19:00 UTC"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := occurrences(t, tt.rrule, utcAt(tt.start), len(tt.want)+1)
+			if !equalStrings(got, tt.want) {
+				t.Errorf("%s from %s\n got %v\nwant %v", tt.rrule, tt.start, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNext(t *testing.T) {
+	start := utcAt("2024-01-01 09:00")
+	tests := []struct {
+		name    string
+		rrule   string
+		after   string
+		exdates map[string]bool
+		want    string
+		index   int
+		ok      bool
+	}{
+		{"下一次", "FREQ=DAILY", "2024-01-01 09:00", nil, "2024-01-02 09:00", 1, true},
+		{"早于起始时间时返回起始时间", "FREQ=DAILY", "2023-12-31 00:00", nil, "2024-01-01 09:00", 0, true},
+		{"跳过排除日期，序号仍计入", "FREQ=DAILY", "2024-01-01 09:00", map[string]bool{"2024-01-02": true}, "2024-01-03 09:00", 2, true},
+		{"排除起始日期", "FREQ=WEEKLY", "2023-12-31 00:00", map[string]bool{"2024-01-01": true}, "2024-01-08 09:00", 1, true},
+		{"同一天中晚于发生时刻", "FREQ=DAILY", "2024-01-05 10:00", nil, "2024-01-06 09:00", 5, true},
+		{"COUNT 用完", "FREQ=DAILY;COUNT=2", "2024-01-02 09:00", nil, "", 0, false},
+		{"UNTIL 之后", "FREQ=DAILY;UNTIL=20240103", "2024-01-03 09:00", nil, "", 0, false},
+		{"最后一次被排除", "FREQ=DAILY;COUNT=3", "2024-01-01 09:00", map[string]bool{"2024-01-02": true, "2024-01-03": true}, "", 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := Parse(tt.rrule)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, index, ok := r.Next(start, utcAt(tt.after), tt.exdates)
+			if ok != tt.ok {
+				t.Fatalf("Next ok = %v, want %v (got %s)", ok, tt.ok, got)
+			}
+			if !ok {
+				return
+			}
+			if s := got.Format("2006-01-02 15:04"); s != tt.want || index != tt.index {
+				t.Errorf("Next = %s #%d, want %s #%d", s, index, tt.want, tt.index)
+			}
+		})
+	}
+}
+
+func TestBetween(t *testing.T) {
+	r, err := Parse("FREQ=WEEKLY;BYDAY=MO,FR")
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := utcAt("2024-01-01 09:00")
+	got := r.Between(start, utcAt("2024-01-05 00:00"), utcAt("2024-01-20 00:00"), map[string]bool{"2024-01-12": true}, 10)
+	var days []string
+	for _, d := range got {
+		days = append(days, d.Format("2006-01-02"))
+	}
+	want := []string{"2024-01-05", "2024-01-08", "2024-01-15", "2024-01-19"}
+	if !equalStrings(days, want) {
+		t.Errorf("Between = %v, want %v", days, want)
+	}
+	if got := r.Between(start, start, utcAt("2025-01-01 00:00"), nil, 3); len(got) != 3 {
+		t.Errorf("Between with limit 3 returned %d occurrences", len(got))
+	}
+}
+
+// fixedWorkdays 测试用的工作日历：周一至周五为工作日，另有调休
+type fixedWorkdays struct {
+	holidays map[string]bool // 放假的工作日
+	workdays map[string]bool // 调休上班的周末
+}
+
+func (c fixedWorkdays) IsWorkday(t time.Time) bool {
+	day := t.Format("2006-01-02")
+	if c.workdays[day] {
+		return true
+	}
+	if c.holidays[day] {
+		return false
+	}
+	return t.Weekday() != time.Saturday && t.Weekday() != time.Sunday
+}
+
+func TestWorkdayCalendar(t *testing.T) {
+	cal := fixedWorkdays{
+		holidays: map[string]bool{"2024-10-01": true, "2024-10-02": true, "2024-10-03": true, "2024-10-04": true, "2024-10-07": true},
+		workdays: map[string]bool{"2024-09-29": true, "2024-10-12": true},
+	}
+	tests := []struct {
+		name  string
+		rrule string
+		start string
+		want  []string
+	}{
+		{
+			"国庆假期后顺延到第一个工作日", "FREQ=MONTHLY;BYMONTHDAY=1;X-WORKDAY=FORWARD;COUNT=3", "2024-09-02 09:00",
+			// 9 月 1 日是星期日，顺延到 9 月 2 日；10 月 1 日顺延到 10 月 8 日
+			[]string{"2024-09-02 09:00 UTC", "2024-10-08 09:00 UTC", "2024-11-01 09:00 UTC"},
+		},
+		{
+			// 假期中每天都顺延到 10 月 8 日，重合的发生时间只返回一次
+			"每天，假期中顺延", "FREQ=DAILY;X-WORKDAY=FORWARD;COUNT=3", "2024-09-30 09:00",
+			[]string{"2024-09-30 09:00 UTC", "2024-10-08 09:00 UTC", "2024-10-09 09:00 UTC"},
+		},
+		{
+			"调休的周末算作工作日", "FREQ=WEEKLY;BYDAY=SU;X-WORKDAY=ONLY;COUNT=2", "2024-09-22 09:00",
+			[]string{"2024-09-22 09:00 UTC", "2024-09-29 09:00 UTC"},
+		},
+		{
+			"每月最后一个工作日", "FREQ=MONTHLY;BYMONTHDAY=-1;X-WORKDAY=BACKWARD;COUNT=3", "2024-08-30 18:00",
+			// 8 月 31 日是星期六，提前到 8 月 30 日；9 月 30 日是星期一
+			[]string{"2024-08-30 18:00 UTC", "2024-09-30 18:00 UTC", "2024-10-31 18:00 UTC"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := Parse(tt.rrule)
+			if err != nil {
+				t.Fatal(err)
+			}
+			r.Calendar = cal
+			var got []string
+			it := r.Iterate(utcAt(tt.start))
+			for len(got) <= len(tt.want) {
+				d, ok := it.Next()
+				if !ok {
+					break
+				}
+				got = append(got, d.Format("2006-01-02 15:04 MST"))
+			}
+			if !equalStrings(got, tt.want) {
+				t.Errorf("%s from %s\n got %v\nwant %v", tt.rrule, tt.start, got, tt.want)
+			}
+		})
+	}
+}
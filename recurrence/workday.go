@@ -0,0 +1,75 @@
+package recurrence
+
+import (
+	"sort"
+	"time"
+)
+
+// Workdays 工作日历，calendar.Calendar 实现了该接口
+type Workdays interface {
+	IsWorkday(t time.Time) bool
+}
+
+// WorkdayMode X-WORKDAY 扩展的取值
+type WorkdayMode int
+
+const (
+	WorkdayNone     WorkdayMode = iota
+	WorkdayOnly                 // 只保留工作日，在 BYSETPOS 之前过滤，如 "每个工作日"
+	WorkdayBackward             // 落在休息日时提前到之前最近的工作日，如 "每月最后一个工作日"
+	WorkdayForward              // 落在休息日时推后到之后最近的工作日，如 "每月 1 号，遇节假日顺延"
+)
+
+var workdayModeNames = map[string]WorkdayMode{
+	"ONLY":     WorkdayOnly,
+	"BACKWARD": WorkdayBackward,
+	"FORWARD":  WorkdayForward,
+}
+
+func (m WorkdayMode) String() string {
+	for name, v := range workdayModeNames {
+		if v == m {
+			return name
+		}
+	}
+	return ""
+}
+
+// 调整到工作日时最多移动的天数，防止错误的日历数据导致死循环
+const maxWorkdayShift = 366
+
+// isWorkday 判断日期 (发生时间所在时区的年月日) 是否为工作日
+func (r *Rule) isWorkday(t time.Time) bool {
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	if r.Calendar != nil {
+		return r.Calendar.IsWorkday(day)
+	}
+	return day.Weekday() != time.Saturday && day.Weekday() != time.Sunday
+}
+
+func (r *Rule) onlyWorkdays(days []time.Time) []time.Time {
+	var out []time.Time
+	for _, d := range days {
+		if r.isWorkday(d) {
+			out = append(out, d)
+		}
+	}
+	return out
+}
+
+// shiftToWorkdays 将落在休息日的发生时间移到最近的工作日，时刻保持不变
+func (r *Rule) shiftToWorkdays(days []time.Time) []time.Time {
+	step := 1
+	if r.Workday == WorkdayBackward {
+		step = -1
+	}
+	out := make([]time.Time, 0, len(days))
+	for _, d := range days {
+		for i := 0; i < maxWorkdayShift && !r.isWorkday(d); i++ {
+			d = d.AddDate(0, 0, step)
+		}
+		out = append(out, d)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Before(out[j]) })
+	return dedupeTimes(out)
+}
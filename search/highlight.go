@@ -0,0 +1,89 @@
+package search
+
+import (
+	"html"
+	"strings"
+	"unicode"
+)
+
+const (
+	// HighlightPre 高亮开始标记
+	HighlightPre = "<em>"
+	// HighlightPost 高亮结束标记
+	HighlightPost = "</em>"
+)
+
+// Snippet 从文本中截取包含查询词元的片段，并用 <em></em> 包裹命中部分
+// 文本本身会做 HTML 转义，maxRunes 为片段的最大字符数，未命中时返回空字符串
+func Snippet(text string, terms []string, maxRunes int) string {
+	runes := []rune(text)
+	lower := make([]rune, len(runes))
+	for i, r := range runes {
+		lower[i] = unicode.ToLower(r)
+	}
+
+	// 标记所有命中的字符
+	hit := make([]bool, len(runes))
+	first := -1
+	for _, term := range terms {
+		t := []rune(term)
+		if len(t) == 0 {
+			continue
+		}
+		for i := 0; i+len(t) <= len(lower); i++ {
+			if string(lower[i:i+len(t)]) != term {
+				continue
+			}
+			for j := i; j < i+len(t); j++ {
+				hit[j] = true
+			}
+			if first == -1 || i < first {
+				first = i
+			}
+		}
+	}
+	if first == -1 {
+		return ""
+	}
+
+	// 以第一个命中位置为中心截取片段
+	start, end := 0, len(runes)
+	if maxRunes > 0 && len(runes) > maxRunes {
+		start = first - maxRunes/4
+		if start < 0 {
+			start = 0
+		}
+		end = start + maxRunes
+		if end > len(runes) {
+			end = len(runes)
+			start = end - maxRunes
+		}
+	}
+
+	return renderHighlight(runes, hit, start, end)
+}
+
+// renderHighlight 输出 [start, end) 区间的文本，命中的字符用高亮标记包裹
+func renderHighlight(runes []rune, hit []bool, start, end int) string {
+	var b strings.Builder
+	if start > 0 {
+		b.WriteString("…")
+	}
+	for i := start; i < end; {
+		j := i
+		for j < end && hit[j] == hit[i] {
+			j++
+		}
+		part := html.EscapeString(string(runes[i:j]))
+		if hit[i] {
+			b.WriteString(HighlightPre + part + HighlightPost)
+		} else {
+			b.WriteString(part)
+		}
+		i = j
+	}
+	if end < len(runes) {
+		b.WriteString("…")
+	}
+	return b.String()
+}
@@ -0,0 +1,134 @@
+package search
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/mozillazg/go-pinyin"
+)
+
+// pinyinArgs 不带声调的普通风格，多音字取最常用读音
+var pinyinArgs = pinyin.NewArgs()
+
+// pinyinUnit 文本中的一个拼音单元：一个汉字或一个拉丁单词
+type pinyinUnit struct {
+	start, end int    // 在原文中的字符区间 [start, end)
+	syllable   string // 汉字的拼音或小写的单词
+}
+
+// pinyinUnits 将文本拆分为拼音单元，标点和空白被忽略
+func pinyinUnits(text string) []pinyinUnit {
+	var units []pinyinUnit
+	runes := []rune(text)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		if unicode.Is(unicode.Han, r) {
+			if py := pinyin.SinglePinyin(r, pinyinArgs); len(py) > 0 {
+				units = append(units, pinyinUnit{start: i, end: i + 1, syllable: py[0]})
+			}
+			i++
+			continue
+		}
+		if isWordRune(r) {
+			j := i
+			for j < len(runes) && isWordRune(runes[j]) {
+				j++
+			}
+			units = append(units, pinyinUnit{start: i, end: j, syllable: strings.ToLower(string(runes[i:j]))})
+			i = j
+			continue
+		}
+		i++
+	}
+	return units
+}
+
+// Pinyin 返回文本的全拼 (无空格) 和首字母缩写
+// 例如 "买牛奶" 返回 "mainiunai" 和 "mnn"，其他文字的单词保持原样并计入一个首字母 (按字符取，不会截断多字节字符)
+func Pinyin(text string) (full, initials string) {
+	var f, i strings.Builder
+	for _, u := range pinyinUnits(text) {
+		f.WriteString(u.syllable)
+		r, _ := utf8.DecodeRuneInString(u.syllable)
+		i.WriteRune(r)
+	}
+	return f.String(), i.String()
+}
+
+// NormalizePinyinQuery 规范化拼音查询，去掉空白并转为小写
+// 如果查询中包含字母数字以外的字符 (例如汉字)，返回空字符串表示不是拼音查询
+func NormalizePinyinQuery(query string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(query) {
+		switch {
+		case unicode.IsSpace(r) || r == '\'':
+			continue
+		case r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)):
+			b.WriteRune(r)
+		default:
+			return ""
+		}
+	}
+	return b.String()
+}
+
+// MatchPinyin 判断文本是否能被拼音查询匹配，返回匹配到的原文字符区间 [start, end)
+// 支持按音节对齐的全拼匹配 (最后一个音节可以只输入前缀，如 "mai niun")
+// 和首字母匹配 (如 "mnn")，query 需先经过 NormalizePinyinQuery 处理
+func MatchPinyin(text, query string) (start, end int, ok bool) {
+	if query == "" {
+		return 0, 0, false
+	}
+	units := pinyinUnits(text)
+	for i := range units {
+		if j, matched := matchFull(units[i:], query); matched {
+			return units[i].start, units[i+j-1].end, true
+		}
+	}
+	for i := range units {
+		if i+len(query) > len(units) {
+			break
+		}
+		matched := true
+		for k := 0; k < len(query); k++ {
+			if units[i+k].syllable[0] != query[k] {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return units[i].start, units[i+len(query)-1].end, true
+		}
+	}
+	return 0, 0, false
+}
+
+// matchFull 从第一个单元开始按音节匹配全拼，返回消耗的单元数
+func matchFull(units []pinyinUnit, query string) (int, bool) {
+	rest := query
+	for n, u := range units {
+		switch {
+		case strings.HasPrefix(rest, u.syllable):
+			rest = rest[len(u.syllable):]
+		case strings.HasPrefix(u.syllable, rest):
+			rest = ""
+		default:
+			return 0, false
+		}
+		if rest == "" {
+			return n + 1, true
+		}
+	}
+	return 0, false
+}
+
+// HighlightRange 用 <em></em> 包裹文本中 [start, end) 区间的字符，其余部分做 HTML 转义
+func HighlightRange(text string, start, end int) string {
+	runes := []rune(text)
+	hit := make([]bool, len(runes))
+	for i := start; i < end && i < len(runes); i++ {
+		hit[i] = true
+	}
+	return renderHighlight(runes, hit, 0, len(runes))
+}
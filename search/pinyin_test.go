@@ -0,0 +1,29 @@
+package search
+
+import (
+	"testing"
+	"unicode/utf8"
+)
+
+func TestPinyin(t *testing.T) {
+	tests := []struct {
+		text     string
+		full     string
+		initials string
+	}{
+		{"买牛奶", "mainiunai", "mnn"},
+		{"买 Milk 和面包", "maimilkhemianbao", "mmhmb"},
+		{"купить молоко", "купитьмолоко", "км"},
+		{"café 咖啡", "cafékafei", "ckf"},
+		{"", "", ""},
+	}
+	for _, tt := range tests {
+		full, initials := Pinyin(tt.text)
+		if full != tt.full || initials != tt.initials {
+			t.Errorf("Pinyin(%q) = %q, %q; want %q, %q", tt.text, full, initials, tt.full, tt.initials)
+		}
+		if !utf8.ValidString(initials) {
+			t.Errorf("Pinyin(%q) initials %q is not valid UTF-8", tt.text, initials)
+		}
+	}
+}
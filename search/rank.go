@@ -0,0 +1,93 @@
+package search
+
+import (
+	"math"
+	"sort"
+)
+
+// Field 词元所在的字段
+type Field uint8
+
+const (
+	FieldTitle       Field = 1
+	FieldDescription Field = 2
+)
+
+// fieldWeights 各字段的权重，标题命中比描述命中更重要
+var fieldWeights = map[Field]float64{
+	FieldTitle:       3,
+	FieldDescription: 1,
+}
+
+// Posting 倒排索引中的一条记录
+type Posting struct {
+	DocID uint
+	Term  string
+	Field Field
+	Freq  int
+}
+
+// Result 排序后的检索结果
+type Result struct {
+	DocID   uint
+	Score   float64
+	Matched int // 命中的查询词元个数
+}
+
+// MinMatch 返回文档至少需要命中的查询词元数
+// 短查询要求全部命中，长查询允许部分词元缺失
+func MinMatch(queryTerms int) int {
+	if queryTerms <= 2 {
+		return queryTerms
+	}
+	return int(math.Ceil(float64(queryTerms) * 0.6))
+}
+
+// Rank 根据倒排记录计算相关度并排序
+// 打分采用简化的 BM25：idf * 字段权重 * 饱和后的词频，
+// 先按命中词元数，再按分数，最后按文档ID倒序 (新的在前) 排序
+func Rank(postings []Posting, queryTerms []string, totalDocs int) []Result {
+	// 统计每个词元出现在多少个文档中
+	docsByTerm := map[string]map[uint]bool{}
+	for _, p := range postings {
+		if docsByTerm[p.Term] == nil {
+			docsByTerm[p.Term] = map[uint]bool{}
+		}
+		docsByTerm[p.Term][p.DocID] = true
+	}
+	if totalDocs < 1 {
+		totalDocs = 1
+	}
+
+	scores := map[uint]float64{}
+	matched := map[uint]map[string]bool{}
+	for _, p := range postings {
+		df := float64(len(docsByTerm[p.Term]))
+		idf := math.Log(1 + (float64(totalDocs)-df+0.5)/(df+0.5))
+		tf := float64(p.Freq)
+		scores[p.DocID] += idf * fieldWeights[p.Field] * tf * 2.2 / (tf + 1.2)
+		if matched[p.DocID] == nil {
+			matched[p.DocID] = map[string]bool{}
+		}
+		matched[p.DocID][p.Term] = true
+	}
+
+	min := MinMatch(len(queryTerms))
+	var results []Result
+	for id, score := range scores {
+		if len(matched[id]) < min {
+			continue
+		}
+		results = append(results, Result{DocID: id, Score: score, Matched: len(matched[id])})
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Matched != results[j].Matched {
+			return results[i].Matched > results[j].Matched
+		}
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].DocID > results[j].DocID
+	})
+	return results
+}
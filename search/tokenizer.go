@@ -0,0 +1,139 @@
+// Package search 提供待办事项全文检索所需的分词、打分和高亮片段生成。
+//
+// 中文等 CJK 文本没有空格分词，这里采用 n-gram 方式：
+// 建立索引时对连续的 CJK 字符同时生成单字 (unigram) 和双字 (bigram) 词元，
+// 查询时对长度不小于 2 的 CJK 片段只使用双字词元，单字查询则使用单字词元。
+// 拉丁字母和数字按单词切分并转为小写。
+package search
+
+import (
+	"strings"
+	"unicode"
+)
+
+// 单个词元的最大长度 (按字符计)，超出部分截断，避免超过数据库列宽
+const maxTermRunes = 32
+
+// Token 分词结果
+type Token struct {
+	Term string
+	Freq int // 词元在文本中出现的次数
+}
+
+// isCJK 判断字符是否属于需要按 n-gram 切分的文字
+func isCJK(r rune) bool {
+	return unicode.Is(unicode.Han, r) ||
+		unicode.Is(unicode.Hiragana, r) ||
+		unicode.Is(unicode.Katakana, r) ||
+		unicode.Is(unicode.Hangul, r)
+}
+
+// isWordRune 判断字符是否属于拉丁单词的一部分
+func isWordRune(r rune) bool {
+	return !isCJK(r) && (unicode.IsLetter(r) || unicode.IsDigit(r))
+}
+
+// segment 文本中连续的同类字符片段
+type segment struct {
+	runes []rune
+	cjk   bool
+}
+
+// segments 将文本切分为 CJK 片段和单词片段，其余字符作为分隔符丢弃
+func segments(text string) []segment {
+	var segs []segment
+	var cur []rune
+	curCJK := false
+	flush := func() {
+		if len(cur) > 0 {
+			segs = append(segs, segment{runes: cur, cjk: curCJK})
+			cur = nil
+		}
+	}
+	for _, r := range strings.ToLower(text) {
+		switch {
+		case isCJK(r):
+			if !curCJK {
+				flush()
+			}
+			curCJK = true
+			cur = append(cur, r)
+		case isWordRune(r):
+			if curCJK {
+				flush()
+			}
+			curCJK = false
+			cur = append(cur, r)
+		default:
+			flush()
+		}
+	}
+	flush()
+	return segs
+}
+
+// truncate 截断过长的词元
+func truncate(r []rune) string {
+	if len(r) > maxTermRunes {
+		r = r[:maxTermRunes]
+	}
+	return string(r)
+}
+
+// Tokenize 对待索引的文本分词，返回去重后的词元及其出现次数
+func Tokenize(text string) []Token {
+	freq := map[string]int{}
+	var order []string
+	add := func(term string) {
+		if freq[term] == 0 {
+			order = append(order, term)
+		}
+		freq[term]++
+	}
+
+	for _, seg := range segments(text) {
+		if !seg.cjk {
+			add(truncate(seg.runes))
+			continue
+		}
+		for i := range seg.runes {
+			add(string(seg.runes[i]))
+			if i+1 < len(seg.runes) {
+				add(string(seg.runes[i : i+2]))
+			}
+		}
+	}
+
+	tokens := make([]Token, len(order))
+	for i, term := range order {
+		tokens[i] = Token{Term: term, Freq: freq[term]}
+	}
+	return tokens
+}
+
+// QueryTerms 对查询语句分词，返回去重后的查询词元
+func QueryTerms(query string) []string {
+	seen := map[string]bool{}
+	var terms []string
+	add := func(term string) {
+		if !seen[term] {
+			seen[term] = true
+			terms = append(terms, term)
+		}
+	}
+
+	for _, seg := range segments(query) {
+		if !seg.cjk {
+			add(truncate(seg.runes))
+			continue
+		}
+		if len(seg.runes) == 1 {
+			add(string(seg.runes))
+			continue
+		}
+		for i := 0; i+1 < len(seg.runes); i++ {
+			add(string(seg.runes[i : i+2]))
+		}
+	}
+	return terms
+}
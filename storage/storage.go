@@ -0,0 +1,60 @@
+// Package storage 定义附件内容的存储接口 (BlobStore) 及其实现
+//
+// 内容按 SHA-256 摘要寻址：键就是内容的十六进制摘要，相同内容只存一份。
+// 目前提供本地文件系统实现 LocalStore，S3 兼容的对象存储可实现同一接口后在 Open 中接入。
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+)
+
+// ErrNotFound 键对应的内容不存在
+var ErrNotFound = errors.New("附件内容不存在")
+
+// ErrInvalidKey 键不是 64 位小写十六进制摘要
+var ErrInvalidKey = errors.New("无效的存储键")
+
+// keyPattern SHA-256 十六进制摘要
+var keyPattern = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+// ValidKey 判断 key 是否为有效的存储键
+func ValidKey(key string) bool {
+	return keyPattern.MatchString(key)
+}
+
+// BlobStore 按键存取附件内容，实现需要支持并发调用
+type BlobStore interface {
+	// Put 写入内容，键已存在时直接覆盖 (内容相同)
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	// Open 打开内容用于读取，不存在时返回 ErrNotFound
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+	// Exists 判断键是否存在
+	Exists(ctx context.Context, key string) (bool, error)
+	// Delete 删除内容，不存在时不返回错误
+	Delete(ctx context.Context, key string) error
+}
+
+// Open 按环境变量创建存储
+// STORAGE_DRIVER 目前只支持 local (默认)，内容保存在 STORAGE_LOCAL_DIR 目录下
+func Open() (BlobStore, error) {
+	switch driver := getEnvOrDefault("STORAGE_DRIVER", "local"); driver {
+	case "local":
+		return NewLocalStore(getEnvOrDefault("STORAGE_LOCAL_DIR", "data/attachments"))
+	default:
+		return nil, fmt.Errorf("不支持的存储类型: %s", driver)
+	}
+}
+
+// getEnvOrDefault 获取环境变量，如果不存在则返回默认值
+func getEnvOrDefault(key, defaultValue string) string {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	return value
+}